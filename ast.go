@@ -35,38 +35,53 @@ type StatNode interface {
 	Node
 }
 
+// LetStatNode binds the variables of the pattern matched with the value.
+// The else block is evaluated if the refutable pattern does not match.
 type LetStatNode struct {
-	Let Loc
-	Ptn PtnNode
-	Eq  Loc
-	Exp ExpNode
+	Let        Loc
+	Ptn        PtnNode
+	Type       TypeNode // nullable
+	Eq         Loc
+	Exp        ExpNode
+	Else       *Loc
+	ElseAction *BlockNode
+	End        *Loc
 }
 
 type DefStatNode struct {
-	Def    Loc
-	Name   Token
-	Open   Loc
-	Params *ParamListNode
-	Close  Loc
-	Block  BlockNode
-	End    Loc
+	Def     Loc
+	Name    Token
+	Open    Loc
+	Params  *ParamListNode
+	Close   Loc
+	RetType TypeNode // nullable
+	Block   BlockNode
+	End     Loc
 }
 
 type ShortDefStatNode struct {
-	Def    Loc
-	Name   Token
-	Open   Loc
-	Params *ParamListNode
-	Close  Loc
-	Eq     Loc
-	Exp    ExpNode
+	Def     Loc
+	Name    Token
+	Open    Loc
+	Params  *ParamListNode
+	Close   Loc
+	RetType TypeNode // nullable
+	Eq      Loc
+	Exp     ExpNode
 }
 
 type ParamListNode struct {
 	Names []Token
+	Types []TypeNode // elements are nullable
 	Sep   []Loc
 }
 
+type AssignStatNode struct {
+	Target ExpNode
+	Arrow  Loc
+	Exp    ExpNode
+}
+
 type ForStatNode struct {
 	For   Loc
 	In    Loc
@@ -108,11 +123,85 @@ type CaseClauNode struct {
 	Action *BlockNode
 }
 
+// TryStatNode catches exceptions raised in the block with the rescue
+// clauses. The ensure block runs whether or not an exception is raised.
+type TryStatNode struct {
+	Try          Loc
+	Block        *BlockNode
+	Claus        []RescueClauNode
+	Ensure       *Loc
+	EnsureAction *BlockNode // nullable
+	End          Loc
+}
+
+type RescueClauNode struct {
+	Rescue Loc
+	Ptn    PtnNode
+	Then   Loc
+	Action *BlockNode
+}
+
 type RetStatNode struct {
 	Ret Loc
 	Exp ExpNode
 }
 
+// FixityDeclNode declares the fixity of the operators such as
+// "infixl 10 +++". The parser applies it to the following expressions.
+type FixityDeclNode struct {
+	Assoc Token // "infixl", "infixr" or "infix"
+	Prec  Token
+	Ops   []Token
+}
+
+type StructDeclNode struct {
+	Struct Loc
+	Name   Token
+	Fields []FieldDeclNode
+	End    Loc
+}
+
+type FieldDeclNode struct {
+	Name Token
+	Type TypeNode
+}
+
+type TypeDeclNode struct {
+	Type   Loc
+	Name   Token
+	Params []Token
+	Eq     Loc
+	Ctors  []CtorDeclNode
+}
+
+type TraitDeclNode struct {
+	Trait   Loc
+	Name    Token
+	Param   Token
+	Methods []MethodSigNode
+	End     Loc
+}
+
+type MethodSigNode struct {
+	Def     Loc
+	Name    Token
+	Params  *ParamListNode
+	RetType TypeNode
+}
+
+type ImplDeclNode struct {
+	Impl  Loc
+	Trait Token
+	Type  TypeNode
+	Defs  []StatNode // DefStatNode or ShortDefStatNode
+	End   Loc
+}
+
+type CtorDeclNode struct {
+	Name  Token
+	Types []TypeNode
+}
+
 type ExpNode interface {
 	Node
 }
@@ -123,6 +212,9 @@ type ParenExpNode struct {
 	Exp   Node
 }
 
+// FunCallExpNode is a function call. If any of the arguments is
+// the placeholder "_", the call is a partial application that
+// returns a function taking the placeholders.
 type FunCallExpNode struct {
 	Callable Node
 	Args     EltListNode
@@ -154,10 +246,21 @@ type IntExpNode struct {
 	Value Token
 }
 
-type StrExpNode struct {
+type FloatExpNode struct {
 	Value Token
 }
 
+type StrExpNode struct {
+	Value Token // decoded text
+}
+
+// InterpExpNode is a string literal with interpolations "#{exp}".
+// Parts are string literals and expressions shown with the Show trait.
+type InterpExpNode struct {
+	Value Token // text of the literal
+	Parts []Node
+}
+
 type ListExpNode struct {
 	Elts EltListNode
 }
@@ -198,6 +301,57 @@ type RangeExpNode struct {
 	Right ExpNode
 }
 
+type BinaryExpNode struct {
+	Left  ExpNode
+	Op    Token
+	Right ExpNode
+	Fun   *VarExpNode // nullable, the function of a user-defined operator
+}
+
+type UnaryExpNode struct {
+	Op  Token
+	Exp ExpNode
+}
+
+type RaiseExpNode struct {
+	Raise Loc
+	Exp   ExpNode
+}
+
+type RecordExpNode struct {
+	Open   Loc
+	Close  Loc
+	Name   *Token // nullable
+	Fields []FieldNode
+}
+
+type UpdateExpNode struct {
+	Open   Loc
+	Close  Loc
+	Exp    ExpNode
+	With   Loc
+	Fields []FieldNode
+}
+
+type FieldNode struct {
+	Name Token
+	Exp  ExpNode // nullable, same as the name if omitted
+}
+
+type AttrExpNode struct {
+	Exp  ExpNode
+	Dot  Loc
+	Name Token
+}
+
+// IndexExpNode is "exp[index]". The index is an int or a range.
+type IndexExpNode struct {
+	Exp   ExpNode
+	Open  Loc
+	Index ExpNode
+	Close Loc
+}
+
 type PtnNode interface {
 	Node
 }
@@ -216,10 +370,21 @@ type IntPtnNode struct {
 	Value Token
 }
 
+type FloatPtnNode struct {
+	Value Token
+}
+
 type StrPtnNode struct {
 	Value Token
 }
 
+type RangePtnNode struct {
+	Left  PtnNode
+	Op    Token
+	Close bool
+	Right PtnNode
+}
+
 type ListPtnNode struct {
 	Elts EltPtnListNode
 }
@@ -245,6 +410,80 @@ type VarPtnNode struct {
 	Name Token
 }
 
+// OrPtnNode matches the value if either of the patterns matches it.
+// Both patterns must bind the same variables.
+type OrPtnNode struct {
+	Left  PtnNode
+	Bar   Loc
+	Right PtnNode
+}
+
+// AsPtnNode binds the value matched by the pattern to the name.
+type AsPtnNode struct {
+	Ptn  PtnNode
+	As   Loc
+	Name Token
+}
+
+// PinPtnNode "^x" matches the value equal to the value of the variable.
+type PinPtnNode struct {
+	Caret Loc
+	Name  Token
+}
+
+// SomePtnNode "some(p)" matches the option having the value matched by p.
+type SomePtnNode struct {
+	SomeLoc Loc
+	Value   PtnNode
+}
+
+type NonePtnNode struct {
+	loc Loc
+}
+
+type RecordPtnNode struct {
+	Open   Loc
+	Close  Loc
+	Name   *Token // nullable
+	Fields []FieldPtnNode
+}
+
+type FieldPtnNode struct {
+	Name Token
+	Ptn  PtnNode // nullable, binds the name if omitted
+}
+
+type CtorPtnNode struct {
+	Name Token
+	Args *EltPtnListNode // nullable if the constructor takes no arguments
+}
+
+type TypeNode interface {
+	Node
+}
+
+type NamedTypeNode struct {
+	Name Token
+	Args []TypeNode
+}
+
+type OptTypeNode struct {
+	Type TypeNode
+	Q    Loc
+}
+
+type TupleTypeNode struct {
+	Open  Loc
+	Close Loc
+	Elts  []TypeNode
+}
+
+type FunTypeNode struct {
+	Params []TypeNode
+	Arrow  Loc
+	Ret    TypeNode
+}
+
 func NewToken(loc Loc, text string) Token {
 	return Token{loc, text}
 }
@@ -260,6 +499,10 @@ func NodeDesc(node Node) string {
 	return buf.String()
 }
 
+func NewChunkNode(loc Loc, block *BlockNode) ChunkNode {
+	return ChunkNode{loc: loc, Block: block}
+}
+
 func (chunk *ChunkNode) Loc() *Loc {
 	return &chunk.loc
 }
@@ -274,6 +517,10 @@ func (chunk *ChunkNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString(")")
 }
 
+func NewBlockNode(loc Loc, stats []Node) BlockNode {
+	return BlockNode{loc: loc, Stats: stats}
+}
+
 func (block *BlockNode) Loc() *Loc {
 	return &block.loc
 }
@@ -296,7 +543,15 @@ func (stat *LetStatNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString("(let ")
 	stat.Ptn.WriteTo(buf)
 	buf.WriteString(" ")
+	if stat.Type != nil {
+		stat.Type.WriteTo(buf)
+		buf.WriteString(" ")
+	}
 	stat.Exp.WriteTo(buf)
+	if stat.ElseAction != nil {
+		buf.WriteString(" ")
+		stat.ElseAction.WriteTo(buf)
+	}
 	buf.WriteString(")")
 }
 
@@ -312,6 +567,18 @@ func (stat *DefStatNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString("])")
 }
 
+func (decl *FixityDeclNode) Loc() *Loc {
+	return &decl.Assoc.Loc
+}
+
+func (decl *FixityDeclNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString(fmt.Sprintf("(%s %s", decl.Assoc.Text, decl.Prec.Text))
+	for _, op := range decl.Ops {
+		buf.WriteString(fmt.Sprintf(" \"%s\"", op.Text))
+	}
+	buf.WriteString(")")
+}
+
 func (stat *ShortDefStatNode) Loc() *Loc {
 	return &stat.Def
 }
@@ -328,20 +595,44 @@ func (params *ParamListNode) Loc() *Loc {
 
 func (params *ParamListNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString("(params [")
-	for _, param := range params.Names {
-		buf.WriteString(fmt.Sprintf("\"%s\" ", param.Text))
+	for i, param := range params.Names {
+		buf.WriteString(fmt.Sprintf("\"%s\"", param.Text))
+		if ty := params.TypeAt(i); ty != nil {
+			buf.WriteString(":")
+			ty.WriteTo(buf)
+		}
+		buf.WriteString(" ")
 	}
 	buf.WriteString("])")
 }
 
+func (params *ParamListNode) TypeAt(i int) TypeNode {
+	if i < len(params.Types) {
+		return params.Types[i]
+	}
+	return nil
+}
+
 func (params *ParamListNode) NameStrs() []string {
-	nameStrs := make([]string, len(params.Names))
+	nameStrs := make([]string, 0, len(params.Names))
 	for _, tok := range params.Names {
 		nameStrs = append(nameStrs, tok.Text)
 	}
 	return nameStrs
 }
 
+func (stat *AssignStatNode) Loc() *Loc {
+	return stat.Target.Loc()
+}
+
+func (stat *AssignStatNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(assign ")
+	stat.Target.WriteTo(buf)
+	buf.WriteString(" ")
+	stat.Exp.WriteTo(buf)
+	buf.WriteString(")")
+}
+
 func (stat *ForStatNode) Loc() *Loc {
 	return &stat.For
 }
@@ -426,6 +717,41 @@ func (clau *CaseClauNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString(")")
 }
 
+func (stat *TryStatNode) Loc() *Loc {
+	return &stat.Try
+}
+
+func (stat *TryStatNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(try ")
+	stat.Block.WriteTo(buf)
+	buf.WriteString(" [")
+	for i, clau := range stat.Claus {
+		if i > 0 {
+			buf.WriteString(" ")
+		}
+		clau.WriteTo(buf)
+	}
+	buf.WriteString("] ")
+	if ensure := stat.EnsureAction; ensure != nil {
+		ensure.WriteTo(buf)
+	} else {
+		buf.WriteString("none")
+	}
+	buf.WriteString(")")
+}
+
+func (clau *RescueClauNode) Loc() *Loc {
+	return &clau.Rescue
+}
+
+func (clau *RescueClauNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(rescue ")
+	clau.Ptn.WriteTo(buf)
+	buf.WriteString(" ")
+	clau.Action.WriteTo(buf)
+	buf.WriteString(")")
+}
+
 func (stat *RetStatNode) Loc() *Loc {
 	return &stat.Ret
 }
@@ -440,6 +766,72 @@ func (stat *RetStatNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString(")")
 }
 
+func (stat *StructDeclNode) Loc() *Loc {
+	return &stat.Struct
+}
+
+func (stat *StructDeclNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString(fmt.Sprintf("(struct \"%s\" [", stat.Name.Text))
+	for _, field := range stat.Fields {
+		buf.WriteString(fmt.Sprintf("(field \"%s\" ", field.Name.Text))
+		field.Type.WriteTo(buf)
+		buf.WriteString(") ")
+	}
+	buf.WriteString("])")
+}
+
+func (stat *TypeDeclNode) Loc() *Loc {
+	return &stat.Type
+}
+
+func (stat *TypeDeclNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString(fmt.Sprintf("(type \"%s\" [", stat.Name.Text))
+	for _, param := range stat.Params {
+		buf.WriteString(fmt.Sprintf("\"%s\" ", param.Text))
+	}
+	buf.WriteString("] [")
+	for _, ctor := range stat.Ctors {
+		buf.WriteString(fmt.Sprintf("(ctor \"%s\" [", ctor.Name.Text))
+		for _, ty := range ctor.Types {
+			ty.WriteTo(buf)
+			buf.WriteString(" ")
+		}
+		buf.WriteString("]) ")
+	}
+	buf.WriteString("])")
+}
+
+func (stat *TraitDeclNode) Loc() *Loc {
+	return &stat.Trait
+}
+
+func (stat *TraitDeclNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString(fmt.Sprintf("(trait \"%s\" \"%s\" [", stat.Name.Text, stat.Param.Text))
+	for _, method := range stat.Methods {
+		buf.WriteString(fmt.Sprintf("(method \"%s\" ", method.Name.Text))
+		method.Params.WriteTo(buf)
+		buf.WriteString(" ")
+		method.RetType.WriteTo(buf)
+		buf.WriteString(") ")
+	}
+	buf.WriteString("])")
+}
+
+func (stat *ImplDeclNode) Loc() *Loc {
+	return &stat.Impl
+}
+
+func (stat *ImplDeclNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString(fmt.Sprintf("(impl \"%s\" ", stat.Trait.Text))
+	stat.Type.WriteTo(buf)
+	buf.WriteString(" [")
+	for _, def := range stat.Defs {
+		def.WriteTo(buf)
+		buf.WriteString(" ")
+	}
+	buf.WriteString("])")
+}
+
 func (exp *ParenExpNode) Loc() *Loc {
 	return &exp.Open
 }
@@ -450,6 +842,23 @@ func (exp *ParenExpNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString(")")
 }
 
+// IsPlaceholder reports whether the argument is the placeholder "_".
+func IsPlaceholder(arg Node) bool {
+	v, ok := arg.(*VarExpNode)
+	return ok && v.Name.Text == "_"
+}
+
+// Placeholders returns the bit mask of the placeholders in the arguments.
+func (exp *FunCallExpNode) Placeholders() int {
+	mask := 0
+	for i, arg := range exp.Args.Elts {
+		if IsPlaceholder(arg) {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
+
 func (exp *FunCallExpNode) Loc() *Loc {
 	return exp.Callable.Loc()
 }
@@ -477,7 +886,7 @@ func (exp *CondOpExpNode) WriteTo(buf *bytes.Buffer) {
 }
 
 func NewVarExpNode(name Token) VarExpNode {
-	return VarExpNode{name}
+	return VarExpNode{Name: name}
 }
 
 func (exp *VarExpNode) Loc() *Loc {
@@ -496,6 +905,10 @@ func (exp *UnitExpNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString("(unit)")
 }
 
+func NewBoolExpNode(loc Loc, value bool) BoolExpNode {
+	return BoolExpNode{loc: loc, Value: value}
+}
+
 func (exp *BoolExpNode) Loc() *Loc {
 	return &exp.loc
 }
@@ -518,12 +931,33 @@ func (exp *IntExpNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString(fmt.Sprintf("(int \"%s\")", exp.Value.Text))
 }
 
+func (exp *FloatExpNode) Loc() *Loc {
+	return &exp.Value.Loc
+}
+
+func (exp *FloatExpNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString(fmt.Sprintf("(float \"%s\")", exp.Value.Text))
+}
+
 func (exp *StrExpNode) Loc() *Loc {
 	return &exp.Value.Loc
 }
 
 func (exp *StrExpNode) WriteTo(buf *bytes.Buffer) {
-	buf.WriteString(fmt.Sprintf("(str \"%s\")", exp.Value.Text))
+	buf.WriteString(fmt.Sprintf("(str %q)", exp.Value.Text))
+}
+
+func (exp *InterpExpNode) Loc() *Loc {
+	return &exp.Value.Loc
+}
+
+func (exp *InterpExpNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(interp")
+	for _, part := range exp.Parts {
+		buf.WriteString(" ")
+		part.WriteTo(buf)
+	}
+	buf.WriteString(")")
 }
 
 func (exp *ListExpNode) Loc() *Loc {
@@ -569,6 +1003,10 @@ func (exp *SomeExpNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString(")")
 }
 
+func NewNoneExpNode(loc Loc) NoneExpNode {
+	return NoneExpNode{loc: loc}
+}
+
 func (exp *NoneExpNode) Loc() *Loc {
 	return &exp.loc
 }
@@ -606,6 +1044,101 @@ func (exp *RangeExpNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString(")")
 }
 
+func (exp *BinaryExpNode) Loc() *Loc {
+	return exp.Left.Loc()
+}
+
+func (exp *BinaryExpNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString(fmt.Sprintf("(binexp \"%s\" ", exp.Op.Text))
+	exp.Left.WriteTo(buf)
+	buf.WriteString(" ")
+	exp.Right.WriteTo(buf)
+	buf.WriteString(")")
+}
+
+func (exp *UnaryExpNode) Loc() *Loc {
+	return &exp.Op.Loc
+}
+
+func (exp *UnaryExpNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString(fmt.Sprintf("(unexp \"%s\" ", exp.Op.Text))
+	exp.Exp.WriteTo(buf)
+	buf.WriteString(")")
+}
+
+func (exp *RaiseExpNode) Loc() *Loc {
+	return &exp.Raise
+}
+
+func (exp *RaiseExpNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(raise ")
+	exp.Exp.WriteTo(buf)
+	buf.WriteString(")")
+}
+
+func (exp *RecordExpNode) Loc() *Loc {
+	return &exp.Open
+}
+
+func (exp *RecordExpNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(record ")
+	if exp.Name != nil {
+		buf.WriteString(fmt.Sprintf("\"%s\" ", exp.Name.Text))
+	} else {
+		buf.WriteString("none ")
+	}
+	writeFieldsTo(buf, exp.Fields)
+	buf.WriteString(")")
+}
+
+func (exp *UpdateExpNode) Loc() *Loc {
+	return &exp.Open
+}
+
+func (exp *UpdateExpNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(update ")
+	exp.Exp.WriteTo(buf)
+	buf.WriteString(" ")
+	writeFieldsTo(buf, exp.Fields)
+	buf.WriteString(")")
+}
+
+func writeFieldsTo(buf *bytes.Buffer, fields []FieldNode) {
+	buf.WriteString("[")
+	for _, field := range fields {
+		buf.WriteString(fmt.Sprintf("(field \"%s\" ", field.Name.Text))
+		if field.Exp != nil {
+			field.Exp.WriteTo(buf)
+		} else {
+			buf.WriteString("none")
+		}
+		buf.WriteString(") ")
+	}
+	buf.WriteString("]")
+}
+
+func (exp *AttrExpNode) Loc() *Loc {
+	return &exp.Name.Loc
+}
+
+func (exp *AttrExpNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(attr ")
+	exp.Exp.WriteTo(buf)
+	buf.WriteString(fmt.Sprintf(" \"%s\")", exp.Name.Text))
+}
+
+func (exp *IndexExpNode) Loc() *Loc {
+	return &exp.Open
+}
+
+func (exp *IndexExpNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(index ")
+	exp.Exp.WriteTo(buf)
+	buf.WriteString(" ")
+	exp.Index.WriteTo(buf)
+	buf.WriteString(")")
+}
+
 func (ptn *UnitPtnNode) Loc() *Loc {
 	return &ptn.Open
 }
@@ -614,6 +1147,10 @@ func (ptn *UnitPtnNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString("(unitptn)")
 }
 
+func NewBoolPtnNode(loc Loc, value bool) BoolPtnNode {
+	return BoolPtnNode{loc: loc, Value: value}
+}
+
 func (ptn *BoolPtnNode) Loc() *Loc {
 	return &ptn.loc
 }
@@ -636,6 +1173,26 @@ func (ptn *IntPtnNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString(fmt.Sprintf("(intptn \"%s\")", ptn.Value.Text))
 }
 
+func (ptn *FloatPtnNode) Loc() *Loc {
+	return &ptn.Value.Loc
+}
+
+func (ptn *FloatPtnNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString(fmt.Sprintf("(floatptn \"%s\")", ptn.Value.Text))
+}
+
+func (ptn *RangePtnNode) Loc() *Loc {
+	return ptn.Left.Loc()
+}
+
+func (ptn *RangePtnNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(rangeptn ")
+	ptn.Left.WriteTo(buf)
+	buf.WriteString(fmt.Sprintf(" %s ", ptn.Op.Text))
+	ptn.Right.WriteTo(buf)
+	buf.WriteString(")")
+}
+
 func (ptn *StrPtnNode) Loc() *Loc {
 	return &ptn.Value.Loc
 }
@@ -696,3 +1253,146 @@ func (ptn *VarPtnNode) Loc() *Loc {
 func (ptn *VarPtnNode) WriteTo(buf *bytes.Buffer) {
 	buf.WriteString(fmt.Sprintf("(varptn %s)", ptn.Name.Text))
 }
+
+func (ptn *OrPtnNode) Loc() *Loc {
+	return ptn.Left.Loc()
+}
+
+func (ptn *OrPtnNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(orptn ")
+	ptn.Left.WriteTo(buf)
+	buf.WriteString(" ")
+	ptn.Right.WriteTo(buf)
+	buf.WriteString(")")
+}
+
+func (ptn *AsPtnNode) Loc() *Loc {
+	return ptn.Ptn.Loc()
+}
+
+func (ptn *AsPtnNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(asptn ")
+	ptn.Ptn.WriteTo(buf)
+	buf.WriteString(fmt.Sprintf(" %s)", ptn.Name.Text))
+}
+
+func (ptn *PinPtnNode) Loc() *Loc {
+	return &ptn.Caret
+}
+
+func (ptn *PinPtnNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString(fmt.Sprintf("(pinptn %s)", ptn.Name.Text))
+}
+
+func (ptn *SomePtnNode) Loc() *Loc {
+	return &ptn.SomeLoc
+}
+
+func (ptn *SomePtnNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(someptn ")
+	ptn.Value.WriteTo(buf)
+	buf.WriteString(")")
+}
+
+func NewNonePtnNode(loc Loc) NonePtnNode {
+	return NonePtnNode{loc: loc}
+}
+
+func (ptn *NonePtnNode) Loc() *Loc {
+	return &ptn.loc
+}
+
+func (ptn *NonePtnNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(noneptn)")
+}
+
+func (ty *NamedTypeNode) Loc() *Loc {
+	return &ty.Name.Loc
+}
+
+func (ty *NamedTypeNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString(fmt.Sprintf("(type \"%s\" [", ty.Name.Text))
+	for _, arg := range ty.Args {
+		arg.WriteTo(buf)
+		buf.WriteString(" ")
+	}
+	buf.WriteString("])")
+}
+
+func (ty *OptTypeNode) Loc() *Loc {
+	return ty.Type.Loc()
+}
+
+func (ty *OptTypeNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(opttype ")
+	ty.Type.WriteTo(buf)
+	buf.WriteString(")")
+}
+
+func (ty *TupleTypeNode) Loc() *Loc {
+	return &ty.Open
+}
+
+func (ty *TupleTypeNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(tupletype [")
+	for _, elt := range ty.Elts {
+		elt.WriteTo(buf)
+		buf.WriteString(" ")
+	}
+	buf.WriteString("])")
+}
+
+func (ty *FunTypeNode) Loc() *Loc {
+	if len(ty.Params) > 0 {
+		return ty.Params[0].Loc()
+	}
+	return &ty.Arrow
+}
+
+func (ty *FunTypeNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(funtype [")
+	for _, param := range ty.Params {
+		param.WriteTo(buf)
+		buf.WriteString(" ")
+	}
+	buf.WriteString("] ")
+	ty.Ret.WriteTo(buf)
+	buf.WriteString(")")
+}
+
+func (ptn *RecordPtnNode) Loc() *Loc {
+	return &ptn.Open
+}
+
+func (ptn *RecordPtnNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString("(recordptn ")
+	if ptn.Name != nil {
+		buf.WriteString(fmt.Sprintf("\"%s\" [", ptn.Name.Text))
+	} else {
+		buf.WriteString("none [")
+	}
+	for _, field := range ptn.Fields {
+		buf.WriteString(fmt.Sprintf("(fieldptn \"%s\" ", field.Name.Text))
+		if field.Ptn != nil {
+			field.Ptn.WriteTo(buf)
+		} else {
+			buf.WriteString("none")
+		}
+		buf.WriteString(") ")
+	}
+	buf.WriteString("])")
+}
+
+func (ptn *CtorPtnNode) Loc() *Loc {
+	return &ptn.Name.Loc
+}
+
+func (ptn *CtorPtnNode) WriteTo(buf *bytes.Buffer) {
+	buf.WriteString(fmt.Sprintf("(ctorptn \"%s\" ", ptn.Name.Text))
+	if ptn.Args != nil {
+		ptn.Args.WriteTo(buf)
+	} else {
+		buf.WriteString("none")
+	}
+	buf.WriteString(")")
+}
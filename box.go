@@ -0,0 +1,22 @@
+package trompe
+
+import (
+	"fmt"
+)
+
+// Box is a mutable cell. Boxes are equal only if they are identical.
+type Box struct {
+	Value Value
+}
+
+func NewBox(value Value) *Box {
+	return &Box{Value: value}
+}
+
+func (b *Box) Type() int {
+	return ValueTypeBox
+}
+
+func (b *Box) Desc() string {
+	return fmt.Sprintf("box(%s)", b.Value.Desc())
+}
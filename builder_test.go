@@ -0,0 +1,225 @@
+package trompe
+
+import (
+	"testing"
+)
+
+// Builders of the nodes for the tests without the parser.
+
+func tok(s string) Token { return Token{Text: s} }
+
+func ntok(s string) *Token {
+	t := tok(s)
+	return &t
+}
+
+func vr(name string) *VarExpNode { return &VarExpNode{Name: tok(name)} }
+
+func in(s string) *IntExpNode { return &IntExpNode{Value: tok(s)} }
+
+func fl(s string) *FloatExpNode { return &FloatExpNode{Value: tok(s)} }
+
+func st(s string) *StrExpNode { return &StrExpNode{Value: tok(s)} }
+
+func bl(b bool) *BoolExpNode { return &BoolExpNode{Value: b} }
+
+func unit() *UnitExpNode { return &UnitExpNode{} }
+
+func none() *NoneExpNode {
+	n := NewNoneExpNode(Loc{})
+	return &n
+}
+
+func some(e Node) *SomeExpNode { return &SomeExpNode{Value: e} }
+
+func call(f Node, args ...Node) *FunCallExpNode {
+	return &FunCallExpNode{Callable: f, Args: EltListNode{Elts: args}}
+}
+
+func attr(e Node, name string) *AttrExpNode { return &AttrExpNode{Exp: e, Name: tok(name)} }
+
+func mcall(recv Node, name string, args ...Node) *FunCallExpNode {
+	return call(attr(recv, name), args...)
+}
+
+func bin(l Node, op string, r Node) *BinaryExpNode {
+	return &BinaryExpNode{Left: l, Op: tok(op), Right: r}
+}
+
+// ubin is a binary operation of the user-defined operator.
+func ubin(l Node, op string, r Node) *BinaryExpNode {
+	b := bin(l, op, r)
+	b.Fun = vr(op)
+	return b
+}
+
+func un(op string, e Node) *UnaryExpNode { return &UnaryExpNode{Op: tok(op), Exp: e} }
+
+func tupe(elts ...Node) *TupleExpNode { return &TupleExpNode{Elts: EltListNode{Elts: elts}} }
+
+func lst(elts ...Node) *ListExpNode { return &ListExpNode{Elts: EltListNode{Elts: elts}} }
+
+func idx(e Node, i Node) *IndexExpNode { return &IndexExpNode{Exp: e, Index: i} }
+
+func rng(l Node, r Node, close bool) *RangeExpNode {
+	return &RangeExpNode{Left: l, Right: r, Close: close}
+}
+
+func raise(e Node) *RaiseExpNode { return &RaiseExpNode{Exp: e} }
+
+func blk(stats ...Node) *BlockNode { return &BlockNode{Stats: stats} }
+
+func chunk(stats ...Node) *ChunkNode { return &ChunkNode{Block: blk(stats...)} }
+
+func params(names ...string) *ParamListNode {
+	p := &ParamListNode{}
+	for _, name := range names {
+		p.Names = append(p.Names, tok(name))
+		p.Types = append(p.Types, nil)
+	}
+	return p
+}
+
+func anon(names []string, body Node) *AnonFunExpNode {
+	return &AnonFunExpNode{Params: params(names...), Exp: body}
+}
+
+func let(p PtnNode, e Node) *LetStatNode { return &LetStatNode{Ptn: p, Exp: e} }
+
+func sdef(name string, names []string, body Node) *ShortDefStatNode {
+	return &ShortDefStatNode{Name: tok(name), Params: params(names...), Exp: body}
+}
+
+func def(name string, names []string, stats ...Node) *DefStatNode {
+	return &DefStatNode{Name: tok(name), Params: params(names...), Block: *blk(stats...)}
+}
+
+func ret(e Node) *RetStatNode { return &RetStatNode{Exp: e} }
+
+func ifElse(cond Node, then *BlockNode, els *BlockNode) *IfStatNode {
+	return &IfStatNode{Cond: []IfCondNode{{Cond: cond, Action: *then}},
+		Else: &Loc{}, ElseAction: els}
+}
+
+func named(name string, args ...TypeNode) *NamedTypeNode {
+	return &NamedTypeNode{Name: tok(name), Args: args}
+}
+
+func structDecl(name string, fields ...string) *StructDeclNode {
+	decl := &StructDeclNode{Name: tok(name)}
+	for i := 0; i < len(fields); i += 2 {
+		decl.Fields = append(decl.Fields,
+			FieldDeclNode{Name: tok(fields[i]), Type: named(fields[i+1])})
+	}
+	return decl
+}
+
+func field(name string, e Node) FieldNode { return FieldNode{Name: tok(name), Exp: e} }
+
+// record returns a record literal. The struct name may be empty.
+func record(name string, fields ...FieldNode) *RecordExpNode {
+	rec := &RecordExpNode{Fields: fields}
+	if name != "" {
+		rec.Name = ntok(name)
+	}
+	return rec
+}
+
+func impl(trait string, ty string, defs ...StatNode) *ImplDeclNode {
+	return &ImplDeclNode{Trait: tok(trait), Type: named(ty), Defs: defs}
+}
+
+// Patterns
+
+func vp(name string) *VarPtnNode { return &VarPtnNode{Name: tok(name)} }
+
+func ip(s string) *IntPtnNode { return &IntPtnNode{Value: tok(s)} }
+
+func sp(s string) *StrPtnNode { return &StrPtnNode{Value: tok(s)} }
+
+func bp(b bool) *BoolPtnNode { return &BoolPtnNode{Value: b} }
+
+func pin(name string) *PinPtnNode { return &PinPtnNode{Name: tok(name)} }
+
+func tup(elts ...PtnNode) *TuplePtnNode { return &TuplePtnNode{Elts: EltPtnListNode{Elts: elts}} }
+
+func lptn(elts ...PtnNode) *ListPtnNode { return &ListPtnNode{Elts: EltPtnListNode{Elts: elts}} }
+
+func cons(h PtnNode, t PtnNode) *ConsPtnNode { return &ConsPtnNode{Left: h, Right: t} }
+
+func orp(l PtnNode, r PtnNode) *OrPtnNode { return &OrPtnNode{Left: l, Right: r} }
+
+func somep(p PtnNode) *SomePtnNode { return &SomePtnNode{Value: p} }
+
+func nonep() *NonePtnNode {
+	p := NewNonePtnNode(Loc{})
+	return &p
+}
+
+func ctorp(name string, args ...PtnNode) *CtorPtnNode {
+	p := &CtorPtnNode{Name: tok(name)}
+	if args != nil {
+		p.Args = &EltPtnListNode{Elts: args}
+	}
+	return p
+}
+
+func clau(p PtnNode, guard Node, stats ...Node) CaseClauNode {
+	return CaseClauNode{Ptn: p, Guard: guard, Action: blk(stats...)}
+}
+
+func caseOf(cond Node, claus ...CaseClauNode) *CaseStatNode {
+	return &CaseStatNode{Cond: cond, Claus: claus}
+}
+
+func caseElse(cond Node, els Node, claus ...CaseClauNode) *CaseStatNode {
+	return &CaseStatNode{Cond: cond, Claus: claus, Else: &Loc{}, ElseAction: blk(els)}
+}
+
+func try(body *BlockNode, ensure *BlockNode, claus ...RescueClauNode) *TryStatNode {
+	return &TryStatNode{Block: body, Claus: claus, EnsureAction: ensure}
+}
+
+func rescue(p PtnNode, stats ...Node) RescueClauNode {
+	return RescueClauNode{Ptn: p, Action: blk(stats...)}
+}
+
+// Running
+
+// check type checks the statements and expects the number of errors.
+func check(t *testing.T, nerr int, stats ...Node) *TypeInfo {
+	t.Helper()
+	Init()
+	info, errs := TypeCheck("test", chunk(stats...))
+	for _, err := range errs {
+		t.Log(err.Error())
+	}
+	if len(errs) != nerr {
+		t.Fatalf("%d type errors, want %d", len(errs), nerr)
+	}
+	return info
+}
+
+// exec type checks, compiles and runs the statements. The value of
+// the last statement is returned with the runtime error.
+func exec(t *testing.T, stats ...Node) (Value, error) {
+	t.Helper()
+	info := check(t, 0, stats...)
+	code, diags := Compile("test", blk(stats...), info)
+	if len(diags) > 0 {
+		t.Fatalf("%v", diags)
+	}
+	return Run("test", code)
+}
+
+// eval runs the statements and compares the description of the value.
+func eval(t *testing.T, want string, stats ...Node) {
+	t.Helper()
+	v, err := exec(t, stats...)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if v == nil || v.Desc() != want {
+		t.Errorf("got %v, want %s", v, want)
+	}
+}
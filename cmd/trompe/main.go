@@ -14,6 +14,15 @@ var versionModeOpt = flag.Bool("version", false, "print version")
 var syntaxOpt = flag.Bool("syntax", false, "check syntax only")
 var debugAstOpt = flag.Bool("debug-ast", false, "parse a file and print ast")
 
+func parse(file string) trompe.Node {
+	node, diags := parser.Parse(file)
+	if len(diags) > 0 {
+		trompe.PrintDiagnostics(diags)
+		os.Exit(1)
+	}
+	return node
+}
+
 func main() {
 	flag.Parse()
 
@@ -35,14 +44,14 @@ func main() {
 
 	if *debugAstOpt {
 		file := flag.Arg(0)
-		node := parser.Parse(file)
+		node := parse(file)
 		fmt.Printf("%s\n", trompe.NodeDesc(node))
 		os.Exit(0)
 	}
 
 	if *syntaxOpt {
 		file := flag.Arg(0)
-		parser.Parse(file)
+		parse(file)
 		os.Exit(0)
 	}
 
@@ -54,8 +63,17 @@ func main() {
 	}
 
 	file := flag.Arg(0)
-	node := parser.Parse(file)
-	code := trompe.Compile(file, node)
+	node := parse(file)
+	info, diags := trompe.TypeCheck(file, node)
+	if len(diags) > 0 {
+		trompe.PrintDiagnostics(diags)
+		os.Exit(1)
+	}
+	code, diags := trompe.Compile(file, node, info)
+	if len(diags) > 0 {
+		trompe.PrintDiagnostics(diags)
+		os.Exit(1)
+	}
 	fmt.Println(code.Inspect())
 	trompe.Run(file, code)
 }
@@ -17,6 +17,15 @@ var versionModeOpt = flag.Bool("version", false, "print version")
 var printOpt = flag.Bool("p", false, "output compiled code to standart output")
 var debugAstOpt = flag.Bool("debug-ast", false, "parse a file and print ast")
 
+func parse(file string) trompe.Node {
+	node, diags := parser.Parse(file)
+	if len(diags) > 0 {
+		trompe.PrintDiagnostics(diags)
+		os.Exit(1)
+	}
+	return node
+}
+
 func main() {
 	flag.Parse()
 
@@ -38,7 +47,7 @@ func main() {
 
 	if *debugAstOpt {
 		file := flag.Arg(0)
-		node := parser.Parse(file)
+		node := parse(file)
 		fmt.Printf("%s\n", trompe.NodeDesc(node))
 	}
 
@@ -50,10 +59,22 @@ func main() {
 	}
 
 	file := flag.Arg(0)
-	node := parser.Parse(file)
-	code := trompe.Compile(file, node)
+	node := parse(file)
+	info, diags := trompe.TypeCheck(file, node)
+	if len(diags) > 0 {
+		trompe.PrintDiagnostics(diags)
+		os.Exit(1)
+	}
+	code, diags := trompe.Compile(file, node, info)
+	if len(diags) > 0 {
+		trompe.PrintDiagnostics(diags)
+		os.Exit(1)
+	}
 	objFile := trompe.NewObjectFile(file)
-	objFile.AddCompiledCode(code)
+	if err := objFile.AddCompiledCode(code); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(1)
+	}
 	objFile.AddAttr(trompe.NewObjectAttr("main",
 		trompe.NewObjectValue(trompe.ObjectValueTypeCode,
 			fmt.Sprintf("%d", code.Id))))
@@ -6,6 +6,8 @@ import (
 	"strings"
 )
 
+// Closure is a value applicable to arguments. The environment given
+// to Apply is used by closures that do not capture environments.
 type Closure interface {
 	Arity() int
 	Apply(*Interp, *Context, *Env) (Value, error)
@@ -13,6 +15,7 @@ type Closure interface {
 
 type CompiledCode struct {
 	Id     int
+	Params []string
 	Syms   []string
 	Lits   []Value
 	Ops    []Opcode
@@ -40,6 +43,7 @@ func (code *CompiledCode) LiteralDesc(i int) string {
 func (code *CompiledCode) Inspect() string {
 	var b strings.Builder
 	b.WriteString(fmt.Sprintf("id: %d\n", code.Id))
+	b.WriteString(fmt.Sprintf("params: %v\n", code.Params))
 
 	b.WriteString("symbols:\n")
 	for i, name := range code.Syms {
@@ -101,13 +105,13 @@ func (code *CompiledCode) Inspect() string {
 		case OpStoreLocal:
 			i := code.Ops[pc+1]
 			pc++
-			s += fmt.Sprintf("store local \"%s\"", code.LiteralDesc(i))
+			s += fmt.Sprintf("store local \"%s\"", code.Syms[i])
 		case OpStoreRef:
 			s += fmt.Sprintf("store ref")
 		case OpStoreAttr:
 			i := code.Ops[pc+1]
 			pc++
-			s += fmt.Sprintf("store attr \"%s\"", code.LiteralDesc(i))
+			s += fmt.Sprintf("store attr \"%s\"", code.Syms[i])
 		case OpPop:
 			s += "pop"
 		case OpDup:
@@ -140,18 +144,38 @@ func (code *CompiledCode) Inspect() string {
 			s += "begin block"
 		case OpEnd:
 			s += "end block"
+		case OpTry:
+			i := code.Ops[pc+1]
+			pc++
+			s += fmt.Sprintf("try; rescue L%d", i)
+		case OpEndTry:
+			s += "end try"
 		case OpCall:
 			i := code.Ops[pc+1]
 			pc++
 			s += fmt.Sprintf("call with %d args", i)
+		case OpCallMethod:
+			i := code.Ops[pc+1]
+			n := code.Ops[pc+2]
+			pc += 2
+			s += fmt.Sprintf("call method %s with %d args", code.Syms[i], n)
+		case OpPartial:
+			i := code.Ops[pc+1]
+			mask := code.Ops[pc+2]
+			pc += 2
+			s += fmt.Sprintf("partial apply %d args (placeholders %b)", i, mask)
+		case OpPipe:
+			s += "pipe"
 		case OpPanic:
 			i := code.Ops[pc+1]
 			pc++
 			s += fmt.Sprintf("panic %d", i)
+		case OpRaise:
+			s += "raise"
 		case OpEq:
 			s += "=="
 		case OpNe:
-			s += "=="
+			s += "!="
 		case OpLt:
 			s += "<"
 		case OpLe:
@@ -172,8 +196,36 @@ func (code *CompiledCode) Inspect() string {
 			s += "/"
 		case OpMod:
 			s += "%"
+		case OpFloorDiv:
+			s += "//"
+		case OpBitAnd:
+			s += "&"
+		case OpBitOr:
+			s += "|"
+		case OpBitXor:
+			s += "~"
+		case OpShiftLeft:
+			s += "<<"
+		case OpShiftRight:
+			s += ">>"
+		case OpNeg:
+			s += "negate"
+		case OpNot:
+			s += "not"
+		case OpBitNot:
+			s += "bitwise not"
+		case OpShow:
+			s += "show"
+		case OpConcat:
+			i := code.Ops[pc+1]
+			pc++
+			s += fmt.Sprintf("concat %d", i)
+		case OpIndex:
+			s += "index"
 		case OpSome:
 			s += "create some"
+		case OpCons:
+			s += "::"
 		case OpList:
 			i := code.Ops[pc+1]
 			pc++
@@ -188,6 +240,22 @@ func (code *CompiledCode) Inspect() string {
 			s += fmt.Sprintf("create half-open range")
 		case OpIter:
 			s += fmt.Sprintf("create iterator")
+		case OpRecord:
+			i := code.Ops[pc+1]
+			pc++
+			s += fmt.Sprintf("create record %s", code.LiteralDesc(i))
+		case OpUpdateRecord:
+			i := code.Ops[pc+1]
+			pc++
+			s += fmt.Sprintf("update record %s", code.LiteralDesc(i))
+		case OpImpl:
+			i := code.Ops[pc+1]
+			pc++
+			s += fmt.Sprintf("register %s", code.LiteralDesc(i))
+		case OpClosure:
+			i := code.Ops[pc+1]
+			pc++
+			s += fmt.Sprintf("closure %s", code.LiteralDesc(i))
 		default:
 			panic(fmt.Sprintf("unknown opcode %d", code.Ops[pc]))
 		}
@@ -205,8 +273,7 @@ func (code *CompiledCode) Desc() string {
 }
 
 func (code *CompiledCode) Arity() int {
-	// TODO
-	return 0
+	return len(code.Params)
 }
 
 func (code *CompiledCode) Apply(ip *Interp, ctx *Context, env *Env) (Value, error) {
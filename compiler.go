@@ -2,9 +2,35 @@ package trompe
 
 import (
 	"fmt"
-	"strconv"
 )
 
+var binaryOps = map[string]int{
+	"+":  OpAdd,
+	"-":  OpSub,
+	"*":  OpMul,
+	"/":  OpDiv,
+	"//": OpFloorDiv,
+	"%":  OpMod,
+	"&":  OpBitAnd,
+	"|":  OpBitOr,
+	"~":  OpBitXor,
+	"<<": OpShiftLeft,
+	">>": OpShiftRight,
+	"==": OpEq,
+	"!=": OpNe,
+	"<":  OpLt,
+	"<=": OpLe,
+	">":  OpGt,
+	">=": OpGe,
+	"::": OpCons,
+}
+
+var unaryOps = map[string]int{
+	"-":   OpNeg,
+	"not": OpNot,
+	"~":   OpBitNot,
+}
+
 type codeComp struct {
 	comp     *compiler
 	params   []string
@@ -13,11 +39,14 @@ type codeComp struct {
 	ops      []int
 	labels   int
 	labelMap map[int]int
-	funComps map[string]*codeComp
+	tries    []*BlockNode // handlers of the enclosing try statements
 }
 
 type compiler struct {
-	path string
+	path    string
+	diags   []Diagnostic
+	info    *TypeInfo
+	structs map[string][]string // field names in declaration order
 }
 
 func newCodeComp(comp *compiler) *codeComp {
@@ -28,7 +57,6 @@ func newCodeComp(comp *compiler) *codeComp {
 		ops:      make([]int, 0),
 		labels:   -1,
 		labelMap: make(map[int]int, 16),
-		funComps: make(map[string]*codeComp, 16),
 	}
 }
 
@@ -37,6 +65,11 @@ func (c *codeComp) newCodeComp() *codeComp {
 	return new
 }
 
+func (c *codeComp) error(loc *Loc, format string, args ...interface{}) {
+	c.comp.diags = append(c.comp.diags,
+		NewDiagnostic(c.comp.path, *loc, fmt.Sprintf(format, args...)))
+}
+
 func (c *codeComp) addParam(name string) {
 	c.params = append(c.params, name)
 }
@@ -100,12 +133,44 @@ func (c *codeComp) addStr(s string) int {
 	return c.addLit(NewString(s))
 }
 
-func (c *codeComp) addFun(name string, comp *codeComp) {
-	c.funComps[name] = comp
+// addFun binds the function to the name in the current environment.
+// The function refers to itself by the name to recurse.
+func (c *codeComp) addFun(name string, code *CompiledCode) {
+	c.addOpClosure(code)
+	c.addOp(OpStoreLocal)
+	c.addOp(c.addSym(name))
+	c.addOpPop()
+	c.addOp(OpLoadUnit)
+}
+
+// addOpClosure creates the closure of the function
+// capturing the current environment.
+func (c *codeComp) addOpClosure(code *CompiledCode) {
+	c.addOp(OpClosure)
+	c.addOp(c.addLit(code))
+}
+
+// compileFun compiles the body of a function.
+// The arguments are bound to the parameters on entry.
+func (c *codeComp) compileFun(params *ParamListNode, body Node) *CompiledCode {
+	comp := c.newCodeComp()
+	if params != nil {
+		comp.params = params.NameStrs()
+	}
+	for i, param := range comp.params {
+		comp.addOp(OpLoadArg)
+		comp.addOp(i)
+		comp.addOp(OpStoreLocal)
+		comp.addOp(comp.addSym(param))
+		comp.addOpPop()
+	}
+	comp.compile(body)
+	comp.addOp(OpReturn)
+	return comp.code()
 }
 
 func (c *codeComp) addMatch(n PtnNode) {
-	ptn := NewPatternFromNode(n)
+	ptn := NewPatternFromNode(n, c.comp.info.Ctors)
 	i := c.addLit(ptn)
 	c.addOp(OpLoadLit)
 	c.addOp(i)
@@ -117,8 +182,91 @@ func (c *codeComp) addOpPanic(kind int) {
 	c.addOp(kind)
 }
 
+// compileClause matches the value on the stack in the scope of the clause.
+// If the pattern matches and the guard holds, the value is popped and
+// the action is evaluated. Otherwise the bindings are discarded and
+// the value is left for the next clause.
+func (c *codeComp) compileClause(ptn PtnNode, guard ExpNode, action *BlockNode, endL int) {
+	nextL := c.newLabel()
+	c.addOp(OpBegin)
+	c.addOp(OpDup)
+	c.addMatch(ptn)
+	c.addOpBranch(false, nextL)
+	if guard != nil {
+		// the guard refers to the bindings of the pattern
+		c.compile(guard)
+		c.addOpBranch(false, nextL)
+	}
+	c.addOpPop()
+	c.compile(action)
+	c.addOp(OpEnd)
+	c.addOpJump(endL)
+	c.addLabel(nextL)
+	c.addOp(OpEnd)
+}
+
+// compileTry compiles the block and the rescue clauses. The exception
+// is on the stack at the rescue label, and is raised again if no clauses match.
+func (c *codeComp) compileTry(node *TryStatNode) {
+	rescueL := c.newLabel()
+	endL := c.newLabel()
+	c.addOp(OpTry)
+	c.addOp(rescueL)
+	c.tries = append(c.tries, nil)
+	c.compile(node.Block)
+	c.tries = c.tries[:len(c.tries)-1]
+	c.addOp(OpEndTry)
+	c.addOpJump(endL)
+
+	c.addLabel(rescueL)
+	for _, clau := range node.Claus {
+		c.compileClause(clau.Ptn, nil, clau.Action, endL)
+	}
+	c.addOp(OpRaise)
+	c.addLabel(endL)
+}
+
+// compileEnsure runs the ensure block after the try statement ends
+// normally, or before the exception propagates.
+func (c *codeComp) compileEnsure(node *TryStatNode) {
+	ensureL := c.newLabel()
+	endL := c.newLabel()
+	c.addOp(OpTry)
+	c.addOp(ensureL)
+	c.tries = append(c.tries, node.EnsureAction)
+	c.compileTry(node)
+	c.tries = c.tries[:len(c.tries)-1]
+	c.addOp(OpEndTry)
+	c.compile(node.EnsureAction)
+	c.addOpPop()
+	c.addOpJump(endL)
+
+	c.addLabel(ensureL)
+	c.compile(node.EnsureAction)
+	c.addOpPop()
+	c.addOp(OpRaise)
+	c.addLabel(endL)
+}
+
+// compileUnwind leaves the enclosing try statements before returning
+// the value on the stack. The ensure blocks run from the innermost.
+func (c *codeComp) compileUnwind() {
+	tries := c.tries
+	for i := len(tries) - 1; i >= 0; i-- {
+		c.addOp(OpEndTry)
+		if ensure := tries[i]; ensure != nil {
+			// returns in the ensure block leave only the outer ones
+			c.tries = tries[:i]
+			c.compile(ensure)
+			c.addOpPop()
+		}
+	}
+	c.tries = tries
+}
+
 func (c *codeComp) code() *CompiledCode {
 	code := NewCompiledCode()
+	code.Params = c.params
 	code.Syms = c.syms
 	code.Lits = c.lits
 	code.Ops = c.ops
@@ -142,21 +290,22 @@ func (c *codeComp) compile(node Node) {
 		}
 		c.addOp(OpEnd)
 	case *LetStatNode:
-		c.compile(node.Ptn)
+		matchL := c.newLabel()
 		c.compile(node.Exp)
-		c.addOp(OpMatch)
-		c.addOp(OpPop)
+		c.addMatch(node.Ptn)
+		c.addOpBranch(true, matchL)
+		if node.ElseAction != nil {
+			c.compile(node.ElseAction)
+			c.addOpPop()
+		}
+		// raised if the else block does not leave
+		c.addOpPanic(OpPanicMatch)
+		c.addLabel(matchL)
+		c.addOp(OpLoadUnit)
 	case *DefStatNode:
-		defComp := c.newCodeComp()
-		defComp.params = node.Params.NameStrs()
-		defComp.compile(&node.Block)
-		c.addFun(node.Name.Text, defComp)
+		c.addFun(node.Name.Text, c.compileFun(node.Params, &node.Block))
 	case *ShortDefStatNode:
-		defComp := c.newCodeComp()
-		defComp.params = node.Params.NameStrs()
-		defComp.compile(node.Exp)
-		defComp.addOp(OpReturn)
-		c.addFun(node.Name.Text, defComp)
+		c.addFun(node.Name.Text, c.compileFun(node.Params, node.Exp))
 	case *IfStatNode:
 		endL := c.newLabel()
 		for _, cond := range node.Cond {
@@ -171,29 +320,33 @@ func (c *codeComp) compile(node Node) {
 		}
 		if node.Else != nil {
 			c.compile(node.ElseAction)
+		} else {
+			c.addOp(OpLoadUnit)
 		}
-		c.addOp(OpLabel)
 		c.addLabel(endL)
 	case *CaseStatNode:
 		endL := c.newLabel()
 		c.compile(node.Cond)
 		for _, clau := range node.Claus {
-			nextL := c.newLabel()
-			c.addOp(OpDup)
-			c.compile(clau.Ptn)
-			c.addOp(OpMatch)
-			c.addOp(OpBranchFalse)
-			c.addOp(nextL)
-			c.compile(clau.Action)
-			c.addOp(OpJump)
-			c.addOp(endL)
-			c.addLabel(nextL)
+			c.compileClause(clau.Ptn, clau.Guard, clau.Action, endL)
 		}
 		c.addOp(OpPop) // Cond
 		if node.Else != nil {
 			c.compile(node.ElseAction)
+		} else {
+			// never reached if the type checker accepts the clauses
+			c.addOpPanic(OpPanicMatch)
 		}
 		c.addLabel(endL)
+	case *TryStatNode:
+		if node.EnsureAction != nil {
+			c.compileEnsure(node)
+		} else {
+			c.compileTry(node)
+		}
+	case *RaiseExpNode:
+		c.compile(node.Exp)
+		c.addOp(OpRaise)
 	case *ForStatNode:
 		beginL := c.newLabel()
 		panicL := c.newLabel()
@@ -220,19 +373,44 @@ func (c *codeComp) compile(node Node) {
 		c.addOp(OpEnd)
 		c.addOpPop()
 	case *RetStatNode:
-		if node.Exp == nil {
+		if len(c.tries) > 0 {
+			if node.Exp == nil {
+				c.addOp(OpLoadUnit)
+			} else {
+				c.compile(node.Exp)
+			}
+			c.compileUnwind()
+			c.addOp(OpReturn)
+		} else if node.Exp == nil {
 			c.addOp(OpReturnUnit)
 		} else {
 			c.compile(node.Exp)
 			c.addOp(OpReturn)
 		}
+	case *ParenExpNode:
+		c.compile(node.Exp)
 	case *FunCallExpNode:
-		c.compile(node.Callable)
-		for _, arg := range node.Args.Elts {
-			c.compile(arg)
+		attr, ok := node.Callable.(*AttrExpNode)
+		isMethod := ok && c.comp.info.Methods[attr] != ""
+		if mask := node.Placeholders(); mask != 0 {
+			c.compilePartial(node, mask, isMethod)
+		} else if isMethod {
+			// the receiver is the first argument
+			c.compile(attr.Exp)
+			for _, arg := range node.Args.Elts {
+				c.compile(arg)
+			}
+			c.addOp(OpCallMethod)
+			c.addOp(c.addSym(attr.Name.Text))
+			c.addOp(len(node.Args.Elts) + 1)
+		} else {
+			c.compile(node.Callable)
+			for _, arg := range node.Args.Elts {
+				c.compile(arg)
+			}
+			c.addOp(OpCall)
+			c.addOp(len(node.Args.Elts))
 		}
-		c.addOp(OpCall)
-		c.addOp(len(node.Args.Elts))
 	case *CondOpExpNode:
 		falseL := c.newLabel()
 		endL := c.newLabel()
@@ -246,9 +424,12 @@ func (c *codeComp) compile(node Node) {
 		c.compile(node.False)
 		c.addLabel(endL)
 	case *VarExpNode:
-		i := c.addSym(node.Name.Text)
+		name := node.Name.Text
+		if impl := c.comp.info.Impls[node]; impl != "" {
+			name = MethodSym(name, impl)
+		}
 		c.addOp(OpLoadLocal)
-		c.addOp(i)
+		c.addOp(c.addSym(name))
 	case *UnitExpNode:
 		c.addOp(OpLoadUnit)
 	case *BoolExpNode:
@@ -258,16 +439,32 @@ func (c *codeComp) compile(node Node) {
 			c.addOp(OpLoadFalse)
 		}
 	case *IntExpNode:
-		val, err := strconv.Atoi(node.Value.Text)
+		val, err := StrToInt(node.Value.Text)
 		if err != nil {
-			panic(fmt.Sprintf("atoi failed: %s", err.Error()))
+			c.error(node.Loc(), "integer literal %s is out of range", node.Value.Text)
 		}
 		c.addOp(OpLoadInt)
 		c.addOp(val)
+	case *FloatExpNode:
+		val, err := StrToFloat(node.Value.Text)
+		if err != nil {
+			c.error(node.Loc(), "floating-point literal %s is out of range", node.Value.Text)
+		}
+		c.addOp(OpLoadLit)
+		c.addOp(c.addLit(NewFloat(val)))
 	case *StrExpNode:
 		i := c.addStr(node.Value.Text)
 		c.addOp(OpLoadLit)
 		c.addOp(i)
+	case *InterpExpNode:
+		for _, part := range node.Parts {
+			c.compile(part)
+			if _, ok := part.(*StrExpNode); !ok {
+				c.addOp(OpShow)
+			}
+		}
+		c.addOp(OpConcat)
+		c.addOp(len(node.Parts))
 	case *ListExpNode:
 		ln := len(node.Elts.Elts)
 		for _, elt := range node.Elts.Elts {
@@ -288,18 +485,91 @@ func (c *codeComp) compile(node Node) {
 	case *NoneExpNode:
 		c.addOp(OpLoadNone)
 	case *AnonFunExpNode:
-		anonComp := newCodeComp(c.comp)
-		for _, name := range node.Params.Names {
-			anonComp.addParam(name.Text)
-		}
+		body := &BlockNode{}
 		for _, stat := range node.Stats {
-			anonComp.compile(stat)
+			body.Stats = append(body.Stats, stat)
+		}
+		body.Stats = append(body.Stats, node.Exp)
+		c.addOpClosure(c.compileFun(node.Params, body))
+	case *StructDeclNode:
+		c.comp.structs[node.Name.Text] = fieldDeclNames(node.Fields)
+		c.addOp(OpLoadUnit)
+	case *FixityDeclNode:
+		c.addOp(OpLoadUnit)
+	case *TypeDeclNode:
+		for _, ctor := range node.Ctors {
+			name := ctor.Name.Text
+			val := NewCtorValue(node.Name.Text, name, len(ctor.Types))
+			c.addOp(OpLoadLit)
+			c.addOp(c.addLit(val))
+			c.addOp(OpStoreLocal)
+			c.addOp(c.addSym(name))
+			c.addOpPop()
 		}
-		anonComp.addOpPop()
-		anonComp.compile(node.Exp)
-		anonComp.addOp(OpReturn)
-		code := anonComp.code()
-		c.addLit(code)
+		c.addOp(OpLoadUnit)
+	case *AssignStatNode:
+		if attr, ok := node.Target.(*AttrExpNode); ok && !IsTyBox(c.comp.info.Types[attr]) {
+			c.compile(attr.Exp)
+			c.compile(node.Exp)
+			c.addOp(OpStoreAttr)
+			c.addOp(c.addSym(attr.Name.Text))
+		} else {
+			c.compile(node.Target)
+			c.compile(node.Exp)
+			c.addOp(OpStoreRef)
+		}
+	case *TraitDeclNode:
+		for _, sig := range node.Methods {
+			name := sig.Name.Text
+			method := NewMethod(node.Name.Text, name, len(sig.Params.Names))
+			c.addOp(OpLoadLit)
+			c.addOp(c.addLit(method))
+			c.addOp(OpStoreLocal)
+			c.addOp(c.addSym(name))
+			c.addOpPop()
+		}
+		c.addOp(OpLoadUnit)
+	case *ImplDeclNode:
+		var names []string
+		for _, def := range node.Defs {
+			var code *CompiledCode
+			switch def := def.(type) {
+			case *DefStatNode:
+				names = append(names, def.Name.Text)
+				code = c.compileFun(def.Params, &def.Block)
+			case *ShortDefStatNode:
+				names = append(names, def.Name.Text)
+				code = c.compileFun(def.Params, def.Exp)
+			}
+			c.addOpClosure(code)
+		}
+		impl := NewImplTemplate(node.Trait.Text, c.comp.info.ImplTypes[node], names)
+		c.addOp(OpImpl)
+		c.addOp(c.addLit(impl))
+	case *RecordExpNode:
+		name := c.comp.info.Structs[node]
+		if node.Name != nil {
+			name = node.Name.Text
+		}
+		// records are created with the fields in declaration order,
+		// and the field expressions are evaluated in that order too
+		fields := c.sortFields(name, node.Fields)
+		c.compileFields(fields)
+		c.addOp(OpRecord)
+		c.addOp(c.addLit(NewRecordTemplate(name, fieldNames(fields))))
+	case *UpdateExpNode:
+		c.compile(node.Exp)
+		c.compileFields(node.Fields)
+		c.addOp(OpUpdateRecord)
+		c.addOp(c.addLit(NewRecordTemplate("", fieldNames(node.Fields))))
+	case *AttrExpNode:
+		c.compile(node.Exp)
+		c.addOp(OpLoadAttr)
+		c.addOp(c.addSym(node.Name.Text))
+	case *IndexExpNode:
+		c.compile(node.Exp)
+		c.compile(node.Index)
+		c.addOp(OpIndex)
 	case *RangeExpNode:
 		c.compile(node.Left)
 		c.compile(node.Right)
@@ -308,14 +578,129 @@ func (c *codeComp) compile(node Node) {
 		} else {
 			c.addOp(OpHalfOpenRange)
 		}
+	case *BinaryExpNode:
+		if node.Fun != nil {
+			// a user-defined operator is a call of the function
+			c.compile(node.Fun)
+			c.compile(node.Left)
+			c.compile(node.Right)
+			c.addOp(OpCall)
+			c.addOp(2)
+		} else {
+			switch node.Op.Text {
+			case "|>":
+				c.compile(node.Left)
+				c.compile(node.Right)
+				c.addOp(OpPipe)
+			case "and", "or":
+				// short-circuit evaluation
+				endL := c.newLabel()
+				c.compile(node.Left)
+				c.addOp(OpDup)
+				c.addOpBranch(node.Op.Text == "or", endL)
+				c.addOpPop()
+				c.compile(node.Right)
+				c.addLabel(endL)
+			default:
+				c.compile(node.Left)
+				c.compile(node.Right)
+				c.addOp(binaryOps[node.Op.Text])
+			}
+		}
+	case *UnaryExpNode:
+		c.compile(node.Exp)
+		c.addOp(unaryOps[node.Op.Text])
 	default:
 		panic(fmt.Sprintf("unsupported node %s", NodeDesc(node)))
 	}
 }
 
-func Compile(path string, node Node) *CompiledCode {
-	comp := &compiler{path: path}
+func (c *codeComp) compileFields(fields []FieldNode) {
+	for _, field := range fields {
+		if field.Exp != nil {
+			c.compile(field.Exp)
+		} else {
+			c.addOp(OpLoadLocal)
+			c.addOp(c.addSym(field.Name.Text))
+		}
+	}
+}
+
+// compilePartial compiles the partial application. The placeholders
+// are not pushed. A method is loaded from the module of the type and
+// takes the receiver as the first argument.
+func (c *codeComp) compilePartial(node *FunCallExpNode, mask int, isMethod bool) {
+	n := len(node.Args.Elts)
+	if isMethod {
+		n++
+	}
+	// the placeholders are the bits of an int operand
+	if n > 62 {
+		c.error(node.Loc(), "too many arguments for partial application")
+		c.addOp(OpLoadUnit)
+		return
+	}
+	if isMethod {
+		attr := node.Callable.(*AttrExpNode)
+		c.addOp(OpLoadLocal)
+		c.addOp(c.addSym(c.comp.info.Methods[attr]))
+		c.addOp(OpLoadAttr)
+		c.addOp(c.addSym(attr.Name.Text))
+		c.compile(attr.Exp)
+		mask <<= 1
+	} else {
+		c.compile(node.Callable)
+	}
+	for _, arg := range node.Args.Elts {
+		if !IsPlaceholder(arg) {
+			c.compile(arg)
+		}
+	}
+	c.addOp(OpPartial)
+	c.addOp(n)
+	c.addOp(mask)
+}
+
+// sortFields returns the fields in declaration order of the struct.
+// The fields are left as they are if the struct is not declared in
+// the compiled code.
+func (c *codeComp) sortFields(name string, fields []FieldNode) []FieldNode {
+	decl, ok := c.comp.structs[name]
+	if !ok {
+		return fields
+	}
+	sorted := make([]FieldNode, 0, len(fields))
+	for _, declName := range decl {
+		for _, field := range fields {
+			if field.Name.Text == declName {
+				sorted = append(sorted, field)
+			}
+		}
+	}
+	return sorted
+}
+
+func fieldDeclNames(fields []FieldDeclNode) []string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name.Text
+	}
+	return names
+}
+
+func fieldNames(fields []FieldNode) []string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name.Text
+	}
+	return names
+}
+
+// Compile compiles the node with the result of type checking.
+// The code is invalid if any errors are reported.
+func Compile(path string, node Node, info *TypeInfo) (*CompiledCode, []Diagnostic) {
+	comp := &compiler{path: path, info: info, structs: make(map[string][]string)}
 	codeComp := newCodeComp(comp)
 	codeComp.compile(node)
-	return codeComp.code()
+	return codeComp.code(), comp.diags
 }
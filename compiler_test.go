@@ -0,0 +1,64 @@
+package trompe
+
+import (
+	"testing"
+)
+
+func TestCompileErrors(t *testing.T) {
+	Init()
+	cases := []Node{
+		in("99999999999999999999999"),
+		fl("1e999"),
+	}
+	for _, node := range cases {
+		if _, diags := Compile("test", blk(node), &TypeInfo{}); len(diags) != 1 {
+			t.Errorf("%s: got %v", NodeDesc(node), diags)
+		}
+	}
+
+	args := make([]Node, 64)
+	names := make([]string, 64)
+	for i := range args {
+		args[i] = vr("_")
+		names[i] = "x"
+	}
+	if _, diags := Compile("test", blk(call(anon(names, unit()), args...)), &TypeInfo{}); len(diags) != 1 {
+		t.Errorf("partial: got %v", diags)
+	}
+}
+
+func TestRecordFieldOrder(t *testing.T) {
+	v, err := exec(t, pointDecl(), record("", field("y", in("2")), field("x", in("1"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := ValueToRecord(v); !ok || r.Fields[0] != "x" || r.Values[0].Desc() != "1" {
+		t.Errorf("got %v", v)
+	}
+}
+
+func TestAttrs(t *testing.T) {
+	mk := anon(nil, point("1", "2"))
+	eval(t, "2", pointDecl(), attr(call(mk), "y"))
+	eval(t, "1", pointDecl(), attr(idx(lst(point("1", "2")), in("0")), "x"))
+	eval(t, "1", pointDecl(), attr(&ParenExpNode{Exp: point("1", "2")}, "x"))
+}
+
+func TestFieldAssignment(t *testing.T) {
+	set := func(target, e Node) *AssignStatNode { return &AssignStatNode{Target: target, Exp: e} }
+	eval(t, "(5, 2)", pointDecl(), let(vp("p"), point("1", "2")),
+		set(attr(vr("p"), "x"), in("5")),
+		tupe(attr(vr("p"), "x"), attr(vr("p"), "y")))
+	// the record is shared
+	eval(t, "7", pointDecl(), let(vp("p"), point("1", "2")), let(vp("q"), vr("p")),
+		set(attr(vr("q"), "y"), in("7")), attr(vr("p"), "y"))
+	// a field holding a box is updated through the box
+	cell := &StructDeclNode{Name: tok("cell"),
+		Fields: []FieldDeclNode{{Name: tok("v"), Type: named("box", named("int"))}}}
+	eval(t, "3", cell, let(vp("c"), record("cell", field("v", call(vr("box"), in("0"))))),
+		set(attr(vr("c"), "v"), in("3")), call(vr("unbox"), attr(vr("c"), "v")))
+
+	check(t, 1, pointDecl(), let(vp("p"), point("1", "2")), set(attr(vr("p"), "x"), st("a")))
+	check(t, 1, pointDecl(), let(vp("p"), point("1", "2")), set(attr(vr("p"), "z"), in("1")))
+	check(t, 1, set(attr(vr("String"), "length"), in("1")))
+}
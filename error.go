@@ -6,6 +6,8 @@ const (
 	GenericError = iota
 	InvalidArityError
 	KeyError
+	IndexError
+	MatchError
 )
 
 type RuntimeError struct {
@@ -20,6 +22,12 @@ func ErrorName(ty int) string {
 		return "GenericError"
 	case InvalidArityError:
 		return "InvalidArityError"
+	case KeyError:
+		return "KeyError"
+	case IndexError:
+		return "IndexError"
+	case MatchError:
+		return "MatchError"
 	default:
 		panic("unknown error")
 	}
@@ -43,7 +51,7 @@ func NewKeyError(ctx *Context, name string) *RuntimeError {
 }
 
 func ValidateArity(ctx *Context, expected int, actual int) *RuntimeError {
-	if expected != actual {
+	if expected != actual && actual != VariadicArity {
 		return NewRuntimeError(ctx,
 			InvalidArityError,
 			fmt.Sprintf("invalid arity (takes %d, but %d given)", expected, actual))
@@ -51,3 +59,29 @@ func ValidateArity(ctx *Context, expected int, actual int) *RuntimeError {
 		return nil
 	}
 }
+
+// Diagnostic is an error in a source file reported before running.
+type Diagnostic struct {
+	File    string
+	Loc     Loc
+	Message string
+}
+
+func NewDiagnostic(file string, loc Loc, msg string) Diagnostic {
+	return Diagnostic{File: file, Loc: loc, Message: msg}
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.File,
+		d.Loc.Start.Line, d.Loc.Start.Col, d.Message)
+}
+
+// PrintDiagnostics prints the diagnostics followed by their source lines.
+func PrintDiagnostics(diags []Diagnostic) {
+	for _, diag := range diags {
+		fmt.Printf("Error: %s\n", diag.Error())
+		if line, ok := SourceLine(diag.Loc); ok {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+}
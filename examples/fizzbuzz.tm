@@ -7,6 +7,6 @@ def fizzbuzz(i)
   end
 end
 
-for i in 1..15 do
+for i in 1...15 do
   show(fizzbuzz(i))
 end
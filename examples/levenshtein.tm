@@ -1,13 +1,13 @@
 def levenshtein(s: String, t: String)
   def dist(i, j)
-    case (i, j) do
-    | (i, 0) -> i
-    | (0, j) -> j
-    | (i, j) ->
-      if s[i-1] == t[i-1] then
-        dist(i-1, j-1)
+    case (i, j) of
+    when (i, 0) then i
+    when (0, j) then j
+    when (i, j) then
+      if s[i - 1] == t[j - 1] then
+        dist(i - 1, j - 1)
       else
-        let (d1, d2, d3) = (dist(i-1, j), dist(i, j-1), dist(i-1, j-1))
+        let (d1, d2, d3) = (dist(i - 1, j), dist(i, j - 1), dist(i - 1, j - 1))
         1 + min(d1, min(d2, d3))
       end
     end
@@ -19,7 +19,7 @@ def test(s, t)
   printf("%s -> %s = %d\n", s, t, levenshtein(s, t))
 end
 
-# kitten -> sitting = 3
-# rosettacode -> raisethysword = 8
+-- kitten -> sitting = 3
+-- rosettacode -> raisethysword = 8
 test("kitten", "sitting")
 test("rosettacode", "raisethysword")
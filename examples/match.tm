@@ -1,45 +1,58 @@
-let match_bool x =
-  match x with
-  | true -> "bool: 1"
-  | false -> "bool: 2"
+def match_bool(x)
+  case x of
+  when true then "bool: 1"
+  when false then "bool: 2"
+  end
+end
 
-let match_int x =
-  match x with
-  | 0 -> "int: 1"
-  | 1 -> "int: 2"
-  | _ -> "int: 3"
+def match_int(x)
+  case x of
+  when 0 then "int: 1"
+  when 1 then "int: 2"
+  when _ then "int: 3"
+  end
+end
 
-let match_string x =
-  match x with
-  | "" -> "string: 1"
-  | "hello" -> "string: 2"
-  | s -> "string: 3"
+def match_string(x)
+  case x of
+  when "" then "string: 1"
+  when "hello" then "string: 2"
+  when s then "string: 3"
+  end
+end
 
-let match_tuple x =
-  match x with
-  | (true, true) -> "tuple 1"
-  | (true, false) -> "tuple 2"
-  | (false, true) -> "tuple 3"
-  | (false, false) -> "tuple 4"
+def match_tuple(x)
+  case x of
+  when (true, true) then "tuple 1"
+  when (true, false) then "tuple 2"
+  when (false, true) then "tuple 3"
+  when (false, false) then "tuple 4"
+  end
+end
 
-let match_list x =
-  match x with
-  | [] -> "list: 1"
-  | [1; 2; 3] -> "list: 2"
-  | hd :: tl -> "list: 3"
+def match_list(x)
+  case x of
+  when [] then "list: 1"
+  when [1, 2, 3] then "list: 2"
+  when [x] then "list: 3"
+  when hd :: tl then "list: 4"
+  end
+end
 
-;; show $ match_bool true
-;; show $ match_bool false
-;; show $ match_int 0
-;; show $ match_int 1
-;; show $ match_int 2
-;; show $ match_string ""
-;; show $ match_string "hello"
-;; show $ match_string "world"
-;; show $ match_list []
-;; show $ match_list [1; 2; 3]
-;; show $ match_list [1; 2; 3; 4; 5]
-;; show $ match_tuple (true, true)
-;; show $ match_tuple (true, false)
-;; show $ match_tuple (false, true)
-;; show $ match_tuple (false, false)
+show(match_bool(true))
+show(match_bool(false))
+show(match_int(0))
+show(match_int(1))
+show(match_int(2))
+show(match_string(""))
+show(match_string("hello"))
+show(match_string("world"))
+show(match_list([]))
+show(match_list([1, 2, 3]))
+show(match_list([1, 2, 3, 4, 5]))
+show(match_list([9]))
+show(match_list(0 :: [1, 2, 3]))
+show(match_tuple((true, true)))
+show(match_tuple((true, false)))
+show(match_tuple((false, true)))
+show(match_tuple((false, false)))
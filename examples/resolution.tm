@@ -28,5 +28,8 @@ let video_mode = {
   name = box(none)
 }
 
-video_mode.name <- "noninterlaced video"
-printf("video mode name: %s\n", unbox(video_mode.name))
+video_mode.name <- some("noninterlaced video")
+case unbox(video_mode.name) of
+when some(name) then printf("video mode name: %s\n", name)
+when none then printf("video mode has no name\n")
+end
@@ -1,3 +1,3 @@
-let square x = x * x
+def square(x) = x * x
 
-;; print_int $ square 3
+show(square(3))
@@ -1,6 +1,9 @@
-let rec sum l =
-  match l with
-  | [] -> 0
-  | hd :: tl -> hd + sum tl
+def sum(l)
+  let total = box(0)
+  for x in l do
+    total <- unbox(total) + x
+  end
+  unbox(total)
+end
 
-;; print_int $ sum [1; 2; 3; 4; 5; 6; 7; 8; 9; 10]
+show(sum([1, 2, 3, 4, 5, 6, 7, 8, 9, 10]))
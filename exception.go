@@ -0,0 +1,61 @@
+package trompe
+
+import "fmt"
+
+// Exceptions are variants of the library type:
+//
+//	type exn = Error(string) | ArityError(string) | KeyError(string)
+//	         | IndexError(string) | MatchError(string)
+//
+// Each constructor corresponds to the type of runtime errors.
+// The type is closed; programs cannot declare another type named exn.
+const ExnTypeName = "exn"
+
+var exnTags = []string{
+	GenericError:      "Error",
+	InvalidArityError: "ArityError",
+	KeyError:          "KeyError",
+	IndexError:        "IndexError",
+	MatchError:        "MatchError",
+}
+
+// ExnTags returns the constructor names of exceptions.
+func ExnTags() []string {
+	return exnTags
+}
+
+// Exn returns the exception value of the runtime error.
+func (err *RuntimeError) Exn() *Variant {
+	return NewVariant(ExnTypeName, exnTags[err.Type], []Value{NewString(err.Reason)})
+}
+
+// ErrorExn returns the exception value of the error.
+// Errors other than runtime errors are generic errors.
+func ErrorExn(ctx *Context, err error) *Variant {
+	if rterr, ok := err.(*RuntimeError); ok {
+		return rterr.Exn()
+	}
+	return NewRuntimeError(ctx, GenericError, err.Error()).Exn()
+}
+
+// NewExnError returns the runtime error of the raised exception value.
+func NewExnError(ctx *Context, v Value) *RuntimeError {
+	if exn, ok := ValueToVariant(v); ok && exn.TypeName == ExnTypeName {
+		for ty, tag := range exnTags {
+			if tag == exn.Tag {
+				reason, _ := ValueToString(exn.Values[0])
+				return NewRuntimeError(ctx, ty, reason.Value)
+			}
+		}
+	}
+	panic(fmt.Sprintf("not exception %s", v.Desc()))
+}
+
+// handler is a rescue point of the try statement in a frame.
+// Raising an exception unwinds the stack and the environment
+// to the state at the beginning of the try statement.
+type handler struct {
+	label int
+	index int // stack index
+	env   *Env
+}
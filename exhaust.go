@@ -0,0 +1,436 @@
+package trompe
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Exhaustiveness and redundancy checking of pattern matching.
+// See Luc Maranget, "Warnings for pattern matching" (2007).
+
+// xpat is a pattern reduced to constructors and wildcards.
+type xpat struct {
+	ctor   string // empty if wildcard
+	args   []*xpat
+	fields []string // field names of records
+	alts   []*xpat  // alternatives of or-patterns
+}
+
+var xwild = &xpat{}
+
+const (
+	xctorUnit  = "()"
+	xctorTuple = "(,)"
+	xctorNil   = "[]"
+	xctorCons  = "::"
+	xctorTrue  = "true"
+	xctorFalse = "false"
+)
+
+// xctor is a constructor of a type.
+type xctor struct {
+	name   string
+	arity  int
+	fields []string
+}
+
+func (p *xpat) isWild() bool {
+	return p.ctor == "" && p.alts == nil
+}
+
+func (p *xpat) Desc() string {
+	switch {
+	case p.isWild():
+		return "_"
+	case p.alts != nil:
+		descs := make([]string, len(p.alts))
+		for i, alt := range p.alts {
+			descs[i] = alt.Desc()
+		}
+		return strings.Join(descs, " | ")
+	case p.ctor == xctorTuple:
+		return "(" + xpatsDesc(p.args) + ")"
+	case p.ctor == xctorNil:
+		return "[]"
+	case p.ctor == xctorCons:
+		var elts []*xpat
+		cur := p
+		for ; cur.ctor == xctorCons; cur = cur.args[1] {
+			elts = append(elts, cur.args[0])
+		}
+		if cur.ctor == xctorNil {
+			return "[" + xpatsDesc(elts) + "]"
+		}
+		return fmt.Sprintf("%s :: %s", p.args[0].Desc(), p.args[1].Desc())
+	case p.fields != nil:
+		fields := make([]string, len(p.fields))
+		for i, field := range p.fields {
+			fields[i] = fmt.Sprintf("%s = %s", field, p.args[i].Desc())
+		}
+		return "{ " + strings.Join(fields, ", ") + " }"
+	case len(p.args) > 0:
+		return variantTagDesc(OptionTypeName, p.ctor) + "(" + xpatsDesc(p.args) + ")"
+	default:
+		// constructor names are unique, so the option tags are of options
+		return variantTagDesc(OptionTypeName, p.ctor)
+	}
+}
+
+func xpatsDesc(ps []*xpat) string {
+	descs := make([]string, len(ps))
+	for i, p := range ps {
+		descs[i] = p.Desc()
+	}
+	return strings.Join(descs, ", ")
+}
+
+func xwilds(n int) []*xpat {
+	ps := make([]*xpat, n)
+	for i := range ps {
+		ps[i] = xwild
+	}
+	return ps
+}
+
+// xctors returns all constructors of the type,
+// or nil if the values of the type cannot be enumerated.
+func (t *typer) xctors(ty Type) []xctor {
+	switch ty := PruneType(ty).(type) {
+	case *TyTuple:
+		if len(ty.Elts) == 0 {
+			return []xctor{{name: xctorUnit}}
+		}
+		return []xctor{{name: xctorTuple, arity: len(ty.Elts)}}
+	case *TyCon:
+		switch ty.Name {
+		case TyUnit.Name:
+			return []xctor{{name: xctorUnit}}
+		case TyBool.Name:
+			return []xctor{{name: xctorTrue}, {name: xctorFalse}}
+		case "list":
+			return []xctor{{name: xctorNil}, {name: xctorCons, arity: 2}}
+		}
+		if v, ok := t.variants[ty.Name]; ok {
+			ctors := make([]xctor, len(v.ctors))
+			for i, ctor := range v.ctors {
+				ctors[i] = xctor{name: ctor.name, arity: len(ctor.types)}
+			}
+			return ctors
+		}
+		if st, ok := t.structs[ty.Name]; ok {
+			return []xctor{{name: "{" + st.name + "}", arity: len(st.fields), fields: st.fields}}
+		}
+	}
+	return nil
+}
+
+// xargTypes returns the argument types of the constructor of the type.
+func (t *typer) xargTypes(ty Type, name string) []Type {
+	switch ty := PruneType(ty).(type) {
+	case *TyTuple:
+		return ty.Elts
+	case *TyCon:
+		switch name {
+		case xctorCons:
+			return []Type{ty.Args[0], ty}
+		case xctorNil, xctorUnit, xctorTrue, xctorFalse:
+			return nil
+		}
+		if st, ok := t.structs[ty.Name]; ok && name == "{"+st.name+"}" {
+			return st.types
+		}
+		if ctor, ok := t.ctors[name]; ok && len(ctor.types) > 0 {
+			fun := t.instantiate(ctor.scheme()).(*TyFun)
+			t.unify(fun.Ret, ty)
+			return fun.Params
+		}
+	}
+	return nil
+}
+
+// xpat reduces the pattern matching values of the type.
+func (t *typer) xpat(node PtnNode, ty Type) *xpat {
+	switch node := node.(type) {
+	case *VarPtnNode:
+		return xwild
+	case *AsPtnNode:
+		return t.xpat(node.Ptn, ty)
+	case *OrPtnNode:
+		return &xpat{alts: []*xpat{t.xpat(node.Left, ty), t.xpat(node.Right, ty)}}
+	case *UnitPtnNode:
+		return &xpat{ctor: xctorUnit}
+	case *BoolPtnNode:
+		if node.Value {
+			return &xpat{ctor: xctorTrue}
+		}
+		return &xpat{ctor: xctorFalse}
+	case *IntPtnNode:
+		if n, err := StrToInt(node.Value.Text); err == nil {
+			return &xpat{ctor: strconv.Itoa(n)}
+		}
+		return &xpat{ctor: node.Value.Text}
+	case *FloatPtnNode:
+		if f, err := StrToFloat(node.Value.Text); err == nil {
+			return &xpat{ctor: strconv.FormatFloat(f, 'g', -1, 64)}
+		}
+		return &xpat{ctor: node.Value.Text}
+	case *StrPtnNode:
+		return &xpat{ctor: node.Value.Text}
+	case *TuplePtnNode:
+		if len(node.Elts.Elts) == 0 {
+			return &xpat{ctor: xctorUnit}
+		}
+		return &xpat{ctor: xctorTuple, args: t.xpats(node.Elts.Elts, t.xargTypes(ty, xctorTuple))}
+	case *ListPtnNode:
+		p := &xpat{ctor: xctorNil}
+		argTys := t.xargTypes(ty, xctorCons)
+		for i := len(node.Elts.Elts) - 1; i >= 0; i-- {
+			p = &xpat{ctor: xctorCons, args: []*xpat{t.xpat(node.Elts.Elts[i], argTys[0]), p}}
+		}
+		return p
+	case *ConsPtnNode:
+		argTys := t.xargTypes(ty, xctorCons)
+		return &xpat{ctor: xctorCons, args: []*xpat{
+			t.xpat(node.Left, argTys[0]), t.xpat(node.Right, argTys[1])}}
+	case *SomePtnNode:
+		return &xpat{ctor: OptionSomeTag,
+			args: t.xpats([]PtnNode{node.Value}, t.xargTypes(ty, OptionSomeTag))}
+	case *NonePtnNode:
+		return &xpat{ctor: OptionNoneTag}
+	case *CtorPtnNode:
+		var args []PtnNode
+		if node.Args != nil {
+			args = node.Args.Elts
+		}
+		return &xpat{ctor: node.Name.Text, args: t.xpats(args, t.xargTypes(ty, node.Name.Text))}
+	case *RecordPtnNode:
+		ctors := t.xctors(ty)
+		if len(ctors) != 1 || ctors[0].fields == nil {
+			break
+		}
+		ctor := ctors[0]
+		argTys := t.xargTypes(ty, ctor.name)
+		p := &xpat{ctor: ctor.name, args: xwilds(ctor.arity), fields: ctor.fields}
+		for _, field := range node.Fields {
+			for i, name := range ctor.fields {
+				if name == field.Name.Text && field.Ptn != nil {
+					p.args[i] = t.xpat(field.Ptn, argTys[i])
+				}
+			}
+		}
+		return p
+	}
+
+	// a constructor that matches nothing but itself
+	return &xpat{ctor: fmt.Sprintf("<%p>", node)}
+}
+
+func (t *typer) xpats(nodes []PtnNode, tys []Type) []*xpat {
+	ps := make([]*xpat, len(nodes))
+	for i, node := range nodes {
+		var ty Type = t.newVar()
+		if i < len(tys) {
+			ty = tys[i]
+		}
+		ps[i] = t.xpat(node, ty)
+	}
+	return ps
+}
+
+// xexpand splits the rows whose first pattern is an or-pattern
+// into the rows of the alternatives.
+func xexpand(rows [][]*xpat) [][]*xpat {
+	var res [][]*xpat
+	for _, row := range rows {
+		if row[0].alts == nil {
+			res = append(res, row)
+			continue
+		}
+		for _, alt := range row[0].alts {
+			res = append(res, xexpand([][]*xpat{append([]*xpat{alt}, row[1:]...)})...)
+		}
+	}
+	return res
+}
+
+// xspecialize keeps the rows whose first pattern matches the constructor
+// and expands the arguments.
+func xspecialize(rows [][]*xpat, ctor xctor) [][]*xpat {
+	var res [][]*xpat
+	for _, row := range xexpand(rows) {
+		if row[0].isWild() {
+			res = append(res, append(xwilds(ctor.arity), row[1:]...))
+		} else if row[0].ctor == ctor.name {
+			res = append(res, append(append([]*xpat{}, row[0].args...), row[1:]...))
+		}
+	}
+	return res
+}
+
+// xdefault keeps the rows whose first pattern is a wildcard.
+func xdefault(rows [][]*xpat) [][]*xpat {
+	var res [][]*xpat
+	for _, row := range xexpand(rows) {
+		if row[0].isWild() {
+			res = append(res, row[1:])
+		}
+	}
+	return res
+}
+
+// xheads returns the constructors in the first column.
+func xheads(rows [][]*xpat) map[string]*xpat {
+	heads := make(map[string]*xpat, len(rows))
+	for _, row := range xexpand(rows) {
+		if !row[0].isWild() {
+			heads[row[0].ctor] = row[0]
+		}
+	}
+	return heads
+}
+
+func (t *typer) xcomplete(ty Type, heads map[string]*xpat) ([]xctor, bool) {
+	ctors := t.xctors(ty)
+	if ctors == nil || len(heads) == 0 {
+		return ctors, false
+	}
+	for _, ctor := range ctors {
+		if heads[ctor.name] == nil {
+			return ctors, false
+		}
+	}
+	return ctors, true
+}
+
+// xuseful reports whether the row matches some values
+// that no rows of the matrix match.
+func (t *typer) xuseful(rows [][]*xpat, row []*xpat, tys []Type) bool {
+	if len(row) == 0 {
+		return len(rows) == 0
+	}
+	if row[0].alts != nil {
+		for _, alt := range row[0].alts {
+			if t.xuseful(rows, append([]*xpat{alt}, row[1:]...), tys) {
+				return true
+			}
+		}
+		return false
+	}
+	if !row[0].isWild() {
+		ctor := xctor{name: row[0].ctor, arity: len(row[0].args), fields: row[0].fields}
+		argTys := t.xargTypes(tys[0], ctor.name)
+		return t.xuseful(xspecialize(rows, ctor),
+			xspecialize([][]*xpat{row}, ctor)[0],
+			append(t.xfillTypes(argTys, ctor.arity), tys[1:]...))
+	}
+	ctors, complete := t.xcomplete(tys[0], xheads(rows))
+	if !complete {
+		return t.xuseful(xdefault(rows), row[1:], tys[1:])
+	}
+	for _, ctor := range ctors {
+		argTys := t.xargTypes(tys[0], ctor.name)
+		if t.xuseful(xspecialize(rows, ctor),
+			xspecialize([][]*xpat{row}, ctor)[0],
+			append(t.xfillTypes(argTys, ctor.arity), tys[1:]...)) {
+			return true
+		}
+	}
+	return false
+}
+
+// xmissing returns a row of patterns matching values that no rows of
+// the matrix match, or nil if the matrix is exhaustive.
+func (t *typer) xmissing(rows [][]*xpat, tys []Type) []*xpat {
+	if len(tys) == 0 {
+		if len(rows) == 0 {
+			return []*xpat{}
+		}
+		return nil
+	}
+	heads := xheads(rows)
+	ctors, complete := t.xcomplete(tys[0], heads)
+	if complete {
+		for _, ctor := range ctors {
+			argTys := t.xargTypes(tys[0], ctor.name)
+			rest := t.xmissing(xspecialize(rows, ctor),
+				append(t.xfillTypes(argTys, ctor.arity), tys[1:]...))
+			if rest != nil {
+				p := &xpat{ctor: ctor.name, args: rest[:ctor.arity], fields: ctor.fields}
+				return append([]*xpat{p}, rest[ctor.arity:]...)
+			}
+		}
+		return nil
+	}
+
+	rest := t.xmissing(xdefault(rows), tys[1:])
+	if rest == nil {
+		return nil
+	}
+	p := xwild
+	if len(heads) > 0 {
+		for _, ctor := range ctors {
+			if heads[ctor.name] == nil {
+				p = &xpat{ctor: ctor.name, args: xwilds(ctor.arity), fields: ctor.fields}
+				break
+			}
+		}
+		if con, ok := PruneType(tys[0]).(*TyCon); ok && con.Name == TyInt.Name {
+			for n := 0; ; n++ {
+				if heads[strconv.Itoa(n)] == nil {
+					p = &xpat{ctor: strconv.Itoa(n)}
+					break
+				}
+			}
+		}
+	}
+	return append([]*xpat{p}, rest...)
+}
+
+func (t *typer) xfillTypes(tys []Type, n int) []Type {
+	filled := make([]Type, n)
+	for i := range filled {
+		if i < len(tys) {
+			filled[i] = tys[i]
+		} else {
+			filled[i] = t.newVar()
+		}
+	}
+	return filled
+}
+
+// checkLet reports the refutable pattern of the let binding without else.
+func (t *typer) checkLet(node *LetStatNode, ty Type) {
+	if node.ElseAction != nil {
+		return
+	}
+	rows := [][]*xpat{{t.xpat(node.Ptn, ty)}}
+	if missing := t.xmissing(rows, []Type{ty}); missing != nil {
+		t.error(node.Ptn.Loc(), "this pattern is refutable; for example, %s is not matched. "+
+			"Use let ... else to handle it", missing[0].Desc())
+	}
+}
+
+// checkCase reports unreachable clauses and values not matched by
+// any clauses of the case statement.
+func (t *typer) checkCase(node *CaseStatNode, condTy Type) {
+	var rows [][]*xpat
+	tys := []Type{condTy}
+	for _, clau := range node.Claus {
+		row := []*xpat{t.xpat(clau.Ptn, condTy)}
+		if !t.xuseful(rows, row, tys) {
+			t.error(clau.Ptn.Loc(), "this clause is unreachable")
+		}
+		// guarded clauses may not match any values
+		if clau.Guard == nil {
+			rows = append(rows, row)
+		}
+	}
+	if node.Else != nil {
+		return
+	}
+	if missing := t.xmissing(rows, tys); missing != nil {
+		t.error(&node.Case, "this pattern matching is not exhaustive; for example, %s is not matched",
+			missing[0].Desc())
+	}
+}
@@ -0,0 +1,60 @@
+package trompe
+
+import (
+	"strings"
+	"testing"
+)
+
+// checkMessage type checks the statements and expects an error
+// containing the message.
+func checkMessage(t *testing.T, msg string, stats ...Node) {
+	t.Helper()
+	Init()
+	_, errs := TypeCheck("test", chunk(stats...))
+	for _, err := range errs {
+		if strings.Contains(err.Error(), msg) {
+			return
+		}
+	}
+	t.Errorf("no error %q in %v", msg, errs)
+}
+
+func TestExhaustiveness(t *testing.T) {
+	cases := []struct {
+		example string
+		stat    Node
+	}{
+		{"none", caseOf(some(in("3")), clau(somep(vp("x")), nil, vr("x")))},
+		{"some(_)", caseOf(some(in("3")), clau(nonep(), nil, in("0")))},
+		{"2", caseOf(in("3"), clau(ip("0"), nil, in("0")), clau(ip("1"), nil, in("0")))},
+		{"(false, false)", caseOf(tupe(bl(true), bl(false)),
+			clau(tup(bp(true), vp("_")), nil, in("0")),
+			clau(tup(vp("_"), bp(true)), nil, in("0")))},
+		{"[]", caseOf(lst(in("1")), clau(cons(vp("h"), vp("t")), nil, in("1")))},
+		{"_ :: _ :: _", caseOf(lst(in("1")), clau(orp(lptn(), lptn(vp("_"))), nil, in("1")))},
+		{"_", caseOf(in("1"), clau(vp("n"), bin(vr("n"), "<", in("0")), st("neg")))},
+	}
+	for _, c := range cases {
+		t.Run(c.example, func(t *testing.T) {
+			checkMessage(t, "for example, "+c.example+" is not matched", c.stat)
+		})
+	}
+}
+
+func TestExhaustive(t *testing.T) {
+	check(t, 0, caseOf(some(in("3")),
+		clau(nonep(), nil, in("0")), clau(somep(vp("x")), nil, vr("x"))))
+	check(t, 0, caseOf(bl(true), clau(orp(bp(true), bp(false)), nil, in("1"))))
+	check(t, 0, caseOf(lst(in("1")),
+		clau(lptn(), nil, in("0")), clau(cons(vp("h"), vp("t")), nil, vr("h"))))
+}
+
+func TestUnreachable(t *testing.T) {
+	checkMessage(t, "unreachable", caseOf(some(in("3")),
+		clau(nonep(), nil, in("0")), clau(somep(vp("x")), nil, vr("x")), clau(vp("_"), nil, in("1"))))
+	checkMessage(t, "unreachable", caseOf(bl(true),
+		clau(orp(bp(true), bp(false)), nil, in("1")), clau(bp(true), nil, in("2"))))
+	// a guarded clause after a catch-all
+	checkMessage(t, "unreachable", caseOf(in("1"),
+		clau(vp("_"), nil, st("y")), clau(vp("n"), bin(vr("n"), "<", in("0")), st("neg"))))
+}
@@ -0,0 +1,34 @@
+package trompe
+
+import (
+	"fmt"
+)
+
+// Fun is a function defined with def or an anonymous function.
+// The body is evaluated in a child of the environment where
+// the function is created.
+type Fun struct {
+	Code *CompiledCode
+	Env  *Env
+}
+
+func NewFun(code *CompiledCode, env *Env) *Fun {
+	return &Fun{Code: code, Env: env}
+}
+
+func (f *Fun) Type() int {
+	return ValueTypeClos
+}
+
+func (f *Fun) Desc() string {
+	return fmt.Sprintf("<fun %p>", f)
+}
+
+func (f *Fun) Arity() int {
+	return f.Code.Arity()
+}
+
+// Apply ignores the environment of the caller.
+func (f *Fun) Apply(ip *Interp, ctx *Context, env *Env) (Value, error) {
+	return ip.Eval(ctx, NewEnv(f.Env), f.Code)
+}
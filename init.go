@@ -13,5 +13,11 @@ func Init() {
 
 func InstallModules() {
 	RootModule = NewModule(nil, "")
+	OpenedModules = nil
+	impls = nil
 	InstallLibCore()
+	InstallLibString()
+	InstallLibList()
+	InstallLibInt()
+	InstallLibFloat()
 }
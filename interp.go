@@ -1,9 +1,14 @@
 package trompe
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"strings"
+)
 
 type Context struct {
 	Parent  *Context
+	Interp  *Interp
 	Module  *Module
 	Clos    Closure
 	Args    []Value
@@ -15,8 +20,13 @@ func NewContext(parent *Context,
 	clos Closure,
 	args []Value,
 	numArgs int) Context {
+	var ip *Interp
+	if parent != nil {
+		ip = parent.Interp
+	}
 	return Context{
 		Parent:  parent,
+		Interp:  ip,
 		Module:  module,
 		Clos:    clos,
 		Args:    args,
@@ -53,7 +63,7 @@ func (s *Stack) Set(i int, value Value) {
 
 func (s *Stack) Push(value Value) {
 	s.Index++
-	if len(s.Locals) < s.Index {
+	if len(s.Locals) <= s.Index {
 		s.Locals = append(s.Locals, value)
 	} else {
 		s.Locals[s.Index] = value
@@ -126,7 +136,7 @@ func (pc *ProgCounter) Jump(n int) {
 		pc.dest = n
 		return
 	}
-	fmt.Printf("jump %p\n", pc.Labels[n])
+	fmt.Printf("jump %d\n", pc.Labels[n])
 	pc.Count = pc.Labels[n]
 }
 
@@ -142,6 +152,7 @@ func Run(file string, code *CompiledCode) (Value, error) {
 	m := NewModule(nil, file)
 	ctx := NewContext(nil, m, code, nil, 0)
 	ip := NewInterp(m)
+	ctx.Interp = ip
 	return ip.Eval(&ctx, NewEnv(m.Env), code)
 }
 
@@ -154,7 +165,7 @@ func (ip *Interp) Eval(ctx *Context, env *Env, code *CompiledCode) (Value, error
 	pc := NewProgCounter(code)
 	cont := true
 	stack := NewStack(16)
-	args := make([]Value, 16)
+	var handlers []handler
 	for cont && pc.HasNext() {
 		op = pc.Next()
 		fmt.Printf("%d: next op: %s\n", pc.Count, GetOpName(op))
@@ -184,6 +195,10 @@ func (ip *Interp) Eval(ctx *Context, env *Env, code *CompiledCode) (Value, error
 			if !pc.isSkip {
 				stack.Push(NewInt(i))
 			}
+		case OpLoadNone:
+			if !pc.isSkip {
+				stack.Push(SharedNone)
+			}
 		case OpLoadLit:
 			i = pc.Next()
 			if !pc.isSkip {
@@ -195,9 +210,13 @@ func (ip *Interp) Eval(ctx *Context, env *Env, code *CompiledCode) (Value, error
 				name := code.Syms[i]
 				fmt.Printf("-- load local %s\n", name)
 				value := env.Get(name)
+				if value == nil && RootModule != nil {
+					if m := GetModule(name); m != nil {
+						value = NewRef(m.Path(), m)
+					}
+				}
 				if value == nil {
 					err = NewKeyError(ctx, name)
-					panic(err.Error())
 					break
 				}
 				stack.Push(value)
@@ -207,9 +226,13 @@ func (ip *Interp) Eval(ctx *Context, env *Env, code *CompiledCode) (Value, error
 			if !pc.isSkip {
 				name := code.Syms[i]
 				top := stack.TopPop()
-				ref, _ := ValueToRef(top)
-				m := ref.Module()
-				attr := m.Env.Get(name)
+				var attr Value
+				switch top := top.(type) {
+				case *Ref:
+					attr = top.Module().Env.Get(name)
+				case *Record:
+					attr = top.Get(name)
+				}
 				if attr == nil {
 					err = NewKeyError(ctx, name)
 					break
@@ -220,19 +243,43 @@ func (ip *Interp) Eval(ctx *Context, env *Env, code *CompiledCode) (Value, error
 			if !pc.isSkip {
 				stack.Push(NewRef(ctx.Module.Path(), ctx.Module))
 			}
+		case OpLoadArg:
+			i = pc.Next()
+			if !pc.isSkip {
+				stack.Push(ctx.Args[i])
+			}
 		case OpStoreLocal:
 			i = pc.Next()
 			if !pc.isSkip {
-				stack.Set(i, stack.Top())
+				env.Set(code.Syms[i], stack.Top())
+			}
+		case OpStoreRef:
+			if !pc.isSkip {
+				v := stack.TopPop()
+				b, ok := ValueToBox(stack.TopPop())
+				if !ok {
+					err = NewRuntimeError(ctx, GenericError, "not box")
+					break
+				}
+				b.Value = v
+				stack.Push(SharedUnit)
 			}
 		case OpStoreAttr:
 			i = pc.Next()
 			if !pc.isSkip {
 				name := code.Syms[i]
 				v := stack.TopPop()
-				ref, _ := ValueToRef(top)
-				m := ref.Module()
-				m.Env.Set(name, v)
+				switch top := stack.TopPop().(type) {
+				case *Ref:
+					top.Module().Env.Set(name, v)
+				case *Record:
+					if !top.Set(name, v) {
+						err = NewKeyError(ctx, name)
+					}
+				default:
+					err = NewRuntimeError(ctx, GenericError, "not record")
+				}
+				stack.Push(SharedUnit)
 			}
 		case OpPop:
 			if !pc.isSkip {
@@ -283,7 +330,9 @@ func (ip *Interp) Eval(ctx *Context, env *Env, code *CompiledCode) (Value, error
 				top = stack.Top()
 				iter, ok := ValueToIter(top)
 				if !ok {
-					panic(fmt.Sprintf("not iter %s", top.Desc()))
+					err = NewRuntimeError(ctx, GenericError,
+						fmt.Sprintf("not iterator %s", top.Desc()))
+					break
 				}
 				if next := iter.Next(); next != nil {
 					stack.Push(next)
@@ -298,21 +347,23 @@ func (ip *Interp) Eval(ctx *Context, env *Env, code *CompiledCode) (Value, error
 				ptn := stack.TopPop()
 				top = stack.TopPop()
 				if ptn, ok := ptn.(*Pattern); ok {
-					if ptn.Eval(env, top) {
-						stack.Push(SharedTrue)
-					} else {
-						stack.Push(SharedFalse)
+					var matched bool
+					if matched, err = ptn.Eval(ip, ctx, env, top); err != nil {
+						break
 					}
+					stack.Push(NewBool(matched))
 				} else {
 					panic("not pattern")
 				}
 			}
 		case OpIter:
-			top = stack.TopPop()
 			if !pc.isSkip {
+				top = stack.TopPop()
 				iter := NewIter(top)
 				if iter == nil {
-					panic("cannot get iterator")
+					err = NewRuntimeError(ctx, GenericError,
+						fmt.Sprintf("cannot get iterator of %s", top.Desc()))
+					break
 				}
 				stack.Push(iter)
 			}
@@ -324,21 +375,104 @@ func (ip *Interp) Eval(ctx *Context, env *Env, code *CompiledCode) (Value, error
 			if !pc.isSkip {
 				env = env.Parent
 			}
+		case OpTry:
+			i = pc.Next()
+			if !pc.isSkip {
+				handlers = append(handlers, handler{label: i, index: stack.Index, env: env})
+			}
+		case OpEndTry:
+			if !pc.isSkip {
+				handlers = handlers[:len(handlers)-1]
+			}
 		case OpCall:
 			i = pc.Next()
 			if !pc.isSkip {
+				args := make([]Value, i)
 				for j := i; j > 0; j-- {
 					args[j-1] = stack.TopPop()
 				}
 				clos, ok := ValueToClos(stack.TopPop())
 				if !ok {
-					panic("not closure")
+					err = NewRuntimeError(ctx, GenericError, "not closure")
+					break
 				}
-				if err := ValidateArity(ctx, i, clos.Arity()); err != nil {
-					return nil, err
+				if arityErr := ValidateArity(ctx, i, clos.Arity()); arityErr != nil {
+					err = arityErr
+					break
 				}
 				newCtx := NewContext(ctx, ctx.Module, clos, args, i)
-				retVal, err = clos.Apply(ip, &newCtx, NewEnv(env))
+				if retVal, err = clos.Apply(ip, &newCtx, NewEnv(env)); err != nil {
+					break
+				}
+				stack.Push(retVal)
+			}
+		case OpPartial:
+			i = pc.Next()
+			mask := pc.Next()
+			if !pc.isSkip {
+				// placeholders are not pushed
+				partArgs := make([]Value, i)
+				for j := i - 1; j >= 0; j-- {
+					if mask&(1<<uint(j)) == 0 {
+						partArgs[j] = stack.TopPop()
+					}
+				}
+				clos, ok := ValueToClos(stack.TopPop())
+				if !ok {
+					err = NewRuntimeError(ctx, GenericError, "not closure")
+					break
+				}
+				if arityErr := ValidateArity(ctx, i, clos.Arity()); arityErr != nil {
+					err = arityErr
+					break
+				}
+				stack.Push(NewPartial(clos, partArgs))
+			}
+		case OpPipe:
+			if !pc.isSkip {
+				clos, ok := ValueToClos(stack.TopPop())
+				if !ok {
+					err = NewRuntimeError(ctx, GenericError, "not closure")
+					break
+				}
+				args := []Value{stack.TopPop()}
+				if arityErr := ValidateArity(ctx, 1, clos.Arity()); arityErr != nil {
+					err = arityErr
+					break
+				}
+				newCtx := NewContext(ctx, ctx.Module, clos, args, 1)
+				if retVal, err = clos.Apply(ip, &newCtx, NewEnv(env)); err != nil {
+					break
+				}
+				stack.Push(retVal)
+			}
+		case OpCallMethod:
+			i = pc.Next()
+			n := pc.Next()
+			if !pc.isSkip {
+				args := make([]Value, n)
+				for j := n; j > 0; j-- {
+					args[j-1] = stack.TopPop()
+				}
+				// the method is in the module of the type of the receiver
+				name := code.Syms[i]
+				var attr Value
+				if m := GetModule(TypeModuleName(TypeNameOf(args[0]))); m != nil {
+					attr = m.GetAttr(name)
+				}
+				clos, ok := ValueToClos(attr)
+				if !ok {
+					err = NewKeyError(ctx, name)
+					break
+				}
+				if arityErr := ValidateArity(ctx, n, clos.Arity()); arityErr != nil {
+					err = arityErr
+					break
+				}
+				newCtx := NewContext(ctx, ctx.Module, clos, args, n)
+				if retVal, err = clos.Apply(ip, &newCtx, NewEnv(env)); err != nil {
+					break
+				}
 				stack.Push(retVal)
 			}
 		case OpPanic:
@@ -346,11 +480,15 @@ func (ip *Interp) Eval(ctx *Context, env *Env, code *CompiledCode) (Value, error
 			if !pc.isSkip {
 				switch i {
 				case OpPanicMatch:
-					panic("pattern match error")
+					err = NewRuntimeError(ctx, MatchError, "pattern match error")
 				default:
 					panic(fmt.Sprintf("unknown panic %d", i))
 				}
 			}
+		case OpRaise:
+			if !pc.isSkip {
+				err = NewExnError(ctx, stack.TopPop())
+			}
 		case OpSome:
 			if !pc.isSkip {
 				top = stack.TopPop()
@@ -363,7 +501,143 @@ func (ip *Interp) Eval(ctx *Context, env *Env, code *CompiledCode) (Value, error
 				for j := 0; j < i; j++ {
 					list = list.Cons(stack.TopPop())
 				}
-				stack.Push(NewList(list))
+				stack.Push(list)
+			}
+		case OpCons:
+			if !pc.isSkip {
+				tail, _ := ValueToList(stack.TopPop())
+				stack.Push(tail.Cons(stack.TopPop()))
+			}
+		case OpTuple:
+			i = pc.Next()
+			if !pc.isSkip {
+				values := make([]Value, i)
+				for j := i - 1; j >= 0; j-- {
+					values[j] = stack.TopPop()
+				}
+				stack.Push(NewTuple(values...))
+			}
+		case OpRecord:
+			i = pc.Next()
+			if !pc.isSkip {
+				tmpl := code.Lits[i].(*Record)
+				values := make([]Value, tmpl.Len())
+				for j := len(values) - 1; j >= 0; j-- {
+					values[j] = stack.TopPop()
+				}
+				stack.Push(NewRecord(tmpl.Name, tmpl.Fields, values))
+			}
+		case OpUpdateRecord:
+			i = pc.Next()
+			if !pc.isSkip {
+				tmpl := code.Lits[i].(*Record)
+				values := make([]Value, tmpl.Len())
+				for j := len(values) - 1; j >= 0; j-- {
+					values[j] = stack.TopPop()
+				}
+				r, _ := ValueToRecord(stack.TopPop())
+				stack.Push(r.Update(tmpl.Fields, values))
+			}
+		case OpImpl:
+			i = pc.Next()
+			if !pc.isSkip {
+				tmpl := code.Lits[i].(*Impl)
+				impl := NewImplTemplate(tmpl.Trait, tmpl.TypeName, tmpl.Names)
+				impl.Methods = make(map[string]Value, len(tmpl.Names))
+				for j := len(tmpl.Names) - 1; j >= 0; j-- {
+					impl.Methods[tmpl.Names[j]] = stack.TopPop()
+				}
+				AddImpl(impl)
+				for name, method := range impl.Methods {
+					env.Set(MethodSym(name, impl.TypeName), method)
+				}
+				stack.Push(SharedUnit)
+			}
+		case OpClosure:
+			i = pc.Next()
+			if !pc.isSkip {
+				stack.Push(NewFun(code.Lits[i].(*CompiledCode), env))
+			}
+		case OpAdd, OpSub, OpMul, OpDiv, OpFloorDiv, OpMod,
+			OpBitAnd, OpBitOr, OpBitXor, OpShiftLeft, OpShiftRight:
+			if !pc.isSkip {
+				r := stack.TopPop()
+				l := stack.TopPop()
+				if top, err = ip.arith(ctx, op, l, r); err != nil {
+					break
+				}
+				stack.Push(top)
+			}
+		case OpEq, OpNe:
+			if !pc.isSkip {
+				r := stack.TopPop()
+				l := stack.TopPop()
+				var eq bool
+				if eq, err = ip.Equal(ctx, l, r); err != nil {
+					break
+				}
+				stack.Push(NewBool(eq == (op == OpEq)))
+			}
+		case OpLt, OpLe, OpGt, OpGe:
+			if !pc.isSkip {
+				r := stack.TopPop()
+				l := stack.TopPop()
+				if lf, ok := ValueToFloat(l); ok {
+					rf, _ := ValueToFloat(r)
+					stack.Push(NewBool(compareFloatOp(op, lf.Value, rf.Value)))
+				} else {
+					var cmp int
+					if cmp, err = ip.Compare(ctx, l, r); err != nil {
+						break
+					}
+					stack.Push(NewBool(compareOp(op, cmp)))
+				}
+			}
+		case OpNeg:
+			if !pc.isSkip {
+				top = stack.TopPop()
+				if f, ok := ValueToFloat(top); ok {
+					stack.Push(NewFloat(-f.Value))
+				} else {
+					n, _ := ValueToInt(top)
+					stack.Push(NewInt(-n.Value))
+				}
+			}
+		case OpNot:
+			if !pc.isSkip {
+				b, _ := ValueToBool(stack.TopPop())
+				stack.Push(NewBool(!b.Value))
+			}
+		case OpBitNot:
+			if !pc.isSkip {
+				n, _ := ValueToInt(stack.TopPop())
+				stack.Push(NewInt(^n.Value))
+			}
+		case OpShow:
+			if !pc.isSkip {
+				var desc string
+				if desc, err = ip.Show(ctx, stack.TopPop()); err != nil {
+					break
+				}
+				stack.Push(NewString(desc))
+			}
+		case OpIndex:
+			if !pc.isSkip {
+				idx := stack.TopPop()
+				if top, err = ip.index(ctx, stack.TopPop(), idx); err != nil {
+					break
+				}
+				stack.Push(top)
+			}
+		case OpConcat:
+			i = pc.Next()
+			if !pc.isSkip {
+				strs := make([]string, i)
+				for j := i - 1; j >= 0; j-- {
+					s, _ := ValueToString(stack.TopPop())
+					strs[j] = s.Value
+				}
+				stack.Push(NewString(strings.Join(strs, "")))
 			}
 		case OpClosedRange:
 			if !pc.isSkip {
@@ -384,13 +658,180 @@ func (ip *Interp) Eval(ctx *Context, env *Env, code *CompiledCode) (Value, error
 		default:
 			panic(fmt.Sprintf("unsupported opcode %s", GetOpName(op)))
 		}
+
+		if err != nil {
+			// unwinds to the innermost try statement in the frame,
+			// or to the caller
+			if len(handlers) == 0 {
+				return nil, err
+			}
+			h := handlers[len(handlers)-1]
+			handlers = handlers[:len(handlers)-1]
+			stack.Index = h.index
+			env = h.env
+			stack.Push(ErrorExn(ctx, err))
+			err = nil
+			pc.Jump(h.label)
+		}
 	}
 
-	if err != nil {
-		return nil, err
-	} else if stack.Index < 0 {
+	if stack.Index < 0 {
 		return SharedUnit, nil
 	} else {
 		return stack.Top(), nil
 	}
 }
+
+// arith applies the arithmetic or bitwise operator to the integers.
+// Division and modulo by zero and negative shift counts are runtime errors.
+func (ip *Interp) arith(ctx *Context, op int, l Value, r Value) (Value, error) {
+	if lf, ok := ValueToFloat(l); ok {
+		rf, _ := ValueToFloat(r)
+		return floatArith(op, lf.Value, rf.Value), nil
+	}
+	li, _ := ValueToInt(l)
+	ri, _ := ValueToInt(r)
+	a, b := li.Value, ri.Value
+	switch op {
+	case OpDiv, OpFloorDiv, OpMod:
+		if b == 0 {
+			return nil, NewRuntimeError(ctx, GenericError, "division by zero")
+		}
+	case OpShiftLeft, OpShiftRight:
+		if b < 0 {
+			return nil, NewRuntimeError(ctx, GenericError,
+				fmt.Sprintf("negative shift count %d", b))
+		}
+	}
+	switch op {
+	case OpAdd:
+		return NewInt(a + b), nil
+	case OpSub:
+		return NewInt(a - b), nil
+	case OpMul:
+		return NewInt(a * b), nil
+	case OpDiv:
+		return NewInt(a / b), nil
+	case OpFloorDiv:
+		q := a / b
+		if (a%b != 0) && ((a < 0) != (b < 0)) {
+			q--
+		}
+		return NewInt(q), nil
+	case OpMod:
+		m := a % b
+		if m != 0 && ((m < 0) != (b < 0)) {
+			m += b
+		}
+		return NewInt(m), nil
+	case OpBitAnd:
+		return NewInt(a & b), nil
+	case OpBitOr:
+		return NewInt(a | b), nil
+	case OpBitXor:
+		return NewInt(a ^ b), nil
+	case OpShiftLeft:
+		return NewInt(a << uint(b)), nil
+	case OpShiftRight:
+		return NewInt(a >> uint(b)), nil
+	default:
+		panic(fmt.Sprintf("not arithmetic opcode %s", GetOpName(op)))
+	}
+}
+
+// index returns the element at the int index or the slice of the range
+// of the list or the string. Indexes start from 0.
+// index returns the element or the slice of the list or the string.
+// Strings are indexed by characters (runes), not bytes.
+func (ip *Interp) index(ctx *Context, v Value, idx Value) (Value, error) {
+	var n int
+	var values []Value
+	var runes []rune
+	s, isStr := ValueToString(v)
+	if isStr {
+		runes = []rune(s.Value)
+		n = len(runes)
+	} else {
+		list, _ := ValueToList(v)
+		values = list.Values()
+		n = len(values)
+	}
+
+	if r, ok := idx.(*Range); ok {
+		start, end := r.Start, r.End
+		if r.Close {
+			end++
+		}
+		if start < 0 || end > n || start > end {
+			return nil, NewRuntimeError(ctx, IndexError,
+				fmt.Sprintf("slice %s out of range for length %d", r.Desc(), n))
+		}
+		if isStr {
+			return NewString(string(runes[start:end])), nil
+		}
+		return NewListOfValues(values[start:end]), nil
+	}
+
+	i, _ := ValueToInt(idx)
+	if i.Value < 0 || i.Value >= n {
+		return nil, NewRuntimeError(ctx, IndexError,
+			fmt.Sprintf("index %d out of range for length %d", i.Value, n))
+	}
+	if isStr {
+		return NewString(string(runes[i.Value])), nil
+	}
+	return values[i.Value], nil
+}
+
+// floatArith follows IEEE 754: division by zero yields an infinity or NaN
+// instead of an error. "//" and "%" floor like their int counterparts.
+func floatArith(op int, a float64, b float64) Value {
+	switch op {
+	case OpAdd:
+		return NewFloat(a + b)
+	case OpSub:
+		return NewFloat(a - b)
+	case OpMul:
+		return NewFloat(a * b)
+	case OpDiv:
+		return NewFloat(a / b)
+	case OpFloorDiv:
+		return NewFloat(math.Floor(a / b))
+	case OpMod:
+		m := math.Mod(a, b)
+		if m != 0 && ((m < 0) != (b < 0)) {
+			m += b
+		}
+		return NewFloat(m)
+	default:
+		panic(fmt.Sprintf("not float arithmetic opcode %s", GetOpName(op)))
+	}
+}
+
+// compareFloatOp compares floats directly so that any comparison
+// with NaN is false.
+func compareFloatOp(op int, a float64, b float64) bool {
+	switch op {
+	case OpLt:
+		return a < b
+	case OpLe:
+		return a <= b
+	case OpGt:
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+func compareOp(op int, cmp int) bool {
+	switch op {
+	case OpLt:
+		return cmp < 0
+	case OpLe:
+		return cmp <= 0
+	case OpGt:
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
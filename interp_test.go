@@ -0,0 +1,285 @@
+package trompe
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestOperators(t *testing.T) {
+	cases := []struct {
+		exp  Node
+		want string
+	}{
+		{bin(in("1"), "+", bin(in("2"), "*", in("3"))), "7"},
+		{bin(un("-", in("7")), "//", in("2")), "-4"},
+		{bin(un("-", in("7")), "/", in("2")), "-3"},
+		{bin(un("-", in("7")), "%", in("2")), "1"},
+		{bin(in("6"), "&", in("3")), "2"},
+		{bin(in("1"), "<<", in("4")), "16"},
+		{un("~", in("0")), "-1"},
+		{bin(in("1"), "<=", in("1")), "true"},
+		{bin(st("a"), "<", st("b")), "true"},
+		{bin(in("1"), "!=", in("1")), "false"},
+		{bin(bl(false), "and", bin(bin(in("1"), "//", in("0")), "==", in("0"))), "false"},
+		{bin(bl(true), "or", bl(false)), "true"},
+		{un("not", bl(true)), "false"},
+		{fl("0x1.8p1"), "3.0"},
+		{bin(fl("1.0"), "/", fl("4.0")), "0.25"},
+		{bin(un("-", fl("7.0")), "//", fl("2.0")), "-4.0"},
+		{bin(fl("1.0"), "/", fl("0.0")), "+Inf"},
+		{bin(bin(fl("0.0"), "/", fl("0.0")), "<=", fl("1.0")), "false"},
+		{call(vr("int"), un("-", fl("3.7"))), "-3"},
+		{bin(in("1"), "::", bin(in("2"), "::", lst())), "[1, 2]"},
+		{ubin(lst(in("1"), in("2")), "@", lst(in("3"))), "[1, 2, 3]"},
+		{call(vr("min"), in("3"), call(vr("min"), in("1"), in("2"))), "1"},
+		{call(vr("max"), st("a"), st("b")), "b"},
+		{call(vr("min"), tupe(in("1"), st("b")), tupe(in("1"), st("a"))), "(1, a)"},
+	}
+	for _, c := range cases {
+		t.Run(NodeDesc(c.exp), func(t *testing.T) {
+			eval(t, c.want, c.exp)
+		})
+	}
+}
+
+func TestIndexAndMethods(t *testing.T) {
+	xs := lst(in("1"), in("2"), in("3"), in("4"))
+	cases := []struct {
+		exp  Node
+		want string
+	}{
+		{idx(xs, in("1")), "2"},
+		{idx(xs, rng(in("1"), in("3"), false)), "[2, 3]"},
+		{idx(st("hello"), rng(in("1"), in("3"), true)), "ell"},
+		{idx(st("hello"), in("0")), "h"},
+		{mcall(st("hello"), "length"), "5"},
+		{mcall(st("héllo"), "length"), "5"},
+		{idx(st("héllo"), in("1")), "é"},
+		{idx(st("日本語"), rng(in("1"), in("3"), false)), "本語"},
+		{call(mcall(st("héllo"), "get", vr("_")), in("4")), "o"},
+		{mcall(in("42"), "to_string"), "42"},
+		{mcall(xs, "reverse"), "[4, 3, 2, 1]"},
+		{call(attr(vr("String"), "length"), st("ab")), "2"},
+		{&InterpExpNode{Parts: []Node{st("a="), in("1"), st(" "), tupe(in("1"), fl("2.5"))}}, "a=1 (1, 2.5)"},
+		{some(in("1")), "some(1)"},
+		{none(), "none"},
+	}
+	for _, c := range cases {
+		t.Run(NodeDesc(c.exp), func(t *testing.T) {
+			eval(t, c.want, c.exp)
+		})
+	}
+}
+
+func TestClosures(t *testing.T) {
+	// def make_adder(n) = fun(x) in x + n end; make_adder(1)(2)
+	eval(t, "3", sdef("make_adder", []string{"n"}, anon([]string{"x"}, bin(vr("x"), "+", vr("n")))),
+		call(call(vr("make_adder"), in("1")), in("2")))
+	// the bindings of the caller do not leak into the callee
+	eval(t, "10", let(vp("k"), in("10")),
+		sdef("get", nil, vr("k")),
+		sdef("f", []string{"k"}, call(vr("get"))),
+		call(vr("f"), in("1")))
+	// def count(n) if n > 0 then count(n - 1) end end; count(3)
+	count := def("count", []string{"n"}, &IfStatNode{Cond: []IfCondNode{{
+		Cond: bin(vr("n"), ">", in("0")), Action: *blk(call(vr("count"), bin(vr("n"), "-", in("1"))))}}})
+	eval(t, "0", count, call(vr("count"), in("3")), in("0"))
+}
+
+func TestPartialApplication(t *testing.T) {
+	sub := anon([]string{"x", "y"}, bin(vr("x"), "-", vr("y")))
+	cases := []struct {
+		exp  Node
+		want string
+	}{
+		{call(call(sub, vr("_"), in("1")), in("10")), "9"},
+		{call(call(sub, in("10"), vr("_")), in("1")), "9"},
+		{bin(in("10"), "|>", call(sub, vr("_"), in("3"))), "7"},
+		{call(mcall(st("hello"), "get", vr("_")), in("1")), "e"},
+		{bin(in("5"), "|>", vr("float")), "5.0"},
+	}
+	for _, c := range cases {
+		t.Run(NodeDesc(c.exp), func(t *testing.T) {
+			eval(t, c.want, c.exp)
+		})
+	}
+	check(t, 1, call(sub, vr("_"), in("1"), in("2")))
+	check(t, 1, vr("_"))
+
+	// a partial of a recursive def referring to a top-level let
+	sum := def("sum", []string{"n", "base"},
+		ifElse(bin(vr("n"), "==", in("0")), blk(vr("base")),
+			blk(bin(vr("n"), "+", call(vr("sum"), bin(vr("n"), "-", in("1")), vr("base"))))))
+	eval(t, "106", sum, let(vp("g"), call(vr("sum"), vr("_"), in("100"))), call(vr("g"), in("3")))
+}
+
+func TestExceptions(t *testing.T) {
+	thrower := anon([]string{"x"}, raise(call(vr("Error"), vr("x"))))
+	cases := []struct {
+		name string
+		stat Node
+		want string
+	}{
+		{"no raise", try(blk(in("1")), nil, rescue(vp("e"), in("2"))), "1"},
+		{"raise", try(blk(raise(call(vr("Error"), st("boom"))), st("no")), nil,
+			rescue(ctorp("Error", vp("m")), vr("m"))), "boom"},
+		{"division by zero", try(blk(bin(in("1"), "//", in("0"))), nil,
+			rescue(ctorp("Error", sp("division by zero")), in("-1"))), "-1"},
+		{"string index", try(blk(idx(st("é"), in("1"))), nil,
+			rescue(ctorp("IndexError", vp("m")), vr("m"))), "index 1 out of range for length 1"},
+		{"index", try(blk(idx(lst(st("a")), in("5"))), nil,
+			rescue(ctorp("KeyError", vp("m")), st("key")),
+			rescue(ctorp("IndexError", vp("m")), st("index"))), "index"},
+		{"function", try(blk(call(thrower, st("deep"))), nil, rescue(vp("e"), st("caught"))), "caught"},
+		{"reraise", try(blk(try(blk(raise(call(vr("KeyError"), st("k")))), nil,
+			rescue(ctorp("IndexError", vp("m")), vr("m")))), nil,
+			rescue(ctorp("KeyError", vp("m")), vr("m"))), "k"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			eval(t, c.want, c.stat)
+		})
+	}
+
+	// ensure runs with and without exceptions
+	set := func(e Node) *AssignStatNode { return &AssignStatNode{Target: vr("b"), Exp: e} }
+	eval(t, "6", let(vp("b"), call(vr("box"), in("0"))),
+		try(blk(try(blk(raise(call(vr("Error"), st("x")))), blk(set(in("5"))))), nil,
+			rescue(vp("_"), in("0"))),
+		try(blk(in("1")), blk(set(bin(call(vr("unbox"), vr("b")), "+", in("1"))))),
+		call(vr("unbox"), vr("b")))
+
+	// return runs the ensure blocks from the innermost
+	bset := func(e Node) *AssignStatNode { return &AssignStatNode{Target: vr("b"), Exp: e} }
+	unbox := call(vr("unbox"), vr("b"))
+	f := def("f", []string{"b"},
+		try(blk(try(blk(ret(in("1"))), blk(bset(bin(unbox, "*", in("10")))))),
+			blk(bset(bin(unbox, "+", in("1")))), rescue(vp("_"), in("0"))),
+		in("2"))
+	eval(t, "(1, 11)", f, let(vp("b"), call(vr("box"), in("1"))),
+		tupe(call(vr("f"), vr("b")), unbox))
+	g := def("g", []string{"b"},
+		try(blk(raise(call(vr("Error"), st("x")))), blk(bset(in("7"))),
+			rescue(vp("_"), ret(in("3")))),
+		in("4"))
+	eval(t, "(3, 7)", g, let(vp("b"), call(vr("box"), in("1"))),
+		tupe(call(vr("g"), vr("b")), unbox))
+	// an exception in the ensure block is caught by the outer handler
+	h := def("h", nil,
+		try(blk(try(blk(ret(in("1"))), blk(raise(call(vr("Error"), st("e")))))), nil,
+			rescue(ctorp("Error", vp("m")), in("5"))))
+	eval(t, "5", h, call(vr("h")))
+
+	// uncaught
+	if _, err := exec(t, raise(call(vr("Error"), st("oops")))); err == nil || err.Error() != "GenericError: oops" {
+		t.Errorf("got %v", err)
+	}
+
+	check(t, 1, try(blk(in("1")), nil, rescue(ip("1"), in("2"))))
+	check(t, 1, &TypeDeclNode{Name: tok("exn"), Ctors: []CtorDeclNode{{Name: tok("Mine")}}})
+}
+
+func TestLetElse(t *testing.T) {
+	els := blk(raise(call(vr("Error"), st("oops"))))
+	eval(t, "1", &LetStatNode{Ptn: somep(vp("a")), Exp: some(in("1")), ElseAction: els}, vr("a"))
+	if _, err := exec(t, &LetStatNode{Ptn: somep(vp("a")), Exp: none(), ElseAction: els}, vr("a")); err == nil {
+		t.Errorf("no error")
+	}
+
+	// the else block must leave
+	first := func(els *BlockNode) Node {
+		return def("first", []string{"xs"},
+			&LetStatNode{Ptn: cons(vp("h"), vp("_")), Exp: vr("xs"), ElseAction: els}, vr("h"))
+	}
+	eval(t, "-1", first(blk(ret(in("-1")))), call(vr("first"), lst()))
+	eval(t, "-1", first(blk(ifElse(bl(true), blk(ret(in("-1"))), blk(raise(call(vr("Error"), st("x"))))))),
+		call(vr("first"), lst()))
+	check(t, 0, first(blk(caseOf(in("1"), clau(vp("_"), nil, ret(in("0")))))))
+	check(t, 1, first(blk()))
+	check(t, 1, first(blk(in("0"))))
+	check(t, 1, first(blk(ret(in("0")), in("0"))))
+	check(t, 1, first(blk(&IfStatNode{Cond: []IfCondNode{{Cond: bl(true), Action: *blk(ret(in("0")))}}})))
+	check(t, 1, first(blk(ifElse(bl(true), blk(ret(in("0"))), blk(in("1"))))))
+}
+
+func TestUserOperators(t *testing.T) {
+	Init()
+	m := NewModule(nil, "Ops")
+	m.AddPrim("<+>", func(ctx *Context, args []Value, nargs int) (Value, error) {
+		a, _ := ValueToInt(args[0])
+		b, _ := ValueToInt(args[1])
+		return NewInt(a.Value*10 + b.Value), nil
+	}, "(int, int) -> int")
+	AddOpenedModule(m)
+	exp := ubin(ubin(in("1"), "<+>", in("2")), "<+>", in("3"))
+	info, errs := TypeCheck("test", chunk(exp))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	code, _ := Compile("test", blk(exp), info)
+	if v, err := Run("test", code); err != nil || v.Desc() != "123" {
+		t.Errorf("got %v, %v", v, err)
+	}
+	if _, errs := TypeCheck("test", chunk(ubin(st("a"), "<+>", in("2")))); len(errs) != 1 {
+		t.Errorf("got %v", errs)
+	}
+}
+
+func TestStackGrowth(t *testing.T) {
+	const n = 17
+	elems := make([]Node, n)
+	parts := make([]Node, n)
+	names := make([]string, n)
+	var sum Node = in("0")
+	var deep Node = in("0")
+	for i := range elems {
+		elems[i] = in("1")
+		parts[i] = st("a")
+		names[i] = "x" + strconv.Itoa(i)
+		sum = bin(sum, "+", vr(names[i]))
+		deep = bin(in("1"), "+", deep)
+	}
+	eval(t, "17", mcall(lst(elems...), "length"))
+	eval(t, "17", deep)
+	eval(t, "17", call(anon(names, sum), elems...))
+	eval(t, strings.Repeat("a", n), &InterpExpNode{Parts: parts})
+}
+
+func TestPrintf(t *testing.T) {
+	printf := func(args ...Node) Node { return call(vr("printf"), args...) }
+	eval(t, "()", printf(st("%s: %d%% %x %f\n"), tupe(in("1"), st("a")), in("50"), in("255"), fl("0.5")))
+	eval(t, "()", printf(st("none\n")))
+	cases := []Node{
+		printf(st("%d\n"), st("a")),
+		printf(st("%f\n"), in("1")),
+		printf(st("%d %d\n"), in("1")),
+		printf(st("\n"), in("1")),
+		printf(st("%q\n"), in("1")),
+		printf(call(vr("id"), st("%d")), in("1")),
+		printf(st("%d"), vr("_")),
+		printf(),
+	}
+	for _, c := range cases {
+		check(t, 1, c)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	// examples/levenshtein.tm
+	minus1 := func(name string) Node { return bin(vr(name), "-", in("1")) }
+	dist := call(vr("dist"), minus1("i"), minus1("j"))
+	rest := blk(let(tup(vp("d1"), vp("d2"), vp("d3")),
+		tupe(call(vr("dist"), minus1("i"), vr("j")), call(vr("dist"), vr("i"), minus1("j")), dist)),
+		bin(in("1"), "+", call(vr("min"), vr("d1"), call(vr("min"), vr("d2"), vr("d3")))))
+	lev := def("levenshtein", []string{"s", "t"},
+		def("dist", []string{"i", "j"}, caseOf(tupe(vr("i"), vr("j")),
+			clau(tup(vp("i"), ip("0")), nil, vr("i")),
+			clau(tup(ip("0"), vp("j")), nil, vr("j")),
+			clau(tup(vp("i"), vp("j")), nil,
+				ifElse(bin(idx(vr("s"), minus1("i")), "==", idx(vr("t"), minus1("j"))), blk(dist), rest)))),
+		call(vr("dist"), mcall(vr("s"), "length"), mcall(vr("t"), "length")))
+	lev.Params.Types = []TypeNode{named("string"), named("string")}
+	eval(t, "(2, 1, 0)", lev, tupe(call(vr("levenshtein"), st("kit"), st("sitt")),
+		call(vr("levenshtein"), st("née"), st("nee")), call(vr("levenshtein"), st("日本"), st("日本"))))
+}
@@ -1,20 +1,201 @@
 package trompe
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"strings"
+)
 
 func LibCoreId(ctx *Context, args []Value, nargs int) (Value, error) {
 	return args[0], nil
 }
 
 func LibCoreShow(ctx *Context, args []Value, nargs int) (Value, error) {
-	fmt.Printf("%s\n", args[0].Desc())
+	s, err := ctx.Interp.Show(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("%s\n", s)
 	return SharedUnit, nil
 }
 
+func LibCoreBox(ctx *Context, args []Value, nargs int) (Value, error) {
+	return NewBox(args[0]), nil
+}
+
+func LibCoreUnbox(ctx *Context, args []Value, nargs int) (Value, error) {
+	b, _ := ValueToBox(args[0])
+	return b.Value, nil
+}
+
+func LibCoreFloat(ctx *Context, args []Value, nargs int) (Value, error) {
+	i, _ := ValueToInt(args[0])
+	return NewFloat(float64(i.Value)), nil
+}
+
+// LibCoreInt truncates the float toward zero.
+func LibCoreInt(ctx *Context, args []Value, nargs int) (Value, error) {
+	f, _ := ValueToFloat(args[0])
+	if math.IsNaN(f.Value) || math.IsInf(f.Value, 0) {
+		return nil, NewRuntimeError(ctx, GenericError,
+			fmt.Sprintf("cannot convert %s to int", f.Desc()))
+	}
+	return NewInt(int(f.Value)), nil
+}
+
+func LibCoreSort(ctx *Context, args []Value, nargs int) (Value, error) {
+	list, _ := ValueToList(args[0])
+	values := list.Values()
+	// insertion sort, stable
+	for i := 1; i < len(values); i++ {
+		for j := i; j > 0; j-- {
+			cmp, err := ctx.Interp.Compare(ctx, values[j-1], values[j])
+			if err != nil {
+				return nil, err
+			}
+			if cmp <= 0 {
+				break
+			}
+			values[j-1], values[j] = values[j], values[j-1]
+		}
+	}
+	return NewListOfValues(values), nil
+}
+
+// FormatVerbs returns the verbs of the directives in the format string.
+// The directives are %d and %x for ints, %f for floats, %s for values
+// shown with the Show trait, and %% for the percent sign.
+func FormatVerbs(format string) ([]byte, error) {
+	var verbs []byte
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		if i >= len(format) {
+			return nil, fmt.Errorf("incomplete directive at end of format")
+		}
+		switch format[i] {
+		case 'd', 'x', 'f', 's':
+			verbs = append(verbs, format[i])
+		case '%':
+		default:
+			return nil, fmt.Errorf("unknown directive %%%c", format[i])
+		}
+	}
+	return verbs, nil
+}
+
+// LibCorePrintf prints the values formatted with the format string.
+func LibCorePrintf(ctx *Context, args []Value, nargs int) (Value, error) {
+	format, ok := ValueToString(args[0])
+	if !ok {
+		return nil, NewRuntimeError(ctx, GenericError, "format must be a string")
+	}
+	if _, err := FormatVerbs(format.Value); err != nil {
+		return nil, NewRuntimeError(ctx, GenericError, err.Error())
+	}
+	var buf strings.Builder
+	j := 1
+	for i := 0; i < len(format.Value); i++ {
+		c := format.Value[i]
+		if c != '%' {
+			buf.WriteByte(c)
+			continue
+		}
+		i++
+		if format.Value[i] == '%' {
+			buf.WriteByte('%')
+			continue
+		}
+		if j >= nargs {
+			return nil, NewRuntimeError(ctx, GenericError, "too few arguments for format")
+		}
+		arg := args[j]
+		j++
+		switch format.Value[i] {
+		case 'd', 'x':
+			n, ok := ValueToInt(arg)
+			if !ok {
+				return nil, NewRuntimeError(ctx, GenericError, "int required")
+			}
+			buf.WriteString(fmt.Sprintf("%"+string(format.Value[i]), n.Value))
+		case 'f':
+			f, ok := ValueToFloat(arg)
+			if !ok {
+				return nil, NewRuntimeError(ctx, GenericError, "float required")
+			}
+			buf.WriteString(fmt.Sprintf("%f", f.Value))
+		case 's':
+			s, err := ctx.Interp.Show(ctx, arg)
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString(s)
+		}
+	}
+	if j < nargs {
+		return nil, NewRuntimeError(ctx, GenericError, "too many arguments for format")
+	}
+	fmt.Print(buf.String())
+	return SharedUnit, nil
+}
+
+// LibCoreMin returns the smaller of the values, or the first if equal.
+func LibCoreMin(ctx *Context, args []Value, nargs int) (Value, error) {
+	cmp, err := ctx.Interp.Compare(ctx, args[0], args[1])
+	if err != nil {
+		return nil, err
+	} else if cmp <= 0 {
+		return args[0], nil
+	}
+	return args[1], nil
+}
+
+// LibCoreMax returns the larger of the values, or the first if equal.
+func LibCoreMax(ctx *Context, args []Value, nargs int) (Value, error) {
+	cmp, err := ctx.Interp.Compare(ctx, args[0], args[1])
+	if err != nil {
+		return nil, err
+	} else if cmp >= 0 {
+		return args[0], nil
+	}
+	return args[1], nil
+}
+
+// LibCoreAppend concatenates the lists. The second list is shared.
+func LibCoreAppend(ctx *Context, args []Value, nargs int) (Value, error) {
+	xs, _ := ValueToList(args[0])
+	ys, _ := ValueToList(args[1])
+	values := xs.Values()
+	for i := len(values) - 1; i >= 0; i-- {
+		ys = ys.Cons(values[i])
+	}
+	return ys, nil
+}
+
 func InstallLibCore() {
 	m := NewModule(nil, "core")
-	m.AddPrim("id", LibCoreId, 1)
-	m.AddPrim("show", LibCoreShow, 1)
+	m.AddPrim("id", LibCoreId, "'a -> 'a")
+	m.AddPrim("show", LibCoreShow, "Show 'a => 'a -> unit")
+	m.AddPrim("sort", LibCoreSort, "Ord 'a => list<'a> -> list<'a>")
+	m.AddPrim("min", LibCoreMin, "Ord 'a => ('a, 'a) -> 'a")
+	m.AddPrim("max", LibCoreMax, "Ord 'a => ('a, 'a) -> 'a")
+	m.AddPrim("box", LibCoreBox, "'a -> box<'a>")
+	m.AddPrim("unbox", LibCoreUnbox, "box<'a> -> 'a")
+	m.AddPrim("float", LibCoreFloat, "int -> float")
+	m.AddPrim("int", LibCoreInt, "float -> int")
+	m.AddPrim("@", LibCoreAppend, "(list<'a>, list<'a>) -> list<'a>")
+	m.AddFixity("@", Fixity{Assoc: AssocRight, Prec: 10})
+	m.AddFormatPrim("printf", LibCorePrintf)
+	m.AddMethod(TraitShow, "desc", "Show 'a => 'a -> string")
+	m.AddMethod(TraitEq, "eq", "Eq 'a => ('a, 'a) -> bool")
+	m.AddMethod(TraitOrd, "compare", "Ord 'a => ('a, 'a) -> int")
+	m.AddAttr(OptionNoneTag, SharedNone)
+	m.AddAttr(OptionSomeTag, NewCtorValue(OptionTypeName, OptionSomeTag, 1))
+	for _, tag := range ExnTags() {
+		m.AddAttr(tag, NewCtorValue(ExnTypeName, tag, 1))
+	}
 	AddTopModule(m)
 	AddOpenedModule(m)
 
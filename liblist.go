@@ -0,0 +1,27 @@
+package trompe
+
+func LibListLength(ctx *Context, args []Value, nargs int) (Value, error) {
+	list, _ := ValueToList(args[0])
+	return NewInt(list.Len()), nil
+}
+
+func LibListGet(ctx *Context, args []Value, nargs int) (Value, error) {
+	return ctx.Interp.index(ctx, args[0], args[1])
+}
+
+func LibListReverse(ctx *Context, args []Value, nargs int) (Value, error) {
+	list, _ := ValueToList(args[0])
+	rev := ListNil
+	for ; !list.IsNil(); list = list.Next {
+		rev = rev.Cons(list.Value)
+	}
+	return rev, nil
+}
+
+func InstallLibList() {
+	m := NewModule(nil, "List")
+	m.AddPrim("length", LibListLength, "list<'a> -> int")
+	m.AddPrim("get", LibListGet, "(list<'a>, int) -> 'a")
+	m.AddPrim("reverse", LibListReverse, "list<'a> -> list<'a>")
+	AddTopModule(m)
+}
@@ -0,0 +1,27 @@
+package trompe
+
+import "strconv"
+
+func LibIntToString(ctx *Context, args []Value, nargs int) (Value, error) {
+	i, _ := ValueToInt(args[0])
+	return NewString(strconv.Itoa(i.Value)), nil
+}
+
+func LibFloatToString(ctx *Context, args []Value, nargs int) (Value, error) {
+	f, _ := ValueToFloat(args[0])
+	return NewString(f.Desc()), nil
+}
+
+func InstallLibInt() {
+	m := NewModule(nil, "Int")
+	m.AddPrim("to_string", LibIntToString, "int -> string")
+	m.AddPrim("to_float", LibCoreFloat, "int -> float")
+	AddTopModule(m)
+}
+
+func InstallLibFloat() {
+	m := NewModule(nil, "Float")
+	m.AddPrim("to_string", LibFloatToString, "float -> string")
+	m.AddPrim("to_int", LibCoreInt, "float -> int")
+	AddTopModule(m)
+}
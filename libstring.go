@@ -0,0 +1,35 @@
+package trompe
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// LibStringLength returns the number of the characters (runes).
+func LibStringLength(ctx *Context, args []Value, nargs int) (Value, error) {
+	s, _ := ValueToString(args[0])
+	return NewInt(utf8.RuneCountInString(s.Value)), nil
+}
+
+func LibStringGet(ctx *Context, args []Value, nargs int) (Value, error) {
+	return ctx.Interp.index(ctx, args[0], args[1])
+}
+
+func LibStringToUpper(ctx *Context, args []Value, nargs int) (Value, error) {
+	s, _ := ValueToString(args[0])
+	return NewString(strings.ToUpper(s.Value)), nil
+}
+
+func LibStringToLower(ctx *Context, args []Value, nargs int) (Value, error) {
+	s, _ := ValueToString(args[0])
+	return NewString(strings.ToLower(s.Value)), nil
+}
+
+func InstallLibString() {
+	m := NewModule(nil, "String")
+	m.AddPrim("length", LibStringLength, "string -> int")
+	m.AddPrim("get", LibStringGet, "(string, int) -> string")
+	m.AddPrim("to_upper", LibStringToUpper, "string -> string")
+	m.AddPrim("to_lower", LibStringToLower, "string -> string")
+	AddTopModule(m)
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 )
 
+// List is an immutable singly linked list. A non-empty list is a cell
+// of the head value and the tail list. The empty list is ListNil, the only
+// cell without the tail.
 type List struct {
 	Value Value
 	Next  *List
@@ -11,14 +14,19 @@ type List struct {
 
 var ListNil = &List{nil, nil}
 
+// NewList returns a list of the one value.
 func NewList(value Value) *List {
-	return &List{Value: value, Next: nil}
+	return ListNil.Cons(value)
+}
+
+// IsNil reports whether the list is empty.
+func (l *List) IsNil() bool {
+	return l.Next == nil
 }
 
 func (l *List) Len() int {
 	i := 0
-	for l.Next != nil {
-		l = l.Next
+	for ; !l.IsNil(); l = l.Next {
 		i++
 	}
 	return i
@@ -28,6 +36,24 @@ func (l *List) Cons(value Value) *List {
 	return &List{Value: value, Next: l}
 }
 
+// NewListOfValues returns a list of the values in order.
+func NewListOfValues(values []Value) *List {
+	list := ListNil
+	for i := len(values) - 1; i >= 0; i-- {
+		list = list.Cons(values[i])
+	}
+	return list
+}
+
+// Values returns the elements of the list in order.
+func (l *List) Values() []Value {
+	var values []Value
+	for ; !l.IsNil(); l = l.Next {
+		values = append(values, l.Value)
+	}
+	return values
+}
+
 // interface Value
 
 func (l *List) Type() int {
@@ -35,6 +61,5 @@ func (l *List) Type() int {
 }
 
 func (l *List) Desc() string {
-	// TODO
-	return fmt.Sprintf("list")
+	return fmt.Sprintf("[%s]", ValuesDesc(l.Values()))
 }
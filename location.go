@@ -11,6 +11,7 @@ type Pos struct {
 }
 
 type Loc struct {
+	File  int // ID of the registered source, 0 if unknown
 	Start Pos
 	End   Pos
 }
@@ -21,6 +22,12 @@ func NewLocAntlr(tok antlr.Token) Loc {
 	offset := tok.GetStart()
 	len_ := tok.GetStop() - offset
 	start := Pos{Line: line, Col: col, Offset: offset}
-	end := Pos{Line: line, Col: col, Offset: offset + len_}
-	return Loc{start, end}
+	var file int
+	if s, ok := tok.GetInputStream().(*SourceStream); ok {
+		file = s.Source.Id
+		start = s.SourcePos(start)
+	}
+	end := start
+	end.Offset += len_
+	return Loc{File: file, Start: start, End: end}
 }
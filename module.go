@@ -1,15 +1,21 @@
 package trompe
 
 import (
+	"fmt"
 	"strings"
 )
 
 type Module struct {
-	Parent *Module
-	Subs   map[string]*Module
-	Name   string
-	File   string
-	Env    *Env
+	Parent  *Module
+	Subs    map[string]*Module
+	Name    string
+	File    string
+	Env     *Env
+	Sigs    map[string]*TyScheme // type signatures of attributes
+	Traits  map[string][]string  // method names of traits
+	Formats map[string]bool      // primitives taking a format string
+
+	Fixities map[string]Fixity // fixities of operators
 }
 
 var RootModule *Module
@@ -31,6 +37,15 @@ func GetModule(path string) *Module {
 	return owner
 }
 
+// TypeModuleName returns the name of the module that has the methods
+// of the type, such as "String" for "string".
+func TypeModuleName(typeName string) string {
+	if typeName == "" {
+		return ""
+	}
+	return strings.ToUpper(typeName[:1]) + typeName[1:]
+}
+
 func AddTopModule(m *Module) {
 	RootModule.AddSub(m)
 }
@@ -52,10 +67,15 @@ func NewModule(parent *Module, name string) *Module {
 	env := NewEnv(nil)
 	env.Imports = OpenedModules
 	return &Module{
-		Parent: parent,
-		Subs:   make(map[string]*Module, 8),
-		Name:   name,
-		Env:    env,
+		Parent:  parent,
+		Subs:    make(map[string]*Module, 8),
+		Name:    name,
+		Env:     env,
+		Sigs:    make(map[string]*TyScheme, 8),
+		Traits:  make(map[string][]string, 8),
+		Formats: make(map[string]bool, 2),
+
+		Fixities: make(map[string]Fixity, 4),
 	}
 }
 
@@ -82,8 +102,50 @@ func (m *Module) AddAttr(name string, value Value) {
 	m.Env.Set(name, value)
 }
 
+func (m *Module) GetSig(name string) *TyScheme {
+	return m.Sigs[name]
+}
+
+func mustParseTypeSig(m *Module, name string, sig string) *TyScheme {
+	scm, err := ParseTypeSig(sig)
+	if err != nil {
+		panic(fmt.Sprintf("%s.%s: %s", m.Path(), name, err))
+	}
+	return scm
+}
+
+func sigArity(scm *TyScheme) int {
+	if fun, ok := scm.Type.(*TyFun); ok {
+		return len(fun.Params)
+	}
+	return 0
+}
+
+// AddPrim adds a primitive with the type signature such as "'a -> 'a".
+// The arity is the number of parameters of the signature.
 func (m *Module) AddPrim(name string,
 	f func(*Context, []Value, int) (Value, error),
-	arity int) {
-	m.AddAttr(name, NewPrim(f, arity))
+	sig string) {
+	scm := mustParseTypeSig(m, name, sig)
+	m.AddAttr(name, NewPrim(f, sigArity(scm)))
+	m.Sigs[name] = scm
+}
+
+// AddFormatPrim adds a primitive taking a format string and the values
+// of its directives, such as printf. The type checker checks the values
+// against the directives if the format string is a literal.
+func (m *Module) AddFormatPrim(name string, f PrimFun) {
+	scm := mustParseTypeSig(m, name, "string -> unit")
+	m.AddAttr(name, NewPrim(f, VariadicArity))
+	m.Sigs[name] = scm
+	m.Formats[name] = true
+}
+
+// AddMethod adds a method of the trait. The signature must have
+// the predicate of the trait such as "Show 'a => 'a -> string".
+func (m *Module) AddMethod(trait string, name string, sig string) {
+	scm := mustParseTypeSig(m, name, sig)
+	m.AddAttr(name, NewMethod(trait, name, sigArity(scm)))
+	m.Sigs[name] = scm
+	m.Traits[trait] = append(m.Traits[trait], name)
 }
@@ -20,15 +20,17 @@ type ObjectAttr struct {
 }
 
 type ObjectValue struct {
-	Type  string `json:"type"`
-	Value string `json:"value"`
+	Type  string         `json:"type"`
+	Value string         `json:"value"`
+	Elts  []*ObjectValue `json:"elements,omitempty"`
 }
 
 type ObjectCode struct {
-	Id   int            `json:"id"`
-	Syms []string       `json:"symbols"`
-	Lits []*ObjectValue `json:"literals"`
-	Ops  []int          `json:"opcodes"`
+	Id     int            `json:"id"`
+	Params []string       `json:"params"`
+	Syms   []string       `json:"symbols"`
+	Lits   []*ObjectValue `json:"literals"`
+	Ops    []int          `json:"opcodes"`
 }
 
 var ObjectValueTypeUnit = "unit"
@@ -37,6 +39,12 @@ var ObjectValueTypeInt = "int"
 var ObjectValueTypeFloat = "float"
 var ObjectValueTypeString = "string"
 var ObjectValueTypeCode = "code"
+var ObjectValueTypeRecord = "record"   // struct name, field names
+var ObjectValueTypeVariant = "variant" // tag, type name
+var ObjectValueTypeCtor = "ctor"       // tag, type name and arity
+var ObjectValueTypeMethod = "method"   // method name, trait name and arity
+var ObjectValueTypeImpl = "impl"       // trait name, type name and method names
+var ObjectValueTypePattern = "pattern" // component of the pattern
 
 func NewObjectFile(name string) *ObjectFile {
 	return &ObjectFile{
@@ -55,24 +63,57 @@ func (file *ObjectFile) AddCode(code *ObjectCode) {
 	file.Codes = append(file.Codes, code)
 }
 
-func (file *ObjectFile) AddCompiledCode(code *CompiledCode) {
+// AddCompiledCode adds the code and the codes in the literals.
+// Opcodes refer to literals by indices, so all of them must be written.
+func (file *ObjectFile) AddCompiledCode(code *CompiledCode) error {
 	objCode := NewObjectCode(code.Id, code.Ops)
+	objCode.Params = code.Params
+	objCode.Syms = code.Syms
 	for _, lit := range code.Lits {
-		// TODO: other types
-		switch lit.Type() {
-		case ValueTypeClos:
-			if clos, ok := ValueToClos(lit); ok {
-				if litCode, ok := clos.(*CompiledCode); ok {
-					file.AddCompiledCode(litCode)
-					break
-				}
+		switch lit := lit.(type) {
+		case *Unit:
+			objCode.AddLit(NewObjectValue(ObjectValueTypeUnit, ""))
+		case *Bool:
+			objCode.AddLit(NewObjectValueBool(lit.Value))
+		case *Int:
+			objCode.AddLit(NewObjectValueInt(lit.Value))
+		case *Float:
+			objCode.AddLit(NewObjectValueFloat(lit.Value))
+		case *String:
+			objCode.AddLit(NewObjectValueString(lit.Value))
+		case *CompiledCode:
+			if err := file.AddCompiledCode(lit); err != nil {
+				return err
 			}
-			panic("notimpl")
+			objCode.AddLit(NewObjectValueCode(lit.Id))
+		case *Record:
+			objCode.AddLit(NewObjectValueElts(ObjectValueTypeRecord, lit.Name,
+				NewObjectValueStrings(lit.Fields)...))
+		case *Variant:
+			if len(lit.Values) > 0 {
+				return fmt.Errorf("cannot write literal %s to object file", lit.Desc())
+			}
+			objCode.AddLit(NewObjectValueElts(ObjectValueTypeVariant, lit.Tag,
+				NewObjectValueString(lit.TypeName)))
+		case *Ctor:
+			objCode.AddLit(NewObjectValueElts(ObjectValueTypeCtor, lit.Tag,
+				NewObjectValueString(lit.TypeName), NewObjectValueInt(lit.arity)))
+		case *Method:
+			objCode.AddLit(NewObjectValueElts(ObjectValueTypeMethod, lit.Name,
+				NewObjectValueString(lit.Trait), NewObjectValueInt(lit.arity)))
+		case *Impl:
+			elts := []*ObjectValue{NewObjectValueString(lit.TypeName)}
+			elts = append(elts, NewObjectValueStrings(lit.Names)...)
+			objCode.AddLit(NewObjectValueElts(ObjectValueTypeImpl, lit.Trait, elts...))
+		case *Pattern:
+			objCode.AddLit(NewObjectValueElts(ObjectValueTypePattern, "",
+				encodePtnComp(lit.Comp)))
 		default:
-			break
+			return fmt.Errorf("cannot write literal %s to object file", lit.Desc())
 		}
 	}
 	file.AddCode(objCode)
+	return nil
 }
 
 func NewObjectAttr(name string, value *ObjectValue) *ObjectAttr {
@@ -99,6 +140,26 @@ func NewObjectValue(ty string, value string) *ObjectValue {
 	return &ObjectValue{Type: ty, Value: value}
 }
 
+func NewObjectValueElts(ty string, value string, elts ...*ObjectValue) *ObjectValue {
+	return &ObjectValue{Type: ty, Value: value, Elts: elts}
+}
+
+func NewObjectValueInt(i int) *ObjectValue {
+	return NewObjectValue(ObjectValueTypeInt, strconv.Itoa(i))
+}
+
+func NewObjectValueString(s string) *ObjectValue {
+	return NewObjectValue(ObjectValueTypeString, s)
+}
+
+func NewObjectValueStrings(ss []string) []*ObjectValue {
+	values := make([]*ObjectValue, len(ss))
+	for i, s := range ss {
+		values[i] = NewObjectValueString(s)
+	}
+	return values
+}
+
 func NewObjectValueBool(value bool) *ObjectValue {
 	var s string
 	if value {
@@ -109,8 +170,14 @@ func NewObjectValueBool(value bool) *ObjectValue {
 	return NewObjectValue(ObjectValueTypeBool, s)
 }
 
+// NewObjectValueFloat encodes the float in the shortest form
+// that decodes to the same bits ("+Inf", "-Inf" and "NaN" included).
+func NewObjectValueFloat(f float64) *ObjectValue {
+	return NewObjectValue(ObjectValueTypeFloat, strconv.FormatFloat(f, 'g', -1, 64))
+}
+
 func NewObjectValueCode(i int) *ObjectValue {
-	return NewObjectValue(ObjectValueTypeCode, fmt.Sprintf("%s", i))
+	return NewObjectValue(ObjectValueTypeCode, strconv.Itoa(i))
 }
 
 // Marshal/Unmarshal
@@ -128,46 +195,330 @@ func UnmarshalObjectFile(data []byte) (*ObjectFile, error) {
 	}
 }
 
-func (file *ObjectFile) Decode() *Module {
+func (file *ObjectFile) Decode() (*Module, error) {
 	if file.module != nil {
-		return file.module
+		return file.module, nil
 	}
 
 	m := NewModule(nil, file.Name)
 	for _, objCode := range file.Codes {
-		objCode.Decode(file)
+		if err := objCode.Decode(file); err != nil {
+			return nil, err
+		}
 	}
 	for _, objAttr := range file.Attrs {
-		m.AddAttr(objAttr.Name, objAttr.Value.Decode(file))
+		value, err := objAttr.Value.Decode(file)
+		if err != nil {
+			return nil, err
+		}
+		m.AddAttr(objAttr.Name, value)
 	}
 	file.module = m
-	return m
+	return m, nil
 }
 
-func (objCode *ObjectCode) Decode(file *ObjectFile) {
+func (objCode *ObjectCode) Decode(file *ObjectFile) error {
 	code := NewCompiledCode()
 	code.Id = objCode.Id
+	code.Params = objCode.Params
 	code.Syms = objCode.Syms
 	code.Ops = objCode.Ops
 	file.CodeVals[code.Id] = code
 
 	for _, objVal := range objCode.Lits {
-		value := objVal.Decode(file)
+		value, err := objVal.Decode(file)
+		if err != nil {
+			return err
+		}
 		code.AddLit(value)
 	}
+	return nil
 }
 
-func (value *ObjectValue) Decode(file *ObjectFile) Value {
+func (value *ObjectValue) Decode(file *ObjectFile) (Value, error) {
 	switch value.Type {
 	case ObjectValueTypeUnit:
-		return SharedUnit
+		return SharedUnit, nil
+	case ObjectValueTypeBool:
+		return NewBool(value.Value == "true"), nil
+	case ObjectValueTypeInt:
+		i, err := value.decodeInt()
+		if err != nil {
+			return nil, err
+		}
+		return NewInt(i), nil
+	case ObjectValueTypeFloat:
+		f, err := strconv.ParseFloat(value.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q in object file", value.Value)
+		}
+		return NewFloat(f), nil
 	case ObjectValueTypeString:
-		return NewString(value.Value)
+		return NewString(value.Value), nil
 	case ObjectValueTypeCode:
-		// TODO: error
-		id, _ := strconv.Atoi(value.Value)
-		return file.CodeVals[id]
+		id, err := value.decodeInt()
+		if err != nil {
+			return nil, err
+		}
+		if code, ok := file.CodeVals[id]; ok {
+			return code, nil
+		}
+		return nil, fmt.Errorf("code %d is not found in object file", id)
+	case ObjectValueTypeRecord:
+		return NewRecordTemplate(value.Value, value.decodeStrings(0)), nil
+	case ObjectValueTypeVariant:
+		if len(value.Elts) != 1 {
+			return nil, value.invalid()
+		}
+		return NewVariant(value.Elts[0].Value, value.Value, nil), nil
+	case ObjectValueTypeCtor:
+		if len(value.Elts) != 2 {
+			return nil, value.invalid()
+		}
+		arity, err := value.Elts[1].decodeInt()
+		if err != nil {
+			return nil, err
+		}
+		return NewCtorValue(value.Elts[0].Value, value.Value, arity), nil
+	case ObjectValueTypeMethod:
+		if len(value.Elts) != 2 {
+			return nil, value.invalid()
+		}
+		arity, err := value.Elts[1].decodeInt()
+		if err != nil {
+			return nil, err
+		}
+		return NewMethod(value.Elts[0].Value, value.Value, arity), nil
+	case ObjectValueTypeImpl:
+		if len(value.Elts) < 1 {
+			return nil, value.invalid()
+		}
+		return NewImplTemplate(value.Value, value.Elts[0].Value, value.decodeStrings(1)), nil
+	case ObjectValueTypePattern:
+		if len(value.Elts) != 1 {
+			return nil, value.invalid()
+		}
+		comp, err := decodePtnComp(value.Elts[0])
+		if err != nil {
+			return nil, err
+		}
+		return newPattern(comp), nil
+	default:
+		return nil, fmt.Errorf("unknown value type %q in object file", value.Type)
+	}
+}
+
+func (value *ObjectValue) invalid() error {
+	return fmt.Errorf("invalid %s value in object file", value.Type)
+}
+
+func (value *ObjectValue) decodeInt() (int, error) {
+	i, err := strconv.Atoi(value.Value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer %q in object file", value.Value)
+	}
+	return i, nil
+}
+
+func (value *ObjectValue) decodeStrings(from int) []string {
+	var ss []string
+	for _, elt := range value.Elts[from:] {
+		ss = append(ss, elt.Value)
+	}
+	return ss
+}
+
+// Patterns
+//
+// A pattern component is written as a value of its kind.
+// Literal components are written as the literal values,
+// and the subcomponents are the elements.
+
+var objectPtnRange = "range"   // "..." or "..<", left and right
+var objectPtnList = "list"     // components
+var objectPtnTuple = "tuple"   // components
+var objectPtnCons = "cons"     // head and tail
+var objectPtnRecord = "record" // fields
+var objectPtnField = "field"   // field name, component
+var objectPtnCtor = "ctor"     // tag, type name and components
+var objectPtnSome = "some"     // component
+var objectPtnNone = "none"     // no elements
+var objectPtnVar = "var"       // name
+var objectPtnPin = "pin"       // name
+var objectPtnOr = "or"         // left and right
+var objectPtnAs = "as"         // name, component
+
+func encodePtnComps(comps []ptnComp) []*ObjectValue {
+	values := make([]*ObjectValue, len(comps))
+	for i, comp := range comps {
+		values[i] = encodePtnComp(comp)
+	}
+	return values
+}
+
+func encodePtnComp(comp ptnComp) *ObjectValue {
+	switch p := comp.(type) {
+	case *ptnUnit:
+		return NewObjectValue(ObjectValueTypeUnit, "")
+	case *ptnBool:
+		return NewObjectValueBool(p.v)
+	case *ptnInt:
+		return NewObjectValueInt(p.v)
+	case *ptnFloat:
+		return NewObjectValueFloat(p.v)
+	case *ptnStr:
+		return NewObjectValueString(p.v)
+	case *ptnRange:
+		op := "..<"
+		if p.close {
+			op = "..."
+		}
+		return NewObjectValueElts(objectPtnRange, op,
+			NewObjectValueInt(p.left), NewObjectValueInt(p.right))
+	case *ptnList:
+		return NewObjectValueElts(objectPtnList, "", encodePtnComps(p.comps)...)
+	case *ptnTuple:
+		return NewObjectValueElts(objectPtnTuple, "", encodePtnComps(p.comps)...)
+	case *ptnCons:
+		return NewObjectValueElts(objectPtnCons, "",
+			encodePtnComp(p.head), encodePtnComp(p.tail))
+	case *ptnRecord:
+		fields := make([]*ObjectValue, len(p.fields))
+		for i, field := range p.fields {
+			fields[i] = NewObjectValueElts(objectPtnField, field, encodePtnComp(p.comps[i]))
+		}
+		return NewObjectValueElts(objectPtnRecord, "", fields...)
+	case *ptnCtor:
+		elts := []*ObjectValue{NewObjectValueString(p.typeName)}
+		elts = append(elts, encodePtnComps(p.comps)...)
+		return NewObjectValueElts(objectPtnCtor, p.tag, elts...)
+	case *ptnOpt:
+		if p.comp == nil {
+			return NewObjectValue(objectPtnNone, "")
+		}
+		return NewObjectValueElts(objectPtnSome, "", encodePtnComp(p.comp))
+	case *ptnVar:
+		return NewObjectValue(objectPtnVar, p.Name)
+	case *ptnPin:
+		return NewObjectValue(objectPtnPin, p.Name)
+	case *ptnOr:
+		return NewObjectValueElts(objectPtnOr, "",
+			encodePtnComp(p.left), encodePtnComp(p.right))
+	case *ptnAs:
+		return NewObjectValueElts(objectPtnAs, p.name, encodePtnComp(p.comp))
+	default:
+		panic("unknown pattern component")
+	}
+}
+
+func decodePtnComps(values []*ObjectValue) ([]ptnComp, error) {
+	comps := make([]ptnComp, len(values))
+	for i, value := range values {
+		comp, err := decodePtnComp(value)
+		if err != nil {
+			return nil, err
+		}
+		comps[i] = comp
+	}
+	return comps, nil
+}
+
+func decodePtnComp(value *ObjectValue) (ptnComp, error) {
+	switch value.Type {
+	case ObjectValueTypeUnit:
+		return &ptnUnit{}, nil
+	case ObjectValueTypeBool:
+		return &ptnBool{value.Value == "true"}, nil
+	case ObjectValueTypeInt:
+		i, err := value.decodeInt()
+		if err != nil {
+			return nil, err
+		}
+		return &ptnInt{i}, nil
+	case ObjectValueTypeFloat:
+		f, err := strconv.ParseFloat(value.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q in object file", value.Value)
+		}
+		return &ptnFloat{f}, nil
+	case ObjectValueTypeString:
+		return &ptnStr{value.Value}, nil
+	case objectPtnRange:
+		if len(value.Elts) != 2 {
+			return nil, value.invalid()
+		}
+		left, err := value.Elts[0].decodeInt()
+		if err != nil {
+			return nil, err
+		}
+		right, err := value.Elts[1].decodeInt()
+		if err != nil {
+			return nil, err
+		}
+		return &ptnRange{left: left, right: right, close: value.Value == "..."}, nil
+	case objectPtnList, objectPtnTuple:
+		comps, err := decodePtnComps(value.Elts)
+		if err != nil {
+			return nil, err
+		}
+		if value.Type == objectPtnList {
+			return &ptnList{comps}, nil
+		}
+		return &ptnTuple{comps}, nil
+	case objectPtnCons, objectPtnOr:
+		if len(value.Elts) != 2 {
+			return nil, value.invalid()
+		}
+		comps, err := decodePtnComps(value.Elts)
+		if err != nil {
+			return nil, err
+		}
+		if value.Type == objectPtnCons {
+			return &ptnCons{head: comps[0], tail: comps[1]}, nil
+		}
+		return &ptnOr{left: comps[0], right: comps[1]}, nil
+	case objectPtnRecord:
+		p := &ptnRecord{}
+		for _, field := range value.Elts {
+			if field.Type != objectPtnField || len(field.Elts) != 1 {
+				return nil, value.invalid()
+			}
+			comp, err := decodePtnComp(field.Elts[0])
+			if err != nil {
+				return nil, err
+			}
+			p.fields = append(p.fields, field.Value)
+			p.comps = append(p.comps, comp)
+		}
+		return p, nil
+	case objectPtnCtor:
+		if len(value.Elts) < 1 {
+			return nil, value.invalid()
+		}
+		comps, err := decodePtnComps(value.Elts[1:])
+		if err != nil {
+			return nil, err
+		}
+		return &ptnCtor{typeName: value.Elts[0].Value, tag: value.Value, comps: comps}, nil
+	case objectPtnSome, objectPtnAs:
+		if len(value.Elts) != 1 {
+			return nil, value.invalid()
+		}
+		comp, err := decodePtnComp(value.Elts[0])
+		if err != nil {
+			return nil, err
+		}
+		if value.Type == objectPtnSome {
+			return &ptnOpt{comp}, nil
+		}
+		return &ptnAs{comp: comp, name: value.Value}, nil
+	case objectPtnNone:
+		return &ptnOpt{}, nil
+	case objectPtnVar:
+		return &ptnVar{value.Value}, nil
+	case objectPtnPin:
+		return &ptnPin{value.Value}, nil
 	default:
-		panic("notimpl")
+		return nil, fmt.Errorf("unknown pattern type %q in object file", value.Type)
 	}
 }
@@ -0,0 +1,125 @@
+package trompe
+
+import (
+	"testing"
+)
+
+func TestObjectFileLiterals(t *testing.T) {
+	Init()
+	file := NewObjectFile("test")
+	code := NewCompiledCode()
+	code.AddLit(NewFloat(0.1))
+	code.AddLit(NewInt(-3))
+	code.AddLit(NewString("a"))
+	if err := file.AddCompiledCode(code); err != nil {
+		t.Fatal(err)
+	}
+	data, err := file.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalObjectFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded.CodeVals = make(map[int]*CompiledCode)
+	if _, err := decoded.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	lits := decoded.CodeVals[code.Id].Lits
+	for i, want := range []string{"0.1", "-3", "a"} {
+		if lits[i].Desc() != want {
+			t.Errorf("got %s, want %s", lits[i].Desc(), want)
+		}
+	}
+
+	code = NewCompiledCode()
+	code.AddLit(NewList(NewInt(1)))
+	if err := file.AddCompiledCode(code); err == nil {
+		t.Errorf("list literal is written")
+	}
+}
+
+// writeRead writes the code to an object file and reads it again.
+func writeRead(t *testing.T, code *CompiledCode) *CompiledCode {
+	t.Helper()
+	file := NewObjectFile("test")
+	if err := file.AddCompiledCode(code); err != nil {
+		t.Fatal(err)
+	}
+	data, err := file.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalObjectFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded.CodeVals = make(map[int]*CompiledCode)
+	if _, err := decoded.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	return decoded.CodeVals[code.Id]
+}
+
+func TestObjectFileProgram(t *testing.T) {
+	shape := &TypeDeclNode{Name: tok("shape"), Ctors: []CtorDeclNode{
+		{Name: tok("Circle"), Types: []TypeNode{named("int")}},
+		{Name: tok("Empty")},
+	}}
+	area := impl("Area", "point", sdef("area", []string{"p"},
+		bin(attr(vr("p"), "x"), "*", attr(vr("p"), "y"))))
+	radius := sdef("radius", []string{"s"}, caseOf(vr("s"),
+		clau(ctorp("Circle", vp("n")), nil, vr("n")),
+		clau(ctorp("Empty"), nil, in("0"))))
+	stats := []Node{shape, pointDecl(), areaTrait(), area, radius,
+		let(tup(vp("a"), vp("b")), tupe(in("1"), in("2"))),
+		bin(bin(call(vr("radius"), call(vr("Circle"), vr("a"))), "+",
+			call(vr("area"), point("2", "3"))), "+",
+			bin(vr("b"), "+", call(vr("radius"), vr("Empty"))))}
+
+	info := check(t, 0, stats...)
+	code, diags := Compile("test", blk(stats...), info)
+	if len(diags) > 0 {
+		t.Fatalf("%v", diags)
+	}
+	v, err := Run("test", writeRead(t, code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Desc() != "9" {
+		t.Errorf("got %s, want 9", v.Desc())
+	}
+}
+
+func TestObjectFilePatterns(t *testing.T) {
+	Init()
+	field := &RecordPtnNode{Fields: []FieldPtnNode{{Name: tok("x"), Ptn: ip("1")}, {Name: tok("y")}}}
+	ptns := []PtnNode{
+		tup(&UnitPtnNode{}, bp(true), ip("-3"), &FloatPtnNode{Value: tok("0.5")}, sp("a")),
+		&RangePtnNode{Left: ip("1"), Right: ip("5"), Close: true},
+		orp(lptn(vp("x"), pin("y")), cons(vp("h"), vp("_"))),
+		&AsPtnNode{Ptn: somep(ctorp("Circle", vp("n"))), Name: tok("s")},
+		nonep(),
+		field,
+	}
+	for _, n := range ptns {
+		ptn := NewPatternFromNode(n, map[*CtorPtnNode]string{})
+		code := NewCompiledCode()
+		code.AddLit(ptn)
+		lit := writeRead(t, code).Lits[0]
+		if lit.Desc() != ptn.Desc() {
+			t.Errorf("got %s, want %s", lit.Desc(), ptn.Desc())
+		}
+	}
+
+	data := []byte(`{"name": "test", "codes": [{"id": 1, "literals": [{"type": "int", "value": "x"}]}]}`)
+	file, err := UnmarshalObjectFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.CodeVals = make(map[int]*CompiledCode)
+	if _, err := file.Decode(); err == nil {
+		t.Errorf("invalid integer is decoded")
+	}
+}
@@ -18,9 +18,9 @@ const (
 	OpLoadAttr  // index of literal string
 	OpLoadArg   // index
 	OpLoadModule
-	OpStoreLocal // index of literal string
-	OpStoreRef
-	OpStoreAttr // index of literal string
+	OpStoreLocal // index of symbol
+	OpStoreRef   // set the value to the box
+	OpStoreAttr  // index of literal string
 	OpPop
 	OpDup
 	OpReturn
@@ -52,6 +52,32 @@ const (
 	OpClosedRange
 	OpHalfOpenRange
 	OpIter
+
+	// Opcodes added later are appended here
+	// so that the numbers of the opcodes above do not change.
+	OpTry // label of the rescue clauses
+	OpEndTry
+	OpCallMethod // index of symbol, length including the receiver
+	OpPartial    // length, bit mask of placeholders
+	OpRaise
+	OpPipe
+	OpFloorDiv
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpNeg
+	OpNot
+	OpBitNot
+	OpShow   // converts the value to a string with Show
+	OpConcat // number of strings
+	OpIndex
+	OpCons
+	OpRecord       // index of record template
+	OpUpdateRecord // index of record template
+	OpImpl         // index of impl template
+	OpClosure      // index of compiled code
 )
 
 const (
@@ -97,6 +123,8 @@ func GetOpName(op int) string {
 		return "OpStoreAttr"
 	case OpPop:
 		return "OpPop"
+	case OpDup:
+		return "OpDup"
 	case OpReturn:
 		return "OpReturn"
 	case OpReturnUnit:
@@ -115,10 +143,22 @@ func GetOpName(op int) string {
 		return "OpBegin"
 	case OpEnd:
 		return "OpEnd"
+	case OpTry:
+		return "OpTry"
+	case OpEndTry:
+		return "OpEndTry"
 	case OpCall:
 		return "OpCall"
+	case OpCallMethod:
+		return "OpCallMethod"
+	case OpPartial:
+		return "OpPartial"
+	case OpPipe:
+		return "OpPipe"
 	case OpPanic:
 		return "OpPanic"
+	case OpRaise:
+		return "OpRaise"
 	case OpEq:
 		return "OpEq"
 	case OpNe:
@@ -143,10 +183,36 @@ func GetOpName(op int) string {
 		return "OpDiv"
 	case OpMod:
 		return "OpMod"
+	case OpFloorDiv:
+		return "OpFloorDiv"
+	case OpBitAnd:
+		return "OpBitAnd"
+	case OpBitOr:
+		return "OpBitOr"
+	case OpBitXor:
+		return "OpBitXor"
+	case OpShiftLeft:
+		return "OpShiftLeft"
+	case OpShiftRight:
+		return "OpShiftRight"
+	case OpNeg:
+		return "OpNeg"
+	case OpNot:
+		return "OpNot"
+	case OpBitNot:
+		return "OpBitNot"
+	case OpShow:
+		return "OpShow"
+	case OpConcat:
+		return "OpConcat"
+	case OpIndex:
+		return "OpIndex"
 	case OpSome:
 		return "OpSome"
 	case OpList:
 		return "OpList"
+	case OpCons:
+		return "OpCons"
 	case OpTuple:
 		return "OpTuple"
 	case OpClosedRange:
@@ -155,6 +221,14 @@ func GetOpName(op int) string {
 		return "OpHalfOpenRange"
 	case OpIter:
 		return "OpIter"
+	case OpRecord:
+		return "OpRecord"
+	case OpUpdateRecord:
+		return "OpUpdateRecord"
+	case OpImpl:
+		return "OpImpl"
+	case OpClosure:
+		return "OpClosure"
 	default:
 		panic("unknown opcode")
 	}
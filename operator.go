@@ -0,0 +1,55 @@
+package trompe
+
+// associativity of operators
+const (
+	AssocLeft = iota
+	AssocRight
+	AssocNone
+)
+
+// Fixity is the associativity and the precedence of a binary operator.
+// Operators with higher precedences bind tighter.
+type Fixity struct {
+	Assoc int
+	Prec  int
+}
+
+// MaxPrec is the highest precedence that operators can declare.
+const MaxPrec = 13
+
+// DefaultFixity is the fixity of user-defined operators without declarations.
+var DefaultFixity = Fixity{Assoc: AssocLeft, Prec: MaxPrec}
+
+// BuiltinFixities are the fixities of the built-in binary operators.
+// They are consistent with the grammar.
+var BuiltinFixities = map[string]Fixity{
+	"*":   {AssocLeft, 12},
+	"/":   {AssocLeft, 12},
+	"//":  {AssocLeft, 12},
+	"%":   {AssocLeft, 12},
+	"+":   {AssocLeft, 11},
+	"-":   {AssocLeft, 11},
+	"::":  {AssocRight, 10},
+	"...": {AssocLeft, 9},
+	"..<": {AssocLeft, 9},
+	"<<":  {AssocLeft, 8},
+	">>":  {AssocLeft, 8},
+	"&":   {AssocLeft, 7},
+	"~":   {AssocLeft, 6},
+	"|":   {AssocLeft, 5},
+	"==":  {AssocLeft, 4},
+	"!=":  {AssocLeft, 4},
+	"<":   {AssocLeft, 4},
+	"<=":  {AssocLeft, 4},
+	">":   {AssocLeft, 4},
+	">=":  {AssocLeft, 4},
+	"and": {AssocLeft, 3},
+	"or":  {AssocLeft, 2},
+	"|>":  {AssocLeft, 1},
+}
+
+// AddFixity declares the fixity of the operator defined in the module.
+// The parser applies fixities of the opened modules.
+func (m *Module) AddFixity(op string, fixity Fixity) {
+	m.Fixities[op] = fixity
+}
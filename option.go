@@ -1,21 +1,35 @@
 package trompe
 
-import (
-	"fmt"
+// Options are variants of the library type:
+//
+//	type option<a> = None | Some(a)
+const (
+	OptionTypeName = "option"
+	OptionNoneTag  = "None"
+	OptionSomeTag  = "Some"
 )
 
-type Option struct {
-	Value Value // nullable
+func NewOption(v Value) *Variant {
+	return NewVariant(OptionTypeName, OptionSomeTag, []Value{v})
 }
 
-func NewOption(v Value) *Option {
-	return &Option{v}
+// variantTagDesc returns the tag of the variant as written in programs.
+// Options are written with the keywords some and none.
+func variantTagDesc(typeName string, tag string) string {
+	if typeName == OptionTypeName {
+		switch tag {
+		case OptionNoneTag:
+			return "none"
+		case OptionSomeTag:
+			return "some"
+		}
+	}
+	return tag
 }
 
-func (o *Option) Type() int {
-	return ValueTypeOption
-}
-
-func (o *Option) Desc() string {
-	return fmt.Sprintf("<option %s>", o.Value.Desc())
+func ValueToOption(v Value) (*Variant, bool) {
+	if v, ok := ValueToVariant(v); ok && v.TypeName == OptionTypeName {
+		return v, true
+	}
+	return nil, false
 }
@@ -12,11 +12,22 @@ stat
     : ';'
     | letdecl
     | fundef
-    | funcall
+    | assign
     | doblock
     | for_
     | if_
     | case_
+    | try_
+    | structdecl
+    | typedecl
+    | traitdecl
+    | impldecl
+    | fixitydecl
+    | exp
+    ;
+
+assign
+    : exp arrow='<-' exp
     ;
 
 retstat
@@ -28,16 +39,64 @@ doblock
     ;
 
 letdecl
-    : 'let' pattern '=' exp
+    : 'let' pattern (':' typeexp)? '=' exp ('else' block 'end')?
     ;
 
 fundef
-    : 'def' NAME '(' parlist? ')' block 'end'
-    | 'def' NAME '(' parlist? ')' '=' exp
+    : 'def' funname '(' parlist? ')' (':' typeexp)? block 'end'
+    | 'def' funname '(' parlist? ')' (':' typeexp)? '=' exp
+    ;
+
+funname
+    : NAME
+    | '(' OPERATOR ')'
+    ;
+
+fixitydecl
+    : assoc=('infixl' | 'infixr' | 'infix') INT OPERATOR+
     ;
 
 parlist
-    : NAME (',' NAME)*
+    : param (',' param)*
+    ;
+
+param
+    : NAME (':' typeexp)?
+    ;
+
+typeexp
+    : NAME ('<' typeexp (',' typeexp)* '>')?
+    | typeexp q='?'
+    | o='(' (typeexp (',' typeexp)*)? c=')'
+    | <assoc=right> typeexp arrow='->' typeexp
+    ;
+
+structdecl
+    : 'struct' NAME fielddecl* 'end'
+    ;
+
+fielddecl
+    : NAME ':' typeexp
+    ;
+
+typedecl
+    : 'type' NAME ('<' NAME (',' NAME)* '>')? eq='=' '|'? ctordecl ('|' ctordecl)*
+    ;
+
+ctordecl
+    : NAME ('(' typeexp (',' typeexp)* ')')?
+    ;
+
+traitdecl
+    : 'trait' NAME '<' NAME '>' methodsig* 'end'
+    ;
+
+methodsig
+    : 'def' NAME '(' parlist? ')' ':' typeexp
+    ;
+
+impldecl
+    : 'impl' NAME '<' typeexp '>' fundef* 'end'
     ;
 
 for_
@@ -49,7 +108,7 @@ if_
     ;
 
 case_
-    : 'case' exp 'of' caseclau? ('else' block)? 'end'
+    : 'case' exp 'of' caseclau* ('else' block)? 'end'
     ;
 
 caseclau
@@ -60,18 +119,50 @@ guard
     : 'in' exp
     ;
 
+try_
+    : 'try' block rescueclau* ('ensure' block)? 'end'
+    ;
+
+rescueclau
+    : 'rescue' pattern 'then' block
+    ;
+
 pattern
     : unit
     | bool_
-    | int_
-    | float_
+    | neg='-'? int_
+    | neg='-'? float_
     | string_
     | pattern rangeop pattern
+    | <assoc=right> pattern cons='::' pattern
+    | pattern bar='|' pattern
+    | pattern 'as' NAME
     | '[' patlist? ']'
     | '(' patlist? ')'
+    | recordptn
+    | ctorptn
+    | optptn
+    | pin='^' NAME
     | NAME
     ;
 
+ctorptn
+    : NAME o='(' patlist? c=')'
+    ;
+
+optptn
+    : some='some' '(' pattern ')'
+    | 'none'
+    ;
+
+recordptn
+    : '{' (NAME ':')? fieldptn (',' fieldptn)* ','? '}'
+    ;
+
+fieldptn
+    : NAME ('=' pattern)?
+    ;
+
 patlist
     : pattern (',' pattern)*
     ;
@@ -79,17 +170,24 @@ patlist
 exp
     : simpleexp
     | funcall
-    /*
-    | <assoc=right> exp operatorPower exp
-    | operatorUnary exp
-    | exp operatorMulDivMod exp
-    | exp operatorAddSub exp
-    | exp operatorComparison exp
-    | exp operatorAnd exp
-    | exp operatorOr exp
-    | exp operatorBitwise exp
-    */
+    | obj=exp o='[' idx=exp c=']'
+    | obj=exp dot='.' NAME arglist
+    | obj=exp dot='.' NAME
+    | operatorUnary operand=exp
+    | left=exp operatorMulDivMod right=exp
+    | left=exp operatorAddSub right=exp
+    | <assoc=right> left=exp operatorCons right=exp
     | left=exp rangeop right=exp
+    | left=exp operatorShift right=exp
+    | left=exp operatorBitAnd right=exp
+    | left=exp operatorBitXor right=exp
+    | left=exp operatorBitOr right=exp
+    | left=exp operatorComparison right=exp
+    | left=exp operatorAnd right=exp
+    | left=exp operatorOr right=exp
+    | left=exp operatorPipe right=exp
+    | left=exp operatorUser right=exp
+    | 'raise' operand=exp
     ;
 
 parenexp
@@ -108,11 +206,22 @@ simpleexp
     | tuple
     | tableconstructor
     | anonfun
+    | optexp
     | statexp
     | var_
+    | opsection
     | parenexp
     ;
 
+opsection
+    : '(' OPERATOR ')'
+    ;
+
+optexp
+    : some='some' '(' exp ')'
+    | 'none'
+    ;
+
 funcall
     : simpleexp arglist
     ;
@@ -146,7 +255,8 @@ eltlist
     ;
 
 tableconstructor
-    : '{' fieldlist? '}'
+    : '{' (NAME ':')? fieldlist? '}'
+    | '{' exp 'with' fieldlist '}'
     ;
 
 fieldlist
@@ -154,7 +264,7 @@ fieldlist
     ;
 
 field
-    : NAME '=' exp
+    : NAME ('=' exp)?
     ;
 
 anonfun
@@ -165,6 +275,13 @@ statexp
     : '[' stat ']'
     ;
 
+// user-defined operators are re-associated by their fixities
+operatorUser
+	: OPERATOR;
+
+operatorPipe
+	: '|>';
+
 operatorOr
 	: 'or';
 
@@ -172,7 +289,23 @@ operatorAnd
 	: 'and';
 
 operatorComparison
-	: '<' | '>' | '<=' | '>=' | '~=' | '==';
+	: '<' | '>' | '<=' | '>=' | '!=' | '==';
+
+operatorBitOr
+	: '|';
+
+operatorBitXor
+	: '~';
+
+operatorBitAnd
+	: '&';
+
+// not tokens '<<' and '>>' to lex nested type arguments such as list<list<int>>
+operatorShift
+	: '<' '<' | '>' '>';
+
+operatorCons
+	: '::';
 
 operatorAddSub
 	: '+' | '-';
@@ -180,14 +313,8 @@ operatorAddSub
 operatorMulDivMod
 	: '*' | '/' | '%' | '//';
 
-operatorBitwise
-	: '&' | '|' | '~' | '<<' | '>>';
-
 operatorUnary
-    : '#' | '-' | '~';
-
-operatorPower
-    : '^';
+    : '-' | 'not' | '~';
 
 unit
     : '(' ')'
@@ -230,7 +357,7 @@ NAME
     ;
 
 NORMALSTRING
-    : '"' ( EscapeSequence | ~('\\'|'"') )* '"'
+    : '"' ( EscapeSequence | Interpolation | ~('\\'|'"') )* '"'
     ;
 
 CHARSTRING
@@ -241,6 +368,17 @@ LONGSTRING
     : '[' NESTED_STR ']'
     ;
 
+// "#{exp}" in NORMALSTRING. Strings in the expression may contain quotes.
+fragment
+Interpolation
+    : '#{' ( NORMALSTRING | CHARSTRING | InterpolationBlock | ~('{'|'}'|'"'|'\'') )* '}'
+    ;
+
+fragment
+InterpolationBlock
+    : '{' ( NORMALSTRING | CHARSTRING | InterpolationBlock | ~('{'|'}'|'"'|'\'') )* '}'
+    ;
+
 fragment
 NESTED_STR
     : '=' NESTED_STR '='
@@ -282,7 +420,7 @@ HexExponentPart
 
 fragment
 EscapeSequence
-    : '\\' [abfnrtvz"'\\]
+    : '\\' [abfnrtvz"'\\#]
     | '\\' '\r'? '\n'
     | DecimalEscape
     | HexEscape
@@ -326,6 +464,26 @@ LINE_COMMENT
     -> channel(HIDDEN)
     ;
 
+// Declared after the comments so that "--" begins a comment.
+// Operators consisting of only '<' and '>' are not lexed as one token
+// to lex nested type arguments such as list<list<int>>.
+// Operators do not end with the unary operators '-' and '~'
+// to lex "n*-1" as "n * -1".
+OPERATOR
+    : [<>]* OperatorLastChar
+    | [<>]* OperatorChar ( OperatorChar | [<>] )* ( OperatorLastChar | [<>] )
+    ;
+
+fragment
+OperatorChar
+    : [+\-*/%&|^!$@~=]
+    ;
+
+fragment
+OperatorLastChar
+    : [+*/%&|^!$@=]
+    ;
+
 WS
     : [ \t\u000C\r\n]+ -> skip
     ;
@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+	. "github.com/szktty/trompe"
+)
+
+// ErrorListener collects syntax errors reported by the lexer and the parser.
+type ErrorListener struct {
+	*antlr.DefaultErrorListener
+	Source *Source
+	Diags  []Diagnostic
+}
+
+func NewErrorListener(src *Source) *ErrorListener {
+	return &ErrorListener{
+		DefaultErrorListener: antlr.NewDefaultErrorListener(),
+		Source:               src,
+	}
+}
+
+func (l *ErrorListener) SyntaxError(recognizer antlr.Recognizer,
+	offendingSymbol interface{},
+	line, column int,
+	msg string,
+	e antlr.RecognitionException) {
+	var loc Loc
+	if tok, ok := offendingSymbol.(antlr.Token); ok && tok != nil {
+		loc = NewLocAntlr(tok)
+	} else {
+		pos := Pos{Line: line, Col: column}
+		if lexer, ok := recognizer.(antlr.Lexer); ok {
+			if s, ok := lexer.GetInputStream().(*SourceStream); ok {
+				pos = s.SourcePos(pos)
+			}
+		}
+		loc = Loc{File: l.Source.Id, Start: pos, End: pos}
+	}
+	l.Diags = append(l.Diags, NewDiagnostic(l.Source.Name, loc, msg))
+}
+
+// listenerError is raised by listeners for the syntax that cannot
+// be converted to a node. Parse recovers it.
+type listenerError struct {
+	loc Loc
+	msg string
+}
+
+func unsupported(ctx antlr.ParserRuleContext, kind string) {
+	panic(listenerError{
+		loc: NewLocAntlr(ctx.GetStart()),
+		msg: fmt.Sprintf("unsupported %s: %s", kind, ctx.GetText()),
+	})
+}
+
+func syntaxError(loc Loc, format string, args ...interface{}) {
+	panic(listenerError{loc: loc, msg: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+	. "github.com/szktty/trompe"
+)
+
+// newFixities returns the fixities of the user-defined operators
+// declared in the opened modules. Each parse has its own table in the
+// source stream, and declarations in the source are added to it to
+// apply to the following expressions.
+func newFixities() map[string]Fixity {
+	fixities := make(map[string]Fixity, 8)
+	for _, m := range OpenedModules {
+		for op, fixity := range m.Fixities {
+			fixities[op] = fixity
+		}
+	}
+	return fixities
+}
+
+// fixitiesOf returns the fixity table of the parse reading the token.
+func fixitiesOf(tok antlr.Token) map[string]Fixity {
+	if s, ok := tok.GetInputStream().(*SourceStream); ok && s.Fixities != nil {
+		return s.Fixities
+	}
+	return newFixities()
+}
+
+func fixityOf(fixities map[string]Fixity, op string) Fixity {
+	if fixity, ok := BuiltinFixities[op]; ok {
+		return fixity
+	} else if fixity, ok := fixities[op]; ok {
+		return fixity
+	}
+	return DefaultFixity
+}
+
+// reassoc rebuilds the chain of binary operations by the fixities
+// of the operators. The grammar parses user-defined operators with
+// the lowest precedence, so the operation is the root of the chain.
+// Parenthesized expressions are not re-associated.
+func reassoc(node Node, fixities map[string]Fixity) Node {
+	r := &reassociator{fixities: fixities}
+	r.flatten(node)
+	return r.exp(0, nil)
+}
+
+type reassociator struct {
+	fixities map[string]Fixity
+	operands []Node
+	ops      []Node // *BinaryExpNode or *RangeExpNode as templates
+	i        int
+}
+
+func (r *reassociator) flatten(node Node) {
+	switch node := node.(type) {
+	case *BinaryExpNode:
+		r.flatten(node.Left)
+		r.ops = append(r.ops, node)
+		r.flatten(node.Right)
+	case *RangeExpNode:
+		r.flatten(node.Left)
+		r.ops = append(r.ops, node)
+		r.flatten(node.Right)
+	default:
+		r.operands = append(r.operands, node)
+	}
+}
+
+// exp is precedence climbing. last is the fixity of the previous
+// operator at the same level to check mixing associativities.
+func (r *reassociator) exp(minPrec int, last *Fixity) Node {
+	lhs := r.operands[r.i]
+	for r.i < len(r.ops) {
+		op := r.ops[r.i]
+		tok := opToken(op)
+		fixity := fixityOf(r.fixities, tok.Text)
+		if fixity.Prec < minPrec {
+			break
+		}
+		if last != nil && last.Prec == fixity.Prec &&
+			(last.Assoc != fixity.Assoc || fixity.Assoc == AssocNone) {
+			syntaxError(tok.Loc, "cannot mix operators of the same precedence %d "+
+				"with different or no associativity at %s", fixity.Prec, tok.Text)
+		}
+		r.i++
+		next := fixity.Prec + 1
+		if fixity.Assoc == AssocRight {
+			next = fixity.Prec
+		}
+		rhs := r.exp(next, &fixity)
+		lhs = rebuild(op, lhs, rhs)
+		last = &fixity
+	}
+	return lhs
+}
+
+func opToken(op Node) Token {
+	switch op := op.(type) {
+	case *BinaryExpNode:
+		return op.Op
+	case *RangeExpNode:
+		return op.Op
+	default:
+		panic("not operator")
+	}
+}
+
+func rebuild(op Node, left Node, right Node) Node {
+	switch op := op.(type) {
+	case *BinaryExpNode:
+		return &BinaryExpNode{Left: left, Op: op.Op, Right: right, Fun: op.Fun}
+	case *RangeExpNode:
+		return &RangeExpNode{Left: left, Op: op.Op, Close: op.Close, Right: right}
+	default:
+		panic("not operator")
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"github.com/antlr/antlr4/runtime/Go/antlr"
 	. "github.com/szktty/trompe"
+	"io"
+	"io/ioutil"
+	"strconv"
 )
 
 type ChunkListener struct {
@@ -21,7 +24,7 @@ func (l *ChunkListener) EnterChunk(ctx *ChunkContext) {
 	if blockCtx != nil {
 		block := NewBlockListener()
 		blockCtx.EnterRule(block)
-		l.Node = ChunkNode{Block: &block.Node}
+		l.Node = NewChunkNode(NewLocAntlr(ctx.GetStart()), &block.Node)
 	}
 }
 
@@ -40,12 +43,34 @@ func (l *BlockListener) EnterBlock(ctx *BlockContext) {
 	for _, statCtx := range ctx.AllStat() {
 		stat := NewStatListener()
 		statCtx.EnterRule(stat)
-		if stat.Node == nil {
-			panic(fmt.Sprintf("stat not found: %s", statCtx.GetText()))
+		if stat.Node != nil {
+			stats = append(stats, stat.Node)
 		}
-		stats = append(stats, stat.Node)
 	}
-	l.Node = BlockNode{Stats: stats}
+	if retCtx := ctx.Retstat(); retCtx != nil {
+		ret := NewRetstatListener()
+		retCtx.EnterRule(ret)
+		stats = append(stats, &ret.Node)
+	}
+	l.Node = NewBlockNode(NewLocAntlr(ctx.GetStart()), stats)
+}
+
+type RetstatListener struct {
+	*BaseTrompeListener
+	Node RetStatNode
+}
+
+func NewRetstatListener() *RetstatListener {
+	return new(RetstatListener)
+}
+
+func (l *RetstatListener) EnterRetstat(ctx *RetstatContext) {
+	l.Node = RetStatNode{Ret: NewLocAntlr(ctx.GetStart())}
+	if expCtx := ctx.Exp(); expCtx != nil {
+		exp := NewExpListener()
+		expCtx.EnterRule(exp)
+		l.Node.Exp = exp.Node
+	}
 }
 
 type StatListener struct {
@@ -59,16 +84,481 @@ func NewStatListener() *StatListener {
 
 func (l *StatListener) EnterStat(ctx *StatContext) {
 	fmt.Printf("enter stat\n")
-	if funcallCtx := ctx.Funcall(); funcallCtx != nil {
-		funcall := NewFuncallListener()
-		funcallCtx.EnterRule(funcall)
-		l.Node = &funcall.Node
+	if assignCtx := ctx.Assign(); assignCtx != nil {
+		assign := NewAssignListener()
+		assignCtx.EnterRule(assign)
+		l.Node = &assign.Node
 	} else if forCtx := ctx.For_(); forCtx != nil {
 		for_ := NewForStatListener()
 		forCtx.EnterRule(for_)
 		l.Node = &for_.Node
+	} else if letCtx := ctx.Letdecl(); letCtx != nil {
+		let := NewLetdeclListener()
+		letCtx.EnterRule(let)
+		l.Node = &let.Node
+	} else if defCtx := ctx.Fundef(); defCtx != nil {
+		def := NewFundefListener()
+		defCtx.EnterRule(def)
+		l.Node = def.Node
+	} else if structCtx := ctx.Structdecl(); structCtx != nil {
+		struct_ := NewStructdeclListener()
+		structCtx.EnterRule(struct_)
+		l.Node = &struct_.Node
+	} else if typeCtx := ctx.Typedecl(); typeCtx != nil {
+		type_ := NewTypedeclListener()
+		typeCtx.EnterRule(type_)
+		l.Node = &type_.Node
+	} else if traitCtx := ctx.Traitdecl(); traitCtx != nil {
+		trait := NewTraitdeclListener()
+		traitCtx.EnterRule(trait)
+		l.Node = &trait.Node
+	} else if implCtx := ctx.Impldecl(); implCtx != nil {
+		impl := NewImpldeclListener()
+		implCtx.EnterRule(impl)
+		l.Node = &impl.Node
+	} else if fixityCtx := ctx.Fixitydecl(); fixityCtx != nil {
+		fixity := NewFixitydeclListener()
+		fixityCtx.EnterRule(fixity)
+		l.Node = &fixity.Node
+	} else if doCtx := ctx.Doblock(); doCtx != nil {
+		do := NewDoblockListener()
+		doCtx.EnterRule(do)
+		l.Node = &do.Node
+	} else if ifCtx := ctx.If_(); ifCtx != nil {
+		if_ := NewIfStatListener()
+		ifCtx.EnterRule(if_)
+		l.Node = &if_.Node
+	} else if caseCtx := ctx.Case_(); caseCtx != nil {
+		case_ := NewCaseStatListener()
+		caseCtx.EnterRule(case_)
+		l.Node = &case_.Node
+	} else if tryCtx := ctx.Try_(); tryCtx != nil {
+		try_ := NewTryStatListener()
+		tryCtx.EnterRule(try_)
+		l.Node = &try_.Node
+	} else if expCtx := ctx.Exp(); expCtx != nil {
+		exp := NewExpListener()
+		expCtx.EnterRule(exp)
+		l.Node = exp.Node
+	} else if ctx.GetText() != ";" {
+		unsupported(ctx, "statement")
+	}
+}
+
+type LetdeclListener struct {
+	*BaseTrompeListener
+	Node LetStatNode
+}
+
+func NewLetdeclListener() *LetdeclListener {
+	return new(LetdeclListener)
+}
+
+func (l *LetdeclListener) EnterLetdecl(ctx *LetdeclContext) {
+	ptn := NewPatternListener()
+	ctx.Pattern().EnterRule(ptn)
+
+	var ty TypeNode
+	if tyCtx := ctx.Typeexp(); tyCtx != nil {
+		tyExp := NewTypeexpListener()
+		tyCtx.EnterRule(tyExp)
+		ty = tyExp.Node
+	}
+
+	exp := NewExpListener()
+	ctx.Exp().EnterRule(exp)
+
+	l.Node = LetStatNode{
+		Let:  NewLocAntlr(ctx.GetStart()),
+		Ptn:  ptn.Node,
+		Type: ty,
+		Eq:   terminalLoc(ctx, "="),
+		Exp:  exp.Node,
+	}
+	if elses := terminalLocs(ctx, "else"); len(elses) > 0 {
+		block := NewBlockListener()
+		ctx.Block().EnterRule(block)
+		end := terminalLoc(ctx, "end")
+		l.Node.Else = &elses[0]
+		l.Node.ElseAction = &block.Node
+		l.Node.End = &end
+	}
+}
+
+type FundefListener struct {
+	*BaseTrompeListener
+	Node StatNode
+}
+
+func NewFundefListener() *FundefListener {
+	return new(FundefListener)
+}
+
+func (l *FundefListener) EnterFundef(ctx *FundefContext) {
+	def := NewLocAntlr(ctx.GetStart())
+	funname := NewFunnameListener()
+	ctx.Funname().EnterRule(funname)
+	name := funname.Name
+	open := terminalLoc(ctx, "(")
+	close := terminalLoc(ctx, ")")
+
+	params := NewParlistListener()
+	if parsCtx := ctx.Parlist(); parsCtx != nil {
+		parsCtx.EnterRule(params)
+	}
+	if funname.Operator && len(params.Node.Names) != 2 {
+		syntaxError(name.Loc, "operator %s must take two parameters", name.Text)
+	}
+
+	var retType TypeNode
+	if tyCtx := ctx.Typeexp(); tyCtx != nil {
+		tyExp := NewTypeexpListener()
+		tyCtx.EnterRule(tyExp)
+		retType = tyExp.Node
+	}
+
+	if blockCtx := ctx.Block(); blockCtx != nil {
+		block := NewBlockListener()
+		blockCtx.EnterRule(block)
+		l.Node = &DefStatNode{
+			Def:     def,
+			Name:    name,
+			Open:    open,
+			Params:  &params.Node,
+			Close:   close,
+			RetType: retType,
+			Block:   block.Node,
+			End:     NewLocAntlr(ctx.GetStop()),
+		}
+	} else {
+		exp := NewExpListener()
+		ctx.Exp().EnterRule(exp)
+		l.Node = &ShortDefStatNode{
+			Def:     def,
+			Name:    name,
+			Open:    open,
+			Params:  &params.Node,
+			Close:   close,
+			RetType: retType,
+			Eq:      terminalLoc(ctx, "="),
+			Exp:     exp.Node,
+		}
+	}
+}
+
+type FunnameListener struct {
+	*BaseTrompeListener
+	Name     Token
+	Operator bool
+}
+
+func NewFunnameListener() *FunnameListener {
+	return new(FunnameListener)
+}
+
+func (l *FunnameListener) EnterFunname(ctx *FunnameContext) {
+	if op := ctx.OPERATOR(); op != nil {
+		l.Name = NewTokenAntlr(op.GetSymbol())
+		l.Operator = true
+	} else {
+		l.Name = NewTokenAntlr(ctx.NAME().GetSymbol())
+	}
+}
+
+type FixitydeclListener struct {
+	*BaseTrompeListener
+	Node FixityDeclNode
+}
+
+func NewFixitydeclListener() *FixitydeclListener {
+	return new(FixitydeclListener)
+}
+
+func (l *FixitydeclListener) EnterFixitydecl(ctx *FixitydeclContext) {
+	l.Node.Assoc = NewTokenAntlr(ctx.GetAssoc())
+	l.Node.Prec = NewTokenAntlr(ctx.INT().GetSymbol())
+	prec, err := strconv.Atoi(l.Node.Prec.Text)
+	if err != nil || prec < 0 || prec > MaxPrec {
+		syntaxError(l.Node.Prec.Loc, "precedence must be between 0 and %d", MaxPrec)
+	}
+	fixity := Fixity{Assoc: AssocLeft, Prec: prec}
+	switch l.Node.Assoc.Text {
+	case "infixr":
+		fixity.Assoc = AssocRight
+	case "infix":
+		fixity.Assoc = AssocNone
+	}
+	fixities := fixitiesOf(ctx.GetStart())
+	for _, opCtx := range ctx.AllOPERATOR() {
+		op := NewTokenAntlr(opCtx.GetSymbol())
+		l.Node.Ops = append(l.Node.Ops, op)
+		fixities[op.Text] = fixity
+	}
+}
+
+type ParlistListener struct {
+	*BaseTrompeListener
+	Node ParamListNode
+}
+
+func NewParlistListener() *ParlistListener {
+	return new(ParlistListener)
+}
+
+func (l *ParlistListener) EnterParlist(ctx *ParlistContext) {
+	for _, paramCtx := range ctx.AllParam() {
+		param := NewParamListener()
+		paramCtx.EnterRule(param)
+		l.Node.Names = append(l.Node.Names, param.Name)
+		l.Node.Types = append(l.Node.Types, param.Type)
+	}
+	l.Node.Sep = terminalLocs(ctx, ",")
+}
+
+type ParamListener struct {
+	*BaseTrompeListener
+	Name Token
+	Type TypeNode
+}
+
+func NewParamListener() *ParamListener {
+	return new(ParamListener)
+}
+
+func (l *ParamListener) EnterParam(ctx *ParamContext) {
+	l.Name = NewTokenAntlr(ctx.NAME().GetSymbol())
+	if tyCtx := ctx.Typeexp(); tyCtx != nil {
+		ty := NewTypeexpListener()
+		tyCtx.EnterRule(ty)
+		l.Type = ty.Node
+	}
+}
+
+type TypeexpListener struct {
+	*BaseTrompeListener
+	Node TypeNode
+}
+
+func NewTypeexpListener() *TypeexpListener {
+	return new(TypeexpListener)
+}
+
+func (l *TypeexpListener) EnterTypeexp(ctx *TypeexpContext) {
+	var elts []TypeNode
+	for _, eltCtx := range ctx.AllTypeexp() {
+		elt := NewTypeexpListener()
+		eltCtx.EnterRule(elt)
+		elts = append(elts, elt.Node)
+	}
+
+	if nameTok := ctx.NAME(); nameTok != nil {
+		l.Node = &NamedTypeNode{
+			Name: NewTokenAntlr(nameTok.GetSymbol()),
+			Args: elts,
+		}
+	} else if q := ctx.GetQ(); q != nil {
+		l.Node = &OptTypeNode{Type: elts[0], Q: NewLocAntlr(q)}
+	} else if arrow := ctx.GetArrow(); arrow != nil {
+		// "(a, b) -> c" takes two parameters
+		var params []TypeNode
+		if tuple, ok := elts[0].(*TupleTypeNode); ok {
+			params = tuple.Elts
+		} else {
+			params = []TypeNode{elts[0]}
+		}
+		l.Node = &FunTypeNode{
+			Params: params,
+			Arrow:  NewLocAntlr(arrow),
+			Ret:    elts[1],
+		}
+	} else if len(elts) == 1 {
+		// parenthesized type
+		l.Node = elts[0]
 	} else {
-		panic("not impl")
+		l.Node = &TupleTypeNode{
+			Open:  NewLocAntlr(ctx.GetO()),
+			Close: NewLocAntlr(ctx.GetC()),
+			Elts:  elts,
+		}
+	}
+}
+
+type StructdeclListener struct {
+	*BaseTrompeListener
+	Node StructDeclNode
+}
+
+func NewStructdeclListener() *StructdeclListener {
+	return new(StructdeclListener)
+}
+
+func (l *StructdeclListener) EnterStructdecl(ctx *StructdeclContext) {
+	l.Node = StructDeclNode{
+		Struct: NewLocAntlr(ctx.GetStart()),
+		Name:   NewTokenAntlr(ctx.NAME().GetSymbol()),
+		End:    NewLocAntlr(ctx.GetStop()),
+	}
+	for _, fieldCtx := range ctx.AllFielddecl() {
+		field := NewFielddeclListener()
+		fieldCtx.EnterRule(field)
+		l.Node.Fields = append(l.Node.Fields, field.Node)
+	}
+}
+
+type FielddeclListener struct {
+	*BaseTrompeListener
+	Node FieldDeclNode
+}
+
+func NewFielddeclListener() *FielddeclListener {
+	return new(FielddeclListener)
+}
+
+func (l *FielddeclListener) EnterFielddecl(ctx *FielddeclContext) {
+	ty := NewTypeexpListener()
+	ctx.Typeexp().EnterRule(ty)
+	l.Node = FieldDeclNode{
+		Name: NewTokenAntlr(ctx.NAME().GetSymbol()),
+		Type: ty.Node,
+	}
+}
+
+type TypedeclListener struct {
+	*BaseTrompeListener
+	Node TypeDeclNode
+}
+
+func NewTypedeclListener() *TypedeclListener {
+	return new(TypedeclListener)
+}
+
+func (l *TypedeclListener) EnterTypedecl(ctx *TypedeclContext) {
+	names := ctx.AllNAME()
+	l.Node = TypeDeclNode{
+		Type: NewLocAntlr(ctx.GetStart()),
+		Name: NewTokenAntlr(names[0].GetSymbol()),
+		Eq:   NewLocAntlr(ctx.GetEq()),
+	}
+	for _, param := range names[1:] {
+		l.Node.Params = append(l.Node.Params, NewTokenAntlr(param.GetSymbol()))
+	}
+	for _, ctorCtx := range ctx.AllCtordecl() {
+		ctor := NewCtordeclListener()
+		ctorCtx.EnterRule(ctor)
+		l.Node.Ctors = append(l.Node.Ctors, ctor.Node)
+	}
+}
+
+type CtordeclListener struct {
+	*BaseTrompeListener
+	Node CtorDeclNode
+}
+
+func NewCtordeclListener() *CtordeclListener {
+	return new(CtordeclListener)
+}
+
+func (l *CtordeclListener) EnterCtordecl(ctx *CtordeclContext) {
+	l.Node = CtorDeclNode{Name: NewTokenAntlr(ctx.NAME().GetSymbol())}
+	for _, tyCtx := range ctx.AllTypeexp() {
+		ty := NewTypeexpListener()
+		tyCtx.EnterRule(ty)
+		l.Node.Types = append(l.Node.Types, ty.Node)
+	}
+}
+
+type TraitdeclListener struct {
+	*BaseTrompeListener
+	Node TraitDeclNode
+}
+
+func NewTraitdeclListener() *TraitdeclListener {
+	return new(TraitdeclListener)
+}
+
+func (l *TraitdeclListener) EnterTraitdecl(ctx *TraitdeclContext) {
+	names := ctx.AllNAME()
+	l.Node = TraitDeclNode{
+		Trait: NewLocAntlr(ctx.GetStart()),
+		Name:  NewTokenAntlr(names[0].GetSymbol()),
+		Param: NewTokenAntlr(names[1].GetSymbol()),
+		End:   NewLocAntlr(ctx.GetStop()),
+	}
+	for _, sigCtx := range ctx.AllMethodsig() {
+		sig := NewMethodsigListener()
+		sigCtx.EnterRule(sig)
+		l.Node.Methods = append(l.Node.Methods, sig.Node)
+	}
+}
+
+type MethodsigListener struct {
+	*BaseTrompeListener
+	Node MethodSigNode
+}
+
+func NewMethodsigListener() *MethodsigListener {
+	return new(MethodsigListener)
+}
+
+func (l *MethodsigListener) EnterMethodsig(ctx *MethodsigContext) {
+	params := NewParlistListener()
+	if parsCtx := ctx.Parlist(); parsCtx != nil {
+		parsCtx.EnterRule(params)
+	}
+	retType := NewTypeexpListener()
+	ctx.Typeexp().EnterRule(retType)
+	l.Node = MethodSigNode{
+		Def:     NewLocAntlr(ctx.GetStart()),
+		Name:    NewTokenAntlr(ctx.NAME().GetSymbol()),
+		Params:  &params.Node,
+		RetType: retType.Node,
+	}
+}
+
+type ImpldeclListener struct {
+	*BaseTrompeListener
+	Node ImplDeclNode
+}
+
+func NewImpldeclListener() *ImpldeclListener {
+	return new(ImpldeclListener)
+}
+
+func (l *ImpldeclListener) EnterImpldecl(ctx *ImpldeclContext) {
+	ty := NewTypeexpListener()
+	ctx.Typeexp().EnterRule(ty)
+	l.Node = ImplDeclNode{
+		Impl:  NewLocAntlr(ctx.GetStart()),
+		Trait: NewTokenAntlr(ctx.NAME().GetSymbol()),
+		Type:  ty.Node,
+		End:   NewLocAntlr(ctx.GetStop()),
+	}
+	for _, defCtx := range ctx.AllFundef() {
+		def := NewFundefListener()
+		defCtx.EnterRule(def)
+		l.Node.Defs = append(l.Node.Defs, def.Node)
+	}
+}
+
+type AssignListener struct {
+	*BaseTrompeListener
+	Node AssignStatNode
+}
+
+func NewAssignListener() *AssignListener {
+	return new(AssignListener)
+}
+
+func (l *AssignListener) EnterAssign(ctx *AssignContext) {
+	exps := ctx.AllExp()
+	target := NewExpListener()
+	exps[0].EnterRule(target)
+	exp := NewExpListener()
+	exps[1].EnterRule(exp)
+	l.Node = AssignStatNode{
+		Target: target.Node,
+		Arrow:  NewLocAntlr(ctx.GetArrow()),
+		Exp:    exp.Node,
 	}
 }
 
@@ -92,8 +582,186 @@ func (l *ForStatListener) EnterFor_(ctx *For_Context) {
 	block := NewBlockListener()
 	ctx.Block().EnterRule(block)
 
-	// TODO: pattern
-	l.Node = ForStatNode{Ptn: ptn.Node, Exp: exp.Node, Block: block.Node}
+	l.Node = ForStatNode{
+		For:   NewLocAntlr(ctx.GetStart()),
+		In:    terminalLoc(ctx, "in"),
+		Do:    terminalLoc(ctx, "do"),
+		End:   NewLocAntlr(ctx.GetStop()),
+		Ptn:   ptn.Node,
+		Exp:   exp.Node,
+		Block: block.Node,
+	}
+}
+
+type DoblockListener struct {
+	*BaseTrompeListener
+	Node BlockNode
+}
+
+func NewDoblockListener() *DoblockListener {
+	return new(DoblockListener)
+}
+
+func (l *DoblockListener) EnterDoblock(ctx *DoblockContext) {
+	block := NewBlockListener()
+	ctx.Block().EnterRule(block)
+	l.Node = block.Node
+}
+
+type IfStatListener struct {
+	*BaseTrompeListener
+	Node IfStatNode
+}
+
+func NewIfStatListener() *IfStatListener {
+	return new(IfStatListener)
+}
+
+func (l *IfStatListener) EnterIf_(ctx *If_Context) {
+	// "if" and "elseif" in order
+	ifs := append(terminalLocs(ctx, "if"), terminalLocs(ctx, "elseif")...)
+	thens := terminalLocs(ctx, "then")
+	blockCtxs := ctx.AllBlock()
+	for i, expCtx := range ctx.AllExp() {
+		exp := NewExpListener()
+		expCtx.EnterRule(exp)
+		block := NewBlockListener()
+		blockCtxs[i].EnterRule(block)
+		l.Node.Cond = append(l.Node.Cond, IfCondNode{
+			If:     ifs[i],
+			Cond:   exp.Node,
+			Then:   thens[i],
+			Action: block.Node,
+		})
+	}
+	if elses := terminalLocs(ctx, "else"); len(elses) > 0 {
+		block := NewBlockListener()
+		blockCtxs[len(blockCtxs)-1].EnterRule(block)
+		l.Node.Else = &elses[0]
+		l.Node.ElseAction = &block.Node
+	}
+	l.Node.End = NewLocAntlr(ctx.GetStop())
+}
+
+type CaseStatListener struct {
+	*BaseTrompeListener
+	Node CaseStatNode
+}
+
+func NewCaseStatListener() *CaseStatListener {
+	return new(CaseStatListener)
+}
+
+func (l *CaseStatListener) EnterCase_(ctx *Case_Context) {
+	exp := NewExpListener()
+	ctx.Exp().EnterRule(exp)
+	l.Node = CaseStatNode{Case: NewLocAntlr(ctx.GetStart()), Cond: exp.Node}
+	for _, clauCtx := range ctx.AllCaseclau() {
+		clau := NewCaseclauListener()
+		clauCtx.EnterRule(clau)
+		l.Node.Claus = append(l.Node.Claus, clau.Node)
+	}
+	if elses := terminalLocs(ctx, "else"); len(elses) > 0 {
+		block := NewBlockListener()
+		ctx.Block().EnterRule(block)
+		l.Node.Else = &elses[0]
+		l.Node.ElseAction = &block.Node
+	}
+}
+
+type CaseclauListener struct {
+	*BaseTrompeListener
+	Node CaseClauNode
+}
+
+func NewCaseclauListener() *CaseclauListener {
+	return new(CaseclauListener)
+}
+
+func (l *CaseclauListener) EnterCaseclau(ctx *CaseclauContext) {
+	ptn := NewPatternListener()
+	ctx.Pattern().EnterRule(ptn)
+	block := NewBlockListener()
+	ctx.Block().EnterRule(block)
+	l.Node = CaseClauNode{
+		When:   NewLocAntlr(ctx.GetStart()),
+		Ptn:    ptn.Node,
+		Then:   terminalLoc(ctx, "then"),
+		Action: &block.Node,
+	}
+	if guardCtx := ctx.Guard(); guardCtx != nil {
+		guard := NewGuardListener()
+		guardCtx.EnterRule(guard)
+		l.Node.In = &guard.In
+		l.Node.Guard = guard.Node
+	}
+}
+
+type TryStatListener struct {
+	*BaseTrompeListener
+	Node TryStatNode
+}
+
+func NewTryStatListener() *TryStatListener {
+	return new(TryStatListener)
+}
+
+func (l *TryStatListener) EnterTry_(ctx *Try_Context) {
+	blockCtxs := ctx.AllBlock()
+	block := NewBlockListener()
+	blockCtxs[0].EnterRule(block)
+	l.Node = TryStatNode{Try: NewLocAntlr(ctx.GetStart()), Block: &block.Node}
+	for _, clauCtx := range ctx.AllRescueclau() {
+		clau := NewRescueclauListener()
+		clauCtx.EnterRule(clau)
+		l.Node.Claus = append(l.Node.Claus, clau.Node)
+	}
+	if ensures := terminalLocs(ctx, "ensure"); len(ensures) > 0 {
+		ensure := NewBlockListener()
+		blockCtxs[1].EnterRule(ensure)
+		l.Node.Ensure = &ensures[0]
+		l.Node.EnsureAction = &ensure.Node
+	}
+	l.Node.End = NewLocAntlr(ctx.GetStop())
+}
+
+type RescueclauListener struct {
+	*BaseTrompeListener
+	Node RescueClauNode
+}
+
+func NewRescueclauListener() *RescueclauListener {
+	return new(RescueclauListener)
+}
+
+func (l *RescueclauListener) EnterRescueclau(ctx *RescueclauContext) {
+	ptn := NewPatternListener()
+	ctx.Pattern().EnterRule(ptn)
+	block := NewBlockListener()
+	ctx.Block().EnterRule(block)
+	l.Node = RescueClauNode{
+		Rescue: NewLocAntlr(ctx.GetStart()),
+		Ptn:    ptn.Node,
+		Then:   terminalLoc(ctx, "then"),
+		Action: &block.Node,
+	}
+}
+
+type GuardListener struct {
+	*BaseTrompeListener
+	In   Loc
+	Node ExpNode
+}
+
+func NewGuardListener() *GuardListener {
+	return new(GuardListener)
+}
+
+func (l *GuardListener) EnterGuard(ctx *GuardContext) {
+	exp := NewExpListener()
+	ctx.Exp().EnterRule(exp)
+	l.In = NewLocAntlr(ctx.GetStart())
+	l.Node = exp.Node
 }
 
 type FuncallListener struct {
@@ -110,7 +778,6 @@ func (l *FuncallListener) EnterFuncall(ctx *FuncallContext) {
 	exp := NewSimpleExpListener()
 	ctx.Simpleexp().EnterRule(exp)
 
-	// TODO: '(', ')'
 	fmt.Printf("arglist\n")
 	args := NewArglistListener()
 	if argsCtx := ctx.Arglist(); argsCtx != nil {
@@ -130,12 +797,13 @@ func NewArglistListener() *ArglistListener {
 }
 
 func (l *ArglistListener) EnterArglist(ctx *ArglistContext) {
-	// TODO: '(', ')'
 	if expsCtx := ctx.Explist(); expsCtx != nil {
 		exps := NewExplistListener()
 		expsCtx.EnterRule(exps)
 		l.Node = exps.Node
 	}
+	l.Node.Open = NewLocAntlr(ctx.GetStart())
+	l.Node.Close = NewLocAntlr(ctx.GetStop())
 }
 
 type ExplistListener struct {
@@ -155,7 +823,7 @@ func (l *ExplistListener) EnterExplist(ctx *ExplistContext) {
 		expCtx.EnterRule(exp)
 		exps = append(exps, exp.Node)
 	}
-	l.Node = EltListNode{Elts: exps}
+	l.Node = EltListNode{Elts: exps, Seps: terminalLocs(ctx, ",")}
 }
 
 type ExpListener struct {
@@ -168,12 +836,15 @@ func NewExpListener() *ExpListener {
 }
 
 func (l *ExpListener) EnterExp(ctx *ExpContext) {
-	// TODO
 	fmt.Printf("enter exp: %s\n", ctx.GetText())
 	if expCtx := ctx.Simpleexp(); expCtx != nil {
 		exp := NewSimpleExpListener()
 		expCtx.EnterRule(exp)
 		l.Node = exp.Node
+	} else if funcallCtx := ctx.Funcall(); funcallCtx != nil {
+		funcall := NewFuncallListener()
+		funcallCtx.EnterRule(funcall)
+		l.Node = &funcall.Node
 	} else if opCtx := ctx.Rangeop(); opCtx != nil {
 		fmt.Printf("enter range\n")
 		op := NewRangeOpListener()
@@ -188,11 +859,66 @@ func (l *ExpListener) EnterExp(ctx *ExpContext) {
 			Op:    op.Token,
 			Close: op.Close,
 			Right: right.Node}
+	} else if objCtx := ctx.GetObj(); objCtx != nil {
+		obj := NewExpListener()
+		objCtx.EnterRule(obj)
+		if argsCtx := ctx.Arglist(); argsCtx != nil {
+			// "value.method(args)"
+			args := NewArglistListener()
+			argsCtx.EnterRule(args)
+			attr := &AttrExpNode{Exp: obj.Node,
+				Dot:  NewLocAntlr(ctx.GetDot()),
+				Name: NewTokenAntlr(ctx.NAME().GetSymbol())}
+			l.Node = &FunCallExpNode{Callable: attr, Args: args.Node}
+		} else if dot := ctx.GetDot(); dot != nil {
+			// "value.field"
+			l.Node = &AttrExpNode{Exp: obj.Node,
+				Dot:  NewLocAntlr(dot),
+				Name: NewTokenAntlr(ctx.NAME().GetSymbol())}
+		} else {
+			idx := NewExpListener()
+			ctx.GetIdx().EnterRule(idx)
+			l.Node = &IndexExpNode{Exp: obj.Node,
+				Open:  NewLocAntlr(ctx.GetO()),
+				Index: idx.Node,
+				Close: NewLocAntlr(ctx.GetC())}
+		}
+	} else if opCtx := ctx.OperatorUnary(); opCtx != nil {
+		exp := NewExpListener()
+		ctx.GetOperand().EnterRule(exp)
+		l.Node = &UnaryExpNode{Op: NewOperatorToken(opCtx), Exp: exp.Node}
+	} else if operandCtx := ctx.GetOperand(); operandCtx != nil {
+		// "raise exp"
+		exp := NewExpListener()
+		operandCtx.EnterRule(exp)
+		l.Node = &RaiseExpNode{Raise: NewLocAntlr(ctx.GetStart()), Exp: exp.Node}
+	} else if leftCtx := ctx.GetLeft(); leftCtx != nil {
+		// the operator is between the operands
+		opCtx := ctx.GetChild(1).(antlr.ParserRuleContext)
+		left := NewExpListener()
+		leftCtx.EnterRule(left)
+		right := NewExpListener()
+		ctx.GetRight().EnterRule(right)
+		op := NewOperatorToken(opCtx)
+		binexp := &BinaryExpNode{Left: left.Node, Op: op, Right: right.Node}
+		if ctx.OperatorUser() != nil {
+			fun := NewVarExpNode(op)
+			binexp.Fun = &fun
+			l.Node = reassoc(binexp, fixitiesOf(ctx.GetStart()))
+		} else {
+			l.Node = binexp
+		}
 	} else {
-		panic("not impl")
+		unsupported(ctx, "expression")
 	}
 }
 
+// NewOperatorToken returns a token of the operator.
+// The operator may consist of two tokens such as "<" "<".
+func NewOperatorToken(ctx antlr.ParserRuleContext) Token {
+	return NewToken(NewLocAntlr(ctx.GetStart()), ctx.GetText())
+}
+
 type RangeOpListener struct {
 	*BaseTrompeListener
 	Token Token
@@ -225,10 +951,13 @@ func NewParenexpListener() *ParenexpListener {
 }
 
 func (l *ParenexpListener) EnterParenexp(ctx *ParenexpContext) {
-	// TODO: open, close
 	exp := NewExpListener()
 	ctx.Exp().EnterRule(exp)
-	l.Node = exp.Node
+	l.Node = &ParenExpNode{
+		Open:  NewLocAntlr(ctx.GetO()),
+		Close: NewLocAntlr(ctx.GetC()),
+		Exp:   exp.Node,
+	}
 }
 
 type SimpleExpListener struct {
@@ -241,12 +970,7 @@ func NewSimpleExpListener() *SimpleExpListener {
 }
 
 func (l *SimpleExpListener) EnterSimpleexp(ctx *SimpleexpContext) {
-	// TODO
 	fmt.Printf("enter simpleexp: %s\n", ctx.GetText())
-	fmt.Printf("int %s\n", ctx.Int_())
-	fmt.Printf("int %s\n", ctx.Hexint())
-	fmt.Printf("float %s\n", ctx.Float_())
-	fmt.Printf("float %s\n", ctx.Hexfloat())
 
 	if parenCtx := ctx.Parenexp(); parenCtx != nil {
 		exp := NewParenexpListener()
@@ -255,33 +979,364 @@ func (l *SimpleExpListener) EnterSimpleexp(ctx *SimpleexpContext) {
 	} else if varCtx := ctx.Var_(); varCtx != nil {
 		var_ := NewVarExpListener()
 		ctx.Var_().EnterRule(var_)
-		l.Node = &var_.Node
+		l.Node = var_.Node
+	} else if tableCtx := ctx.Tableconstructor(); tableCtx != nil {
+		table := NewTableconstructorListener()
+		tableCtx.EnterRule(table)
+		l.Node = table.Node
 	} else if intCtx := ctx.Int_(); intCtx != nil {
 		int_ := NewIntListener()
 		intCtx.EnterRule(int_)
 		l.Node = &int_.Node
+	} else if hexCtx := ctx.Hexint(); hexCtx != nil {
+		l.Node = &IntExpNode{Value: NewTokenAntlr(hexCtx.GetStart())}
+	} else if floatCtx := ctx.Float_(); floatCtx != nil {
+		l.Node = &FloatExpNode{Value: NewTokenAntlr(floatCtx.GetStart())}
+	} else if hexCtx := ctx.Hexfloat(); hexCtx != nil {
+		l.Node = &FloatExpNode{Value: NewTokenAntlr(hexCtx.GetStart())}
 	} else if strCtx := ctx.String_(); strCtx != nil {
 		str := NewStringListener()
 		strCtx.EnterRule(str)
-		l.Node = &str.Node
+		l.Node = str.Node
+	} else if unitCtx := ctx.Unit(); unitCtx != nil {
+		unit := NewUnitListener()
+		unitCtx.EnterRule(unit)
+		l.Node = &UnitExpNode{Open: unit.Open, Close: unit.Close}
+	} else if boolCtx := ctx.Bool_(); boolCtx != nil {
+		bool_ := NewBoolListener()
+		boolCtx.EnterRule(bool_)
+		exp := NewBoolExpNode(bool_.Loc, bool_.Value)
+		l.Node = &exp
+	} else if listCtx := ctx.List(); listCtx != nil {
+		list := NewListListener()
+		listCtx.EnterRule(list)
+		l.Node = &ListExpNode{Elts: list.Elts}
+	} else if tupleCtx := ctx.Tuple(); tupleCtx != nil {
+		tuple := NewTupleListener()
+		tupleCtx.EnterRule(tuple)
+		l.Node = tuple.Node
+	} else if anonCtx := ctx.Anonfun(); anonCtx != nil {
+		anon := NewAnonfunListener()
+		anonCtx.EnterRule(anon)
+		l.Node = &anon.Node
+	} else if statCtx := ctx.Statexp(); statCtx != nil {
+		stat := NewStatexpListener()
+		statCtx.EnterRule(stat)
+		l.Node = stat.Node
+	} else if secCtx := ctx.Opsection(); secCtx != nil {
+		sec := NewOpsectionListener()
+		secCtx.EnterRule(sec)
+		l.Node = &sec.Node
+	} else if optCtx := ctx.Optexp(); optCtx != nil {
+		opt := NewOptexpListener()
+		optCtx.EnterRule(opt)
+		l.Node = opt.Node
 	} else {
-		panic("not impl")
+		unsupported(ctx, "expression")
 	}
 }
 
-type VarExpListener struct {
+// OpsectionListener builds a variable of "(op)" referring to
+// the function of the operator.
+type OpsectionListener struct {
 	*BaseTrompeListener
 	Node VarExpNode
 }
 
+func NewOpsectionListener() *OpsectionListener {
+	return new(OpsectionListener)
+}
+
+func (l *OpsectionListener) EnterOpsection(ctx *OpsectionContext) {
+	l.Node = NewVarExpNode(NewTokenAntlr(ctx.OPERATOR().GetSymbol()))
+}
+
+type OptexpListener struct {
+	*BaseTrompeListener
+	Node ExpNode
+}
+
+func NewOptexpListener() *OptexpListener {
+	return new(OptexpListener)
+}
+
+func (l *OptexpListener) EnterOptexp(ctx *OptexpContext) {
+	if some := ctx.GetSome(); some != nil {
+		exp := NewExpListener()
+		ctx.Exp().EnterRule(exp)
+		l.Node = &SomeExpNode{SomeLoc: NewLocAntlr(some), Value: exp.Node}
+	} else {
+		exp := NewNoneExpNode(NewLocAntlr(ctx.GetStart()))
+		l.Node = &exp
+	}
+}
+
+type UnitListener struct {
+	*BaseTrompeListener
+	Open  Loc
+	Close Loc
+}
+
+func NewUnitListener() *UnitListener {
+	return new(UnitListener)
+}
+
+func (l *UnitListener) EnterUnit(ctx *UnitContext) {
+	l.Open = NewLocAntlr(ctx.GetStart())
+	l.Close = NewLocAntlr(ctx.GetStop())
+}
+
+type BoolListener struct {
+	*BaseTrompeListener
+	Loc   Loc
+	Value bool
+}
+
+func NewBoolListener() *BoolListener {
+	return new(BoolListener)
+}
+
+func (l *BoolListener) EnterBool_(ctx *Bool_Context) {
+	l.Loc = NewLocAntlr(ctx.GetStart())
+	l.Value = ctx.GetText() == "true"
+}
+
+type ListListener struct {
+	*BaseTrompeListener
+	Elts EltListNode
+}
+
+func NewListListener() *ListListener {
+	return new(ListListener)
+}
+
+func (l *ListListener) EnterList(ctx *ListContext) {
+	if eltsCtx := ctx.Eltlist(); eltsCtx != nil {
+		elts := NewEltlistListener()
+		eltsCtx.EnterRule(elts)
+		l.Elts = elts.Node
+	}
+	l.Elts.Open = NewLocAntlr(ctx.GetStart())
+	l.Elts.Close = NewLocAntlr(ctx.GetStop())
+}
+
+type TupleListener struct {
+	*BaseTrompeListener
+	Node ExpNode
+}
+
+func NewTupleListener() *TupleListener {
+	return new(TupleListener)
+}
+
+func (l *TupleListener) EnterTuple(ctx *TupleContext) {
+	var elts EltListNode
+	if eltsCtx := ctx.Eltlist(); eltsCtx != nil {
+		list := NewEltlistListener()
+		eltsCtx.EnterRule(list)
+		elts = list.Node
+	}
+	elts.Open = NewLocAntlr(ctx.GetStart())
+	elts.Close = NewLocAntlr(ctx.GetStop())
+
+	// "(a)" is a parenthesized expression, and "(a,)" is a tuple
+	if len(elts.Elts) == 1 && len(elts.Seps) == 0 {
+		l.Node = &ParenExpNode{Open: elts.Open, Close: elts.Close, Exp: elts.Elts[0]}
+	} else {
+		l.Node = &TupleExpNode{Elts: elts}
+	}
+}
+
+type EltlistListener struct {
+	*BaseTrompeListener
+	Node EltListNode
+}
+
+func NewEltlistListener() *EltlistListener {
+	return new(EltlistListener)
+}
+
+func (l *EltlistListener) EnterEltlist(ctx *EltlistContext) {
+	for _, expCtx := range ctx.AllExp() {
+		exp := NewExpListener()
+		expCtx.EnterRule(exp)
+		l.Node.Elts = append(l.Node.Elts, exp.Node)
+	}
+	l.Node.Seps = terminalLocs(ctx, ",")
+}
+
+type AnonfunListener struct {
+	*BaseTrompeListener
+	Node AnonFunExpNode
+}
+
+func NewAnonfunListener() *AnonfunListener {
+	return new(AnonfunListener)
+}
+
+func (l *AnonfunListener) EnterAnonfun(ctx *AnonfunContext) {
+	params := NewParlistListener()
+	if parsCtx := ctx.Parlist(); parsCtx != nil {
+		parsCtx.EnterRule(params)
+	}
+	l.Node = AnonFunExpNode{
+		Open:   NewLocAntlr(ctx.GetStart()),
+		Close:  NewLocAntlr(ctx.GetStop()),
+		Params: &params.Node,
+		In:     terminalLoc(ctx, "in"),
+	}
+	for _, statCtx := range ctx.AllStat() {
+		stat := NewStatListener()
+		statCtx.EnterRule(stat)
+		if stat.Node != nil {
+			l.Node.Stats = append(l.Node.Stats, stat.Node)
+		}
+	}
+	exp := NewExpListener()
+	ctx.Exp().EnterRule(exp)
+	l.Node.Exp = exp.Node
+}
+
+type StatexpListener struct {
+	*BaseTrompeListener
+	Node ExpNode
+}
+
+func NewStatexpListener() *StatexpListener {
+	return new(StatexpListener)
+}
+
+// EnterStatexp makes the statement an expression.
+// Every statement has a value.
+func (l *StatexpListener) EnterStatexp(ctx *StatexpContext) {
+	stat := NewStatListener()
+	ctx.Stat().EnterRule(stat)
+	if stat.Node == nil {
+		// "[;]"
+		l.Node = &UnitExpNode{
+			Open:  NewLocAntlr(ctx.GetStart()),
+			Close: NewLocAntlr(ctx.GetStop()),
+		}
+	} else {
+		l.Node = stat.Node
+	}
+}
+
+type VarExpListener struct {
+	*BaseTrompeListener
+	Node ExpNode
+}
+
 func NewVarExpListener() *VarExpListener {
 	return new(VarExpListener)
 }
 
 func (l *VarExpListener) EnterVar_(ctx *Var_Context) {
-	// TODO
-	fmt.Printf("enter var\n")
-	l.Node = NewVarExpNode(NewTokenAntlr(ctx.GetStart()))
+	path := NewModulepathListener()
+	ctx.Modulepath().EnterRule(path)
+
+	// "a.b.c" is accessing attributes "b" and "c" of "a"
+	var exp ExpNode
+	for i, name := range path.Names {
+		if i == 0 {
+			var_ := NewVarExpNode(name)
+			exp = &var_
+		} else {
+			exp = &AttrExpNode{Exp: exp, Name: name}
+		}
+	}
+	l.Node = exp
+}
+
+type ModulepathListener struct {
+	*BaseTrompeListener
+	Names []Token
+}
+
+func NewModulepathListener() *ModulepathListener {
+	return new(ModulepathListener)
+}
+
+func (l *ModulepathListener) EnterModulepath(ctx *ModulepathContext) {
+	for _, name := range ctx.AllNAME() {
+		l.Names = append(l.Names, NewTokenAntlr(name.GetSymbol()))
+	}
+}
+
+type TableconstructorListener struct {
+	*BaseTrompeListener
+	Node ExpNode
+}
+
+func NewTableconstructorListener() *TableconstructorListener {
+	return new(TableconstructorListener)
+}
+
+func (l *TableconstructorListener) EnterTableconstructor(ctx *TableconstructorContext) {
+	open := NewLocAntlr(ctx.GetStart())
+	close := NewLocAntlr(ctx.GetStop())
+	fields := NewFieldlistListener()
+	if fieldsCtx := ctx.Fieldlist(); fieldsCtx != nil {
+		fieldsCtx.EnterRule(fields)
+	}
+
+	if expCtx := ctx.Exp(); expCtx != nil {
+		exp := NewExpListener()
+		expCtx.EnterRule(exp)
+		l.Node = &UpdateExpNode{
+			Open:   open,
+			Close:  close,
+			Exp:    exp.Node,
+			Fields: fields.Nodes,
+		}
+	} else {
+		var name *Token
+		if nameTok := ctx.NAME(); nameTok != nil {
+			tok := NewTokenAntlr(nameTok.GetSymbol())
+			name = &tok
+		}
+		l.Node = &RecordExpNode{
+			Open:   open,
+			Close:  close,
+			Name:   name,
+			Fields: fields.Nodes,
+		}
+	}
+}
+
+type FieldlistListener struct {
+	*BaseTrompeListener
+	Nodes []FieldNode
+}
+
+func NewFieldlistListener() *FieldlistListener {
+	return new(FieldlistListener)
+}
+
+func (l *FieldlistListener) EnterFieldlist(ctx *FieldlistContext) {
+	for _, fieldCtx := range ctx.AllField() {
+		field := NewFieldListener()
+		fieldCtx.EnterRule(field)
+		l.Nodes = append(l.Nodes, field.Node)
+	}
+}
+
+type FieldListener struct {
+	*BaseTrompeListener
+	Node FieldNode
+}
+
+func NewFieldListener() *FieldListener {
+	return new(FieldListener)
+}
+
+func (l *FieldListener) EnterField(ctx *FieldContext) {
+	l.Node = FieldNode{Name: NewTokenAntlr(ctx.NAME().GetSymbol())}
+	if expCtx := ctx.Exp(); expCtx != nil {
+		exp := NewExpListener()
+		expCtx.EnterRule(exp)
+		l.Node.Exp = exp.Node
+	}
 }
 
 type IntListener struct {
@@ -298,18 +1353,44 @@ func (l *IntListener) EnterInt_(ctx *Int_Context) {
 	l.Node = IntExpNode{Value: NewTokenAntlr(ctx.GetStart())}
 }
 
+// signed returns the number token prefixed with the minus sign
+// of a negative number pattern. The sign is nil if not given.
+func signed(neg antlr.Token, value Token) Token {
+	if neg == nil {
+		return value
+	}
+	loc := NewLocAntlr(neg)
+	loc.End = value.Loc.End
+	return Token{Loc: loc, Text: "-" + value.Text}
+}
+
 type StringListener struct {
 	*BaseTrompeListener
-	Node StrExpNode
+	Node ExpNode // *StrExpNode or *InterpExpNode
 }
 
 func NewStringListener() *StringListener {
 	return new(StringListener)
 }
 
+// EnterString_ decodes the string literal. Long strings are raw,
+// single-quoted strings decode escape sequences, and double-quoted
+// strings also have interpolations.
 func (l *StringListener) EnterString_(ctx *String_Context) {
 	fmt.Printf("enter string\n")
-	l.Node = StrExpNode{Value: NewTokenAntlr(ctx.GetStart())}
+	tok := ctx.GetStart()
+	value := NewTokenAntlr(tok)
+	text := []rune(tok.GetText())
+	switch {
+	case ctx.LONGSTRING() != nil:
+		value.Text = unquoteLong(value.Text)
+		l.Node = &StrExpNode{Value: value}
+	case ctx.CHARSTRING() != nil:
+		value.Text = decodeEscapes(tok, text, 1, len(text)-1)
+		l.Node = &StrExpNode{Value: value}
+	default:
+		l.Node = interpString(tok)
+	}
 }
 
 type PatternListener struct {
@@ -324,30 +1405,293 @@ func NewPatternListener() *PatternListener {
 func (l *PatternListener) EnterPattern(ctx *PatternContext) {
 	fmt.Printf("enter pattern: %s\n", ctx.GetText())
 
-	if varCtx := ctx.NAME(); varCtx != nil {
-		l.Node = &VarPtnNode{NewTokenAntlr(ctx.GetStart())}
+	if cons := ctx.GetCons(); cons != nil {
+		ptns := ctx.AllPattern()
+		left := NewPatternListener()
+		ptns[0].EnterRule(left)
+		right := NewPatternListener()
+		ptns[1].EnterRule(right)
+		l.Node = &ConsPtnNode{Left: left.Node, Sep: NewLocAntlr(cons), Right: right.Node}
+	} else if bar := ctx.GetBar(); bar != nil {
+		ptns := ctx.AllPattern()
+		left := NewPatternListener()
+		ptns[0].EnterRule(left)
+		right := NewPatternListener()
+		ptns[1].EnterRule(right)
+		l.Node = &OrPtnNode{Left: left.Node, Bar: NewLocAntlr(bar), Right: right.Node}
+	} else if as := terminalLocs(ctx, "as"); len(as) > 0 {
+		ptn := NewPatternListener()
+		ctx.Pattern(0).EnterRule(ptn)
+		l.Node = &AsPtnNode{Ptn: ptn.Node, As: as[0],
+			Name: NewTokenAntlr(ctx.NAME().GetSymbol())}
+	} else if optCtx := ctx.Optptn(); optCtx != nil {
+		opt := NewOptptnListener()
+		optCtx.EnterRule(opt)
+		l.Node = opt.Node
+	} else if ctorCtx := ctx.Ctorptn(); ctorCtx != nil {
+		ctor := NewCtorptnListener()
+		ctorCtx.EnterRule(ctor)
+		l.Node = &ctor.Node
+	} else if pin := ctx.GetPin(); pin != nil {
+		l.Node = &PinPtnNode{Caret: NewLocAntlr(pin),
+			Name: NewTokenAntlr(ctx.NAME().GetSymbol())}
+	} else if varCtx := ctx.NAME(); varCtx != nil {
+		name := NewTokenAntlr(ctx.GetStart())
+		if IsCtorName(name.Text) {
+			l.Node = &CtorPtnNode{Name: name}
+		} else {
+			l.Node = &VarPtnNode{name}
+		}
+	} else if recordCtx := ctx.Recordptn(); recordCtx != nil {
+		record := NewRecordptnListener()
+		recordCtx.EnterRule(record)
+		l.Node = &record.Node
+	} else if opCtx := ctx.Rangeop(); opCtx != nil {
+		op := NewRangeOpListener()
+		opCtx.EnterRule(op)
+		ptns := ctx.AllPattern()
+		left := NewPatternListener()
+		ptns[0].EnterRule(left)
+		right := NewPatternListener()
+		ptns[1].EnterRule(right)
+		l.Node = &RangePtnNode{
+			Left:  left.Node,
+			Op:    op.Token,
+			Close: op.Close,
+			Right: right.Node,
+		}
 	} else if intCtx := ctx.Int_(); intCtx != nil {
 		int_ := NewIntListener()
 		intCtx.EnterRule(int_)
-		l.Node = &int_.Node
+		l.Node = &IntPtnNode{Value: signed(ctx.GetNeg(), int_.Node.Value)}
+	} else if floatCtx := ctx.Float_(); floatCtx != nil {
+		value := NewTokenAntlr(floatCtx.GetStart())
+		l.Node = &FloatPtnNode{Value: signed(ctx.GetNeg(), value)}
 	} else if strCtx := ctx.String_(); strCtx != nil {
 		str := NewStringListener()
 		strCtx.EnterRule(str)
-		l.Node = &str.Node
+		lit, ok := str.Node.(*StrExpNode)
+		if !ok {
+			syntaxError(*str.Node.Loc(), "interpolation is not allowed in patterns")
+		}
+		l.Node = &StrPtnNode{Value: lit.Value}
+	} else if unitCtx := ctx.Unit(); unitCtx != nil {
+		unit := NewUnitListener()
+		unitCtx.EnterRule(unit)
+		l.Node = &UnitPtnNode{Open: unit.Open, Close: unit.Close}
+	} else if boolCtx := ctx.Bool_(); boolCtx != nil {
+		bool_ := NewBoolListener()
+		boolCtx.EnterRule(bool_)
+		ptn := NewBoolPtnNode(bool_.Loc, bool_.Value)
+		l.Node = &ptn
+	} else if open := ctx.GetStart(); open.GetText() == "[" || open.GetText() == "(" {
+		elts := EltPtnListNode{
+			Open:  NewLocAntlr(open),
+			Close: NewLocAntlr(ctx.GetStop()),
+		}
+		if listCtx := ctx.Patlist(); listCtx != nil {
+			list := NewPatlistListener()
+			listCtx.EnterRule(list)
+			elts.Elts = list.Elts
+			elts.Seps = terminalLocs(listCtx, ",")
+		}
+		if open.GetText() == "[" {
+			l.Node = &ListPtnNode{Elts: elts}
+		} else if len(elts.Elts) == 1 {
+			// parenthesized pattern
+			l.Node = elts.Elts[0]
+		} else {
+			l.Node = &TuplePtnNode{Elts: elts}
+		}
 	} else {
-		panic("not impl")
+		unsupported(ctx, "pattern")
 	}
 }
 
-func Parse(file string) Node {
-	input, _ := antlr.NewFileStream(file)
-	lexer := NewTrompeLexer(input)
+type OptptnListener struct {
+	*BaseTrompeListener
+	Node PtnNode
+}
+
+func NewOptptnListener() *OptptnListener {
+	return new(OptptnListener)
+}
+
+func (l *OptptnListener) EnterOptptn(ctx *OptptnContext) {
+	if some := ctx.GetSome(); some != nil {
+		ptn := NewPatternListener()
+		ctx.Pattern().EnterRule(ptn)
+		l.Node = &SomePtnNode{SomeLoc: NewLocAntlr(some), Value: ptn.Node}
+	} else {
+		ptn := NewNonePtnNode(NewLocAntlr(ctx.GetStart()))
+		l.Node = &ptn
+	}
+}
+
+type CtorptnListener struct {
+	*BaseTrompeListener
+	Node CtorPtnNode
+}
+
+func NewCtorptnListener() *CtorptnListener {
+	return new(CtorptnListener)
+}
+
+func (l *CtorptnListener) EnterCtorptn(ctx *CtorptnContext) {
+	l.Node = CtorPtnNode{
+		Name: NewTokenAntlr(ctx.NAME().GetSymbol()),
+		Args: &EltPtnListNode{
+			Open:  NewLocAntlr(ctx.GetO()),
+			Close: NewLocAntlr(ctx.GetC()),
+		},
+	}
+	if listCtx := ctx.Patlist(); listCtx != nil {
+		list := NewPatlistListener()
+		listCtx.EnterRule(list)
+		l.Node.Args.Elts = list.Elts
+	}
+}
+
+type PatlistListener struct {
+	*BaseTrompeListener
+	Elts []PtnNode
+}
+
+func NewPatlistListener() *PatlistListener {
+	return new(PatlistListener)
+}
+
+func (l *PatlistListener) EnterPatlist(ctx *PatlistContext) {
+	for _, ptnCtx := range ctx.AllPattern() {
+		ptn := NewPatternListener()
+		ptnCtx.EnterRule(ptn)
+		l.Elts = append(l.Elts, ptn.Node)
+	}
+}
+
+type RecordptnListener struct {
+	*BaseTrompeListener
+	Node RecordPtnNode
+}
+
+func NewRecordptnListener() *RecordptnListener {
+	return new(RecordptnListener)
+}
+
+func (l *RecordptnListener) EnterRecordptn(ctx *RecordptnContext) {
+	l.Node = RecordPtnNode{
+		Open:  NewLocAntlr(ctx.GetStart()),
+		Close: NewLocAntlr(ctx.GetStop()),
+	}
+	if nameTok := ctx.NAME(); nameTok != nil {
+		name := NewTokenAntlr(nameTok.GetSymbol())
+		l.Node.Name = &name
+	}
+	for _, fieldCtx := range ctx.AllFieldptn() {
+		field := NewFieldptnListener()
+		fieldCtx.EnterRule(field)
+		l.Node.Fields = append(l.Node.Fields, field.Node)
+	}
+}
+
+type FieldptnListener struct {
+	*BaseTrompeListener
+	Node FieldPtnNode
+}
+
+func NewFieldptnListener() *FieldptnListener {
+	return new(FieldptnListener)
+}
+
+func (l *FieldptnListener) EnterFieldptn(ctx *FieldptnContext) {
+	l.Node = FieldPtnNode{Name: NewTokenAntlr(ctx.NAME().GetSymbol())}
+	if ptnCtx := ctx.Pattern(); ptnCtx != nil {
+		ptn := NewPatternListener()
+		ptnCtx.EnterRule(ptn)
+		l.Node.Ptn = ptn.Node
+	}
+}
+
+// terminalLocs returns the locations of the tokens of the text
+// that are the direct children of the context.
+func terminalLocs(ctx antlr.ParserRuleContext, text string) []Loc {
+	var locs []Loc
+	for _, child := range ctx.GetChildren() {
+		if term, ok := child.(antlr.TerminalNode); ok && term.GetText() == text {
+			locs = append(locs, NewLocAntlr(term.GetSymbol()))
+		}
+	}
+	return locs
+}
+
+// terminalLoc returns the location of the first token of the text.
+func terminalLoc(ctx antlr.ParserRuleContext, text string) Loc {
+	if locs := terminalLocs(ctx, text); len(locs) > 0 {
+		return locs[0]
+	}
+	return Loc{}
+}
+
+// Parse parses the file. The node is nil if any errors are found.
+// The parser recovers from syntax errors to report as many errors as possible.
+func Parse(file string) (Node, []Diagnostic) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, []Diagnostic{NewDiagnostic(file, Loc{}, err.Error())}
+	}
+	return ParseString(file, string(data))
+}
+
+// ParseReader parses the text read from the reader.
+// The name is used for diagnostics.
+func ParseReader(name string, r io.Reader) (Node, []Diagnostic) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, []Diagnostic{NewDiagnostic(name, Loc{}, err.Error())}
+	}
+	return ParseString(name, string(data))
+}
+
+// ParseString parses the text. The text is registered as a source,
+// and locations of the nodes refer to the source.
+func ParseString(name string, text string) (Node, []Diagnostic) {
+	return parseSource(AddSource(name, text))
+}
+
+func parseSource(src *Source) (Node, []Diagnostic) {
+	return parseText(src, src.Text, Pos{}, newFixities())
+}
+
+// parseText parses the text as the source. The text is a part of
+// the source at the base position when parsing interpolations,
+// which share the fixities with the enclosing text.
+func parseText(src *Source, text string, base Pos, fixities map[string]Fixity) (node Node, diags []Diagnostic) {
+	errs := NewErrorListener(src)
+	lexer := NewTrompeLexer(&SourceStream{InputStream: antlr.NewInputStream(text),
+		Source: src, Fixities: fixities, Base: base})
+	lexer.RemoveErrorListeners()
+	lexer.AddErrorListener(errs)
 	stream := antlr.NewCommonTokenStream(lexer, 0)
 	p := NewTrompeParser(stream)
+	p.RemoveErrorListeners()
+	p.AddErrorListener(errs)
 	p.BuildParseTrees = true
 	tree := p.Chunk()
+	if len(errs.Diags) > 0 {
+		return nil, errs.Diags
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(listenerError)
+			if !ok {
+				panic(r)
+			}
+			node = nil
+			diags = []Diagnostic{NewDiagnostic(src.Name, e.loc, e.msg)}
+		}
+	}()
 	listener := NewChunkListener()
 	antlr.ParseTreeWalkerDefault.EnterRule(listener, tree)
-	fmt.Printf("%s\n", NodeDesc(&listener.Node))
-	return &listener.Node
+	return &listener.Node, nil
 }
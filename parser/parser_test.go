@@ -0,0 +1,287 @@
+package parser
+
+import (
+	. "github.com/szktty/trompe"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseExamples(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.tm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no examples")
+	}
+	for _, file := range files {
+		node, diags := Parse(file)
+		for _, diag := range diags {
+			t.Errorf("%s", diag.Error())
+		}
+		if node == nil && len(diags) == 0 {
+			t.Errorf("%s: no node", file)
+		}
+	}
+}
+
+func TestParseString(t *testing.T) {
+	node, diags := ParseString("test", "let x = (1, 2)\nshow(x)\n")
+	if len(diags) > 0 {
+		t.Fatalf("%v", diags)
+	}
+	if loc := node.Loc(); loc.File == 0 || loc.Start.Line != 1 {
+		t.Errorf("invalid location %v", *loc)
+	}
+
+	_, diags = ParseString("error", "let = 1\nlet y = \n")
+	if len(diags) < 2 {
+		t.Errorf("expected syntax errors, got %v", diags)
+	}
+}
+
+func TestParseStrings(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{`"a\tb\x41\u{3042}"`, `(str "a\tbAあ")`},
+		{`'#{x}\n'`, `(str "#{x}\n")`},
+		{"[[\nraw\\n]]", `(str "raw\\n")`},
+		{`"x = #{x + 1}!"`, `(interp (str "x = ") (binexp "+" (var "x") (int "1")) (str "!"))`},
+	}
+	for _, c := range cases {
+		node, diags := ParseString("test", c.src)
+		if len(diags) > 0 {
+			t.Errorf("%s: %v", c.src, diags)
+			continue
+		}
+		stats := node.(*ChunkNode).Block.Stats
+		if got := NodeDesc(stats[0]); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.src, got, c.want)
+		}
+	}
+
+	for _, src := range []string{`"#{}"`, `"\u{110000}"`, `"\256"`, `"#{let x = 1}"`, `"#{1; 2}"`} {
+		if _, diags := ParseString("error", src); len(diags) == 0 {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+
+	// the locations in interpolations are in the source
+	node, diags := ParseString("test", "let s = 1\n\"a #{s + 1}\"\n")
+	if len(diags) > 0 {
+		t.Fatalf("%v", diags)
+	}
+	interp := node.(*ChunkNode).Block.Stats[1].(*InterpExpNode)
+	binexp := interp.Parts[1].(*BinaryExpNode)
+	if loc := binexp.Left.Loc(); loc.Start.Line != 2 || loc.Start.Col != 5 || loc.Start.Offset != 15 {
+		t.Errorf("invalid location %v", *loc)
+	}
+	_, diags = ParseString("error", "\n\"#{1 +}\"")
+	if len(diags) != 1 || diags[0].Loc.Start.Line != 2 {
+		t.Errorf("invalid error %v", diags)
+	}
+}
+
+func TestParseOperators(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"a * b +++ c", `(binexp "*" (var "a") (binexp "+++" (var "b") (var "c")))`},
+		{"infixl 9 +++\na * b +++ c", `(binexp "+++" (binexp "*" (var "a") (var "b")) (var "c"))`},
+		{"infixr 12 ^^\na ^^ b ^^ c + d", `(binexp "+" (binexp "^^" (var "a") (binexp "^^" (var "b") (var "c"))) (var "d"))`},
+		{"(<+>)", `(var "<+>")`},
+		{"n*-1", `(binexp "*" (var "n") (unexp "-" (int "1")))`},
+		{"a==~b", `(binexp "==" (var "a") (unexp "~" (var "b")))`},
+		// declarations in other sources do not apply
+		{"a * b +++ c", `(binexp "*" (var "a") (binexp "+++" (var "b") (var "c")))`},
+	}
+	for _, c := range cases {
+		node, diags := ParseString("test", c.src)
+		if len(diags) > 0 {
+			t.Errorf("%s: %v", c.src, diags)
+			continue
+		}
+		stats := node.(*ChunkNode).Block.Stats
+		if got := NodeDesc(stats[len(stats)-1]); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.src, got, c.want)
+		}
+	}
+
+	for _, src := range []string{"def (+++)(a) = a", "infixl 14 +++", "infix 4 =~=\na == b =~= c"} {
+		if _, diags := ParseString("error", src); len(diags) == 0 {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
+
+func TestParseModuleFixities(t *testing.T) {
+	Init()
+	node, diags := ParseString("test", "xs @ ys @ zs")
+	if len(diags) > 0 {
+		t.Fatalf("%v", diags)
+	}
+	want := `(binexp "@" (var "xs") (binexp "@" (var "ys") (var "zs")))`
+	if got := NodeDesc(node.(*ChunkNode).Block.Stats[0]); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseExceptions(t *testing.T) {
+	node, diags := ParseString("test", `raise Error("x") + 1`)
+	if len(diags) > 0 {
+		t.Fatalf("%v", diags)
+	}
+	want := `(raise (binexp "+" (funcall (var "Error") (eltlist [(str "x") ])) (int "1")))`
+	if got := NodeDesc(node.(*ChunkNode).Block.Stats[0]); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	src := "try\n  f()\nrescue KeyError(m) then\n  0\nrescue e then\n  1\nensure\n  g()\nend\n"
+	node, diags = ParseString("test", src)
+	if len(diags) > 0 {
+		t.Fatalf("%v", diags)
+	}
+	try_, ok := node.(*ChunkNode).Block.Stats[0].(*TryStatNode)
+	if !ok || len(try_.Claus) != 2 || try_.EnsureAction == nil {
+		t.Errorf("invalid try statement %s", NodeDesc(node))
+	}
+}
+
+func TestParseLists(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"1 :: 2 :: xs", `(binexp "::" (int "1") (binexp "::" (int "2") (var "xs")))`},
+		{"x + 1 :: xs", `(binexp "::" (binexp "+" (var "x") (int "1")) (var "xs"))`},
+	}
+	for _, c := range cases {
+		node, diags := ParseString("test", c.src)
+		if len(diags) > 0 {
+			t.Errorf("%s: %v", c.src, diags)
+			continue
+		}
+		if got := NodeDesc(node.(*ChunkNode).Block.Stats[0]); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.src, got, c.want)
+		}
+	}
+
+	node, diags := ParseString("test", "case xs of\nwhen a :: b :: _ then 1\nend\n")
+	if len(diags) > 0 {
+		t.Fatalf("%v", diags)
+	}
+	case_ := node.(*ChunkNode).Block.Stats[0].(*CaseStatNode)
+	want := `(consptn (varptn a) (consptn (varptn b) (varptn _)))`
+	if got := NodeDesc(case_.Claus[0].Ptn); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseAttrs(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"r.name", `(attr (var "r") "name")`},
+		{"(r).name", `(attr (paren (var "r")) "name")`},
+		{"xs[0].name.len", `(attr (attr (index (var "xs") (int "0")) "name") "len")`},
+		{"-p.x", `(unexp "-" (attr (var "p") "x"))`},
+	}
+	for _, c := range cases {
+		node, diags := ParseString("test", c.src)
+		if len(diags) > 0 {
+			t.Errorf("%s: %v", c.src, diags)
+			continue
+		}
+		if got := NodeDesc(node.(*ChunkNode).Block.Stats[0]); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.src, got, c.want)
+		}
+	}
+
+	node, diags := ParseString("test", "f(x).width")
+	if len(diags) > 0 {
+		t.Fatalf("%v", diags)
+	}
+	attr, ok := node.(*ChunkNode).Block.Stats[0].(*AttrExpNode)
+	if !ok || attr.Name.Text != "width" {
+		t.Errorf("got %s", NodeDesc(node))
+	} else if _, ok := attr.Exp.(*FunCallExpNode); !ok {
+		t.Errorf("got %s", NodeDesc(attr))
+	}
+}
+
+func TestParseOrAsPatterns(t *testing.T) {
+	src := "case x of\nwhen 0 | 1 | 2 then 1\nwhen h :: _ as xs then 2\nend\n"
+	node, diags := ParseString("test", src)
+	if len(diags) > 0 {
+		t.Fatalf("%v", diags)
+	}
+	case_ := node.(*ChunkNode).Block.Stats[0].(*CaseStatNode)
+	wants := []string{
+		`(orptn (orptn (intptn "0") (intptn "1")) (intptn "2"))`,
+		`(asptn (consptn (varptn h) (varptn _)) xs)`,
+	}
+	for i, want := range wants {
+		if got := NodeDesc(case_.Claus[i].Ptn); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestParseNegativePatterns(t *testing.T) {
+	src := "case x of\nwhen -1 then 1\nwhen -10...-2 then 2\nwhen -0.5 then 3\nend\n"
+	node, diags := ParseString("test", src)
+	if len(diags) > 0 {
+		t.Fatalf("%v", diags)
+	}
+	case_ := node.(*ChunkNode).Block.Stats[0].(*CaseStatNode)
+	wants := []string{
+		`(intptn "-1")`,
+		`(rangeptn (intptn "-10") ... (intptn "-2"))`,
+		`(floatptn "-0.5")`,
+	}
+	for i, want := range wants {
+		if got := NodeDesc(case_.Claus[i].Ptn); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+	if loc := case_.Claus[0].Ptn.Loc(); loc.End.Offset-loc.Start.Offset != 1 {
+		t.Errorf("location of -1 is %v", loc)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	src := "case some(1) of\nwhen some(x) then x\nwhen none then 0\nend\n"
+	node, diags := ParseString("test", src)
+	if len(diags) > 0 {
+		t.Fatalf("%v", diags)
+	}
+	case_ := node.(*ChunkNode).Block.Stats[0].(*CaseStatNode)
+	if got, want := NodeDesc(case_.Cond), `(some (int "1"))`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	wants := []string{`(someptn (varptn x))`, `(noneptn)`}
+	for i, want := range wants {
+		if got := NodeDesc(case_.Claus[i].Ptn); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestParseLetElse(t *testing.T) {
+	src := "let some(x) = opt else\n  raise Error(\"none\")\nend\n"
+	node, diags := ParseString("test", src)
+	if len(diags) > 0 {
+		t.Fatalf("%v", diags)
+	}
+	let := node.(*ChunkNode).Block.Stats[0].(*LetStatNode)
+	if got, want := NodeDesc(let.Ptn), `(someptn (varptn x))`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if let.Else == nil || let.ElseAction == nil || len(let.ElseAction.Stats) != 1 {
+		t.Errorf("no else block: %s", NodeDesc(let))
+	}
+}
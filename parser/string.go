@@ -0,0 +1,219 @@
+package parser
+
+import (
+	"bytes"
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+	. "github.com/szktty/trompe"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// unquoteLong removes the brackets of the long string "[==[...]==]".
+// A newline immediately following the opening bracket is skipped.
+func unquoteLong(s string) string {
+	n := strings.Index(s[1:], "[") + 2
+	s = s[n : len(s)-n]
+	if strings.HasPrefix(s, "\r\n") {
+		return s[2:]
+	}
+	return strings.TrimPrefix(s, "\n")
+}
+
+// runeLoc returns the location of the rune at the index of the token text.
+func runeLoc(tok antlr.Token, text []rune, i int) Loc {
+	loc := NewLocAntlr(tok)
+	pos := loc.Start
+	for _, c := range text[:i] {
+		if c == '\n' {
+			pos.Line++
+			pos.Col = 0
+		} else {
+			pos.Col++
+		}
+	}
+	pos.Offset += i
+	loc.Start = pos
+	loc.End = pos
+	return loc
+}
+
+// decodeEscapes decodes the escape sequences in text[start:end] of the token.
+// The lexer has already validated the forms of the sequences.
+func decodeEscapes(tok antlr.Token, text []rune, start int, end int) string {
+	var buf bytes.Buffer
+	for i := start; i < end; i++ {
+		c := text[i]
+		if c != '\\' {
+			buf.WriteRune(c)
+			continue
+		}
+		esc := i
+		i++
+		switch c = text[i]; c {
+		case 'a':
+			buf.WriteByte('\a')
+		case 'b':
+			buf.WriteByte('\b')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case 'v':
+			buf.WriteByte('\v')
+		case 'z':
+			// skips the following white spaces including line breaks
+			for i+1 < end && unicode.IsSpace(text[i+1]) {
+				i++
+			}
+		case '\r', '\n':
+			// line continuation
+			if c == '\r' && i+1 < end && text[i+1] == '\n' {
+				i++
+			}
+		case 'x':
+			n, _ := strconv.ParseUint(string(text[i+1:i+3]), 16, 8)
+			buf.WriteByte(byte(n))
+			i += 2
+		case 'u':
+			rbrace := i + 2
+			for text[rbrace] != '}' {
+				rbrace++
+			}
+			n, err := strconv.ParseUint(string(text[i+2:rbrace]), 16, 32)
+			if err != nil || n > unicode.MaxRune || !utf8.ValidRune(rune(n)) {
+				syntaxError(runeLoc(tok, text, esc), "invalid Unicode code point \"%s\"",
+					string(text[esc:rbrace+1]))
+			}
+			buf.WriteRune(rune(n))
+			i = rbrace
+		default:
+			if '0' <= c && c <= '9' {
+				// "\d", "\dd" or "\ddd" where the first digit is 0-2
+				digits := i + 1
+				for n := 1; n < 3 && digits < end && '0' <= text[digits] && text[digits] <= '9'; n++ {
+					if n == 2 && c > '2' {
+						break
+					}
+					digits++
+				}
+				n, _ := strconv.Atoi(string(text[i:digits]))
+				if n > 255 {
+					syntaxError(runeLoc(tok, text, esc), "decimal escape too large \"%s\"",
+						string(text[esc:digits]))
+				}
+				buf.WriteByte(byte(n))
+				i = digits - 1
+			} else {
+				// '"', '\'', '\\' and '#'
+				buf.WriteRune(c)
+			}
+		}
+	}
+	return buf.String()
+}
+
+// interpString splits the double-quoted string into string literals
+// and interpolations "#{exp}". A string without interpolations is
+// a simple string literal.
+func interpString(tok antlr.Token) ExpNode {
+	value := NewTokenAntlr(tok)
+	text := []rune(value.Text)
+	end := len(text) - 1
+	var parts []Node
+	lit := 1
+	addLit := func(i int) {
+		if lit < i {
+			s := decodeEscapes(tok, text, lit, i)
+			parts = append(parts, &StrExpNode{Value: Token{Loc: runeLoc(tok, text, lit), Text: s}})
+		}
+	}
+	for i := 1; i < end; i++ {
+		switch text[i] {
+		case '\\':
+			// the escaped character never begins an interpolation
+			i++
+		case '#':
+			if text[i+1] != '{' {
+				break
+			}
+			rbrace := closingBrace(text, i+2, end)
+			if rbrace < 0 {
+				syntaxError(runeLoc(tok, text, i), "unterminated interpolation")
+			}
+			addLit(i)
+			parts = append(parts, parseInterp(tok, text, i+2, rbrace))
+			i = rbrace
+			lit = rbrace + 1
+		}
+	}
+	if len(parts) == 0 {
+		value.Text = decodeEscapes(tok, text, 1, end)
+		return &StrExpNode{Value: value}
+	}
+	addLit(end)
+	return &InterpExpNode{Value: value, Parts: parts}
+}
+
+// closingBrace returns the index of the brace closing the interpolation
+// beginning at the index, or -1. Braces in the nested strings are ignored.
+func closingBrace(text []rune, i int, end int) int {
+	depth := 1
+	for ; i < end; i++ {
+		switch c := text[i]; c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case '"', '\'':
+			for i++; i < end && text[i] != c; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// parseInterp parses text[start:end] of the token as an expression.
+// The locations of the nodes are offset to the position in the source.
+func parseInterp(tok antlr.Token, text []rune, start int, end int) ExpNode {
+	loc := runeLoc(tok, text, start-2)
+	src := &Source{}
+	if s, ok := tok.GetInputStream().(*SourceStream); ok {
+		src = s.Source
+	}
+	pos := runeLoc(tok, text, start).Start
+	base := Pos{Line: pos.Line - 1, Col: pos.Col, Offset: pos.Offset}
+	node, diags := parseText(src, string(text[start:end]), base, fixitiesOf(tok))
+	if len(diags) > 0 {
+		syntaxError(diags[0].Loc, "%s", diags[0].Message)
+	}
+	stats := node.(*ChunkNode).Block.Stats
+	if len(stats) != 1 || !isExp(stats[0]) {
+		syntaxError(loc, "interpolation must be an expression")
+	}
+	return stats[0]
+}
+
+// isExp reports whether the statement is an expression. Control
+// statements such as if and case have values and are expressions.
+func isExp(node Node) bool {
+	switch node.(type) {
+	case *LetStatNode, *DefStatNode, *ShortDefStatNode, *AssignStatNode,
+		*ForStatNode, *RetStatNode, *StructDeclNode, *TypeDeclNode,
+		*TraitDeclNode, *ImplDeclNode, *FixityDeclNode:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,49 @@
+package trompe
+
+import (
+	"fmt"
+)
+
+// Partial is a closure applied to some of the arguments such as "f(1, _)".
+// Nil arguments are placeholders filled with the arguments of the call.
+type Partial struct {
+	Clos  Closure
+	Args  []Value
+	arity int
+}
+
+func NewPartial(clos Closure, args []Value) *Partial {
+	arity := 0
+	for _, arg := range args {
+		if arg == nil {
+			arity++
+		}
+	}
+	return &Partial{Clos: clos, Args: args, arity: arity}
+}
+
+func (p *Partial) Type() int {
+	return ValueTypeClos
+}
+
+func (p *Partial) Desc() string {
+	return fmt.Sprintf("<partial %p>", p)
+}
+
+func (p *Partial) Arity() int {
+	return p.arity
+}
+
+func (p *Partial) Apply(ip *Interp, ctx *Context, env *Env) (Value, error) {
+	args := make([]Value, len(p.Args))
+	j := 0
+	for i, arg := range p.Args {
+		if arg == nil {
+			args[i] = ctx.Args[j]
+			j++
+		} else {
+			args[i] = arg
+		}
+	}
+	return ip.Apply(ctx, p.Clos, args...)
+}
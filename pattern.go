@@ -2,7 +2,6 @@ package trompe
 
 import (
 	"fmt"
-	"strings"
 )
 
 var ptnWildcard = &ptnVar{"_"}
@@ -12,20 +11,33 @@ type Pattern struct {
 }
 
 type ptnComp interface {
-	Eval(*Env, Value) bool
+	Eval(*matching, Value) bool
 	Desc() string
 }
 
+// matching is the state of matching a value with a pattern.
+// The variables are bound to the environment only if the whole
+// pattern matches, so pins refer to the values before matching.
+type matching struct {
+	ip    *Interp
+	ctx   *Context
+	env   *Env
+	binds map[string]Value
+	err   error // stops matching
+}
+
 func newPattern(c ptnComp) *Pattern {
 	return &Pattern{c}
 }
 
-func NewPatternFromNode(n PtnNode) *Pattern {
-	comp := parsePtnNode(n)
+// NewPatternFromNode returns the pattern of the node. Ctors has the type
+// names of the constructor patterns.
+func NewPatternFromNode(n PtnNode, ctors map[*CtorPtnNode]string) *Pattern {
+	comp := parsePtnNode(n, ctors)
 	return newPattern(comp)
 }
 
-func parsePtnNode(n PtnNode) ptnComp {
+func parsePtnNode(n PtnNode, ctors map[*CtorPtnNode]string) ptnComp {
 	switch n := n.(type) {
 	case *UnitPtnNode:
 		return &ptnUnit{}
@@ -34,18 +46,80 @@ func parsePtnNode(n PtnNode) ptnComp {
 	case *IntPtnNode:
 		i, _ := StrToInt(n.Value.Text)
 		return &ptnInt{i}
+	case *FloatPtnNode:
+		f, _ := StrToFloat(n.Value.Text)
+		return &ptnFloat{f}
 	case *StrPtnNode:
 		return &ptnStr{n.Value.Text}
+	case *RangePtnNode:
+		p := &ptnRange{close: n.Close}
+		if left, ok := n.Left.(*IntPtnNode); ok {
+			p.left, _ = StrToInt(left.Value.Text)
+		}
+		if right, ok := n.Right.(*IntPtnNode); ok {
+			p.right, _ = StrToInt(right.Value.Text)
+		}
+		return p
+	case *TuplePtnNode:
+		p := &ptnTuple{}
+		for _, elt := range n.Elts.Elts {
+			p.comps = append(p.comps, parsePtnNode(elt, ctors))
+		}
+		return p
+	case *ListPtnNode:
+		p := &ptnList{}
+		for _, elt := range n.Elts.Elts {
+			p.comps = append(p.comps, parsePtnNode(elt, ctors))
+		}
+		return p
+	case *ConsPtnNode:
+		return &ptnCons{head: parsePtnNode(n.Left, ctors), tail: parsePtnNode(n.Right, ctors)}
 	case *VarPtnNode:
 		return &ptnVar{n.Name.Text}
+	case *PinPtnNode:
+		return &ptnPin{n.Name.Text}
+	case *OrPtnNode:
+		return &ptnOr{left: parsePtnNode(n.Left, ctors), right: parsePtnNode(n.Right, ctors)}
+	case *AsPtnNode:
+		return &ptnAs{comp: parsePtnNode(n.Ptn, ctors), name: n.Name.Text}
+	case *SomePtnNode:
+		return &ptnOpt{parsePtnNode(n.Value, ctors)}
+	case *NonePtnNode:
+		return &ptnOpt{}
+	case *CtorPtnNode:
+		p := &ptnCtor{typeName: ctors[n], tag: n.Name.Text}
+		if n.Args != nil {
+			for _, arg := range n.Args.Elts {
+				p.comps = append(p.comps, parsePtnNode(arg, ctors))
+			}
+		}
+		return p
+	case *RecordPtnNode:
+		p := &ptnRecord{}
+		for _, field := range n.Fields {
+			p.fields = append(p.fields, field.Name.Text)
+			if field.Ptn != nil {
+				p.comps = append(p.comps, parsePtnNode(field.Ptn, ctors))
+			} else {
+				p.comps = append(p.comps, &ptnVar{field.Name.Text})
+			}
+		}
+		return p
 	default:
 		panic("notimpl")
 		return nil
 	}
 }
 
-func (p *Pattern) Eval(env *Env, v Value) bool {
-	return p.Comp.Eval(env, v)
+func (p *Pattern) Eval(ip *Interp, ctx *Context, env *Env, v Value) (bool, error) {
+	m := &matching{ip: ip, ctx: ctx, env: env, binds: make(map[string]Value, 4)}
+	if !p.Comp.Eval(m, v) {
+		return false, m.err
+	}
+	for name, value := range m.binds {
+		env.Set(name, value)
+	}
+	return true, nil
 }
 
 func (p *Pattern) Type() int {
@@ -59,7 +133,7 @@ func (p *Pattern) Desc() string {
 type ptnUnit struct {
 }
 
-func (p *ptnUnit) Eval(env *Env, v Value) bool {
+func (p *ptnUnit) Eval(m *matching, v Value) bool {
 	return v == SharedUnit
 }
 
@@ -71,7 +145,7 @@ type ptnBool struct {
 	v bool
 }
 
-func (p *ptnBool) Eval(env *Env, v Value) bool {
+func (p *ptnBool) Eval(m *matching, v Value) bool {
 	if b, ok := ValueToBool(v); ok {
 		return p.v == b.Value
 	} else {
@@ -91,7 +165,7 @@ type ptnInt struct {
 	v int
 }
 
-func (p *ptnInt) Eval(env *Env, v Value) bool {
+func (p *ptnInt) Eval(m *matching, v Value) bool {
 	if i, ok := ValueToInt(v); ok {
 		return p.v == i.Value
 	} else {
@@ -103,12 +177,11 @@ func (p *ptnInt) Desc() string {
 	return fmt.Sprintf("%d", p.v)
 }
 
-/*
 type ptnFloat struct {
 	v float64
 }
 
-func (p *ptnFloat) Eval(env *Env, v Value) bool {
+func (p *ptnFloat) Eval(m *matching, v Value) bool {
 	if f, ok := ValueToFloat(v); ok {
 		return p.v == f.Value
 	} else {
@@ -117,15 +190,37 @@ func (p *ptnFloat) Eval(env *Env, v Value) bool {
 }
 
 func (p *ptnFloat) Desc() string {
-	return fmt.Sprintf("%f", p.v)
+	return NewFloat(p.v).Desc()
+}
+
+type ptnRange struct {
+	left  int
+	right int
+	close bool
+}
+
+func (p *ptnRange) Eval(m *matching, v Value) bool {
+	if i, ok := ValueToInt(v); ok {
+		return p.left <= i.Value &&
+			(i.Value < p.right || p.close && i.Value == p.right)
+	} else {
+		return false
+	}
+}
+
+func (p *ptnRange) Desc() string {
+	if p.close {
+		return fmt.Sprintf("%d...%d", p.left, p.right)
+	} else {
+		return fmt.Sprintf("%d..<%d", p.left, p.right)
+	}
 }
-*/
 
 type ptnStr struct {
 	v string
 }
 
-func (p *ptnStr) Eval(env *Env, v Value) bool {
+func (p *ptnStr) Eval(m *matching, v Value) bool {
 	if s, ok := ValueToString(v); ok {
 		return p.v == s.Value
 	} else {
@@ -141,14 +236,14 @@ type ptnList struct {
 	comps []ptnComp
 }
 
-func (p *ptnList) Eval(env *Env, v Value) bool {
+func (p *ptnList) Eval(m *matching, v Value) bool {
 	if l, ok := ValueToList(v); ok {
 		if len(p.comps) == l.Len() {
-			iter := l
 			for _, comp := range p.comps {
-				if !comp.Eval(env, iter.Value) {
+				if !comp.Eval(m, l.Value) {
 					return false
 				}
+				l = l.Next
 			}
 			return true
 		} else {
@@ -171,6 +266,23 @@ func (p *ptnList) Desc() string {
 	return desc
 }
 
+type ptnCons struct {
+	head ptnComp
+	tail ptnComp
+}
+
+func (p *ptnCons) Eval(m *matching, v Value) bool {
+	if l, ok := ValueToList(v); ok && !l.IsNil() {
+		return p.head.Eval(m, l.Value) && p.tail.Eval(m, l.Next)
+	} else {
+		return false
+	}
+}
+
+func (p *ptnCons) Desc() string {
+	return fmt.Sprintf("%s :: %s", p.head.Desc(), p.tail.Desc())
+}
+
 type ptnTuple struct {
 	comps []ptnComp
 }
@@ -179,7 +291,7 @@ func newPtnTuple(c ...ptnComp) *ptnTuple {
 	return &ptnTuple{c}
 }
 
-func (p *ptnTuple) Eval(env *Env, v Value) bool {
+func (p *ptnTuple) Eval(m *matching, v Value) bool {
 	if t, ok := ValueToTuple(v); ok {
 		if len(p.comps) != t.Len() {
 			return false
@@ -188,7 +300,7 @@ func (p *ptnTuple) Eval(env *Env, v Value) bool {
 		for i := 0; i < len(p.comps); i++ {
 			e1 := p.comps[i]
 			e2 := t.Values[i]
-			if !e1.Eval(env, e2) {
+			if !e1.Eval(m, e2) {
 				return false
 			}
 		}
@@ -210,17 +322,106 @@ func (p *ptnTuple) Desc() string {
 	return desc
 }
 
+type ptnRecord struct {
+	fields []string
+	comps  []ptnComp
+}
+
+func (p *ptnRecord) Eval(m *matching, v Value) bool {
+	if r, ok := ValueToRecord(v); ok {
+		for i, field := range p.fields {
+			fv := r.Get(field)
+			if fv == nil || !p.comps[i].Eval(m, fv) {
+				return false
+			}
+		}
+		return true
+	} else {
+		return false
+	}
+}
+
+func (p *ptnRecord) Desc() string {
+	desc := "{"
+	for i, field := range p.fields {
+		desc += fmt.Sprintf("%s = %s", field, p.comps[i].Desc())
+		if i+1 < len(p.fields) {
+			desc += ", "
+		}
+	}
+	desc += "}"
+	return desc
+}
+
+type ptnCtor struct {
+	typeName string
+	tag      string
+	comps    []ptnComp
+}
+
+func (p *ptnCtor) Eval(m *matching, v Value) bool {
+	if vv, ok := ValueToVariant(v); ok {
+		if vv.TypeName != p.typeName || vv.Tag != p.tag || len(vv.Values) != len(p.comps) {
+			return false
+		}
+		for i, comp := range p.comps {
+			if !comp.Eval(m, vv.Values[i]) {
+				return false
+			}
+		}
+		return true
+	} else {
+		return false
+	}
+}
+
+func (p *ptnCtor) Desc() string {
+	if len(p.comps) == 0 {
+		return p.tag
+	}
+	desc := p.tag + "("
+	for i, e := range p.comps {
+		desc += e.Desc()
+		if i+1 < len(p.comps) {
+			desc += ", "
+		}
+	}
+	desc += ")"
+	return desc
+}
+
+// ptnOpt matches none if comp is nil, otherwise some.
 type ptnOpt struct {
-	v Pattern
+	comp ptnComp
+}
+
+func (p *ptnOpt) Eval(m *matching, v Value) bool {
+	if opt, ok := ValueToOption(v); ok {
+		if p.comp == nil {
+			return opt.Tag == OptionNoneTag
+		} else {
+			return opt.Tag == OptionSomeTag && p.comp.Eval(m, opt.Values[0])
+		}
+	} else {
+		return false
+	}
+}
+
+func (p *ptnOpt) Desc() string {
+	if p.comp == nil {
+		return "none"
+	} else {
+		return fmt.Sprintf("some(%s)", p.comp.Desc())
+	}
 }
 
 type ptnVar struct {
 	Name string
 }
 
-func (p *ptnVar) Eval(env *Env, v Value) bool {
-	if !strings.HasPrefix(p.Name, "_") {
-		env.Set(p.Name, v)
+func (p *ptnVar) Eval(m *matching, v Value) bool {
+	if p.Name != "_" {
+		m.binds[p.Name] = v
 	}
 	return true
 }
@@ -229,13 +430,54 @@ func (p *ptnVar) Desc() string {
 	return fmt.Sprintf("$%s", p.Name)
 }
 
+type ptnOr struct {
+	left  ptnComp
+	right ptnComp
+}
+
+func (p *ptnOr) Eval(m *matching, v Value) bool {
+	return p.left.Eval(m, v) || m.err == nil && p.right.Eval(m, v)
+}
+
+func (p *ptnOr) Desc() string {
+	return fmt.Sprintf("%s | %s", p.left.Desc(), p.right.Desc())
+}
+
+type ptnAs struct {
+	comp ptnComp
+	name string
+}
+
+func (p *ptnAs) Eval(m *matching, v Value) bool {
+	if p.comp.Eval(m, v) {
+		m.binds[p.name] = v
+		return true
+	} else {
+		return false
+	}
+}
+
+func (p *ptnAs) Desc() string {
+	return fmt.Sprintf("%s as $%s", p.comp.Desc(), p.name)
+}
+
 type ptnPin struct {
 	Name string
 }
 
-func (p *ptnPin) Eval(env *Env, v Value) bool {
-	// TODO
-	return true
+func (p *ptnPin) Eval(m *matching, v Value) bool {
+	pinned := m.env.Get(p.Name)
+	if pinned == nil {
+		m.err = NewRuntimeError(m.ctx, KeyError,
+			fmt.Sprintf("pinned variable %s is not bound", p.Name))
+		return false
+	}
+	eq, err := m.ip.Equal(m.ctx, pinned, v)
+	if err != nil {
+		m.err = err
+		return false
+	}
+	return eq
 }
 
 func (p *ptnPin) Desc() string {
@@ -0,0 +1,144 @@
+package trompe
+
+import (
+	"testing"
+)
+
+func TestGuards(t *testing.T) {
+	mk := func(x string) *CaseStatNode {
+		return caseOf(in(x),
+			clau(vp("n"), bin(vr("n"), "<", in("0")), st("neg")),
+			clau(ip("0"), nil, st("zero")),
+			clau(vp("n"), bin(vr("n"), ">", in("100")), st("big")),
+			clau(vp("_"), nil, st("pos")))
+	}
+	for x, want := range map[string]string{"-5": "neg", "0": "zero", "500": "big", "7": "pos"} {
+		eval(t, want, mk(x))
+	}
+	// guards are bool
+	check(t, 1, caseOf(in("1"), clau(vp("n"), in("1"), st("x")), clau(vp("_"), nil, st("y"))))
+}
+
+func TestPins(t *testing.T) {
+	mk := func(x Node) Node {
+		return call(anon([]string{"key"}, caseOf(x,
+			clau(pin("key"), nil, st("same")),
+			clau(vp("_"), nil, st("other")))), lst(in("1"), in("2")))
+	}
+	eval(t, "same", mk(lst(in("1"), in("2"))))
+	eval(t, "other", mk(lst(in("2"))))
+
+	// pins refer to the variables before the pattern
+	eval(t, "2", let(vp("x"), in("1")),
+		caseElse(tupe(in("2"), in("1")), in("0"), clau(tup(vp("x"), pin("x")), nil, vr("x"))))
+	check(t, 1, caseElse(tupe(in("3"), in("3")), st("ne"), clau(tup(vp("a"), pin("a")), nil, st("eq"))))
+	// unbound and mismatched pins
+	check(t, 1, caseElse(in("1"), in("2"), clau(pin("nope"), nil, in("1"))))
+	check(t, 1, call(anon([]string{"k"}, caseElse(in("1"), in("2"), clau(pin("k"), nil, in("1")))), st("s")))
+
+	// pins match with Eq
+	eq := impl("Eq", "point", sdef("eq", []string{"a", "b"},
+		bin(attr(vr("a"), "x"), "==", attr(vr("b"), "x"))))
+	eval(t, "same", pointDecl(), eq, let(vp("p"), point("1", "2")),
+		caseElse(point("1", "9"), st("diff"), clau(pin("p"), nil, st("same"))))
+}
+
+func TestClauseScopes(t *testing.T) {
+	// the bindings of a clause do not leak into the others and after the case
+	c := caseOf(in("5"),
+		clau(vp("k"), bl(false), in("0")),
+		clau(vp("k"), nil, vr("k")))
+	eval(t, "2", let(vp("k"), in("1")), c, bin(vr("k"), "+", in("1")))
+	eval(t, "5", let(vp("k"), in("1")), c)
+	eval(t, "2", let(vp("k"), in("1")),
+		caseElse(tupe(some(st("a")), in("0")), unit(), clau(tup(vp("k"), ip("1")), nil, unit())),
+		bin(vr("k"), "+", in("1")))
+}
+
+func TestStructuralPatterns(t *testing.T) {
+	list := func(x Node) Node {
+		return caseOf(x,
+			clau(lptn(), nil, st("empty")),
+			clau(lptn(vp("a"), vp("b")), nil, st("two")),
+			clau(cons(vp("h"), cons(ip("5"), vp("t"))), nil, st("second5")),
+			clau(cons(vp("h"), vp("t")), nil, &InterpExpNode{Parts: []Node{st("h="), vr("h"), st(" t="), vr("t")}}))
+	}
+	tuple := func(x Node) Node {
+		return caseOf(x,
+			clau(tup(orp(ip("0"), ip("1")), vp("_")), nil, st("small")),
+			clau(tup(&RangePtnNode{Left: ip("2"), Right: ip("9"), Close: true}, vp("_")), nil, st("digit")),
+			clau(orp(tup(vp("a"), ip("100")), tup(ip("100"), vp("a"))), nil, st("hundred")),
+			clau(&AsPtnNode{Ptn: tup(vp("x"), vp("_")), Name: tok("whole")}, nil,
+				&InterpExpNode{Parts: []Node{vr("whole")}}))
+	}
+	option := func(x Node) Node {
+		return caseOf(x,
+			clau(somep(ip("0")), nil, st("zero")),
+			clau(somep(vp("_n")), nil, &InterpExpNode{Parts: []Node{vr("_n")}}),
+			clau(nonep(), nil, st("none")))
+	}
+	cases := []struct {
+		exp  Node
+		want string
+	}{
+		{list(lst()), "empty"},
+		{list(lst(in("1"), in("2"))), "two"},
+		{list(lst(in("1"), in("5"), in("3"))), "second5"},
+		{list(lst(in("1"), in("2"), in("3"))), "h=1 t=[2, 3]"},
+		{list(bin(in("7"), "::", lst())), "h=7 t=[]"},
+		{tuple(tupe(in("1"), in("5"))), "small"},
+		{tuple(tupe(in("9"), in("5"))), "digit"},
+		{tuple(tupe(in("100"), in("5"))), "hundred"},
+		{tuple(tupe(in("10"), in("5"))), "(10, 5)"},
+		{option(some(in("0"))), "zero"},
+		{option(some(in("3"))), "3"},
+		{option(none()), "none"},
+	}
+	for _, c := range cases {
+		t.Run(c.want, func(t *testing.T) {
+			eval(t, c.want, c.exp)
+		})
+	}
+
+	// the variables of or-patterns
+	check(t, 1, caseOf(in("1"), clau(orp(vp("a"), ip("1")), nil, in("1"))))
+	check(t, 1, caseOf(tupe(in("1"), st("a")),
+		clau(orp(tup(vp("a"), vp("_")), tup(vp("_"), vp("a"))), nil, in("1"))))
+}
+
+func TestNegativePatterns(t *testing.T) {
+	sign := func(x Node) Node {
+		return caseOf(x,
+			clau(ip("-1"), nil, st("minus one")),
+			clau(&RangePtnNode{Left: ip("-10"), Right: ip("-2"), Close: true}, nil, st("negative")),
+			clau(vp("_"), nil, st("other")))
+	}
+	eval(t, "minus one", sign(un("-", in("1"))))
+	eval(t, "negative", sign(un("-", in("10"))))
+	eval(t, "other", sign(un("-", in("11"))))
+	eval(t, "other", sign(in("1")))
+	eval(t, "half", caseOf(un("-", fl("0.5")),
+		clau(&FloatPtnNode{Value: tok("-0.5")}, nil, st("half")),
+		clau(vp("_"), nil, st("other"))))
+	// -1 and 1 are different values
+	check(t, 0, caseOf(in("1"),
+		clau(ip("-1"), nil, unit()), clau(ip("1"), nil, unit()), clau(vp("_"), nil, unit())))
+}
+
+func TestCtorPatternTypes(t *testing.T) {
+	node := ctorp("Empty")
+	ptn := NewPatternFromNode(node, map[*CtorPtnNode]string{node: "shape"})
+	cases := []struct {
+		v    Value
+		want bool
+	}{
+		{NewVariant("shape", "Empty", nil), true},
+		{NewVariant("box", "Empty", nil), false},
+		{NewVariant("shape", "Circle", nil), false},
+	}
+	for _, c := range cases {
+		if ok, err := ptn.Eval(nil, nil, NewEnv(nil), c.v); ok != c.want || err != nil {
+			t.Errorf("%s: got %v, %v", c.v.Desc(), ok, err)
+		}
+	}
+}
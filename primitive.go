@@ -11,6 +11,9 @@ type Primitive struct {
 
 type PrimFun = func(*Context, []Value, int) (Value, error)
 
+// VariadicArity is the arity of primitives taking any number of arguments.
+const VariadicArity = -1
+
 func NewPrim(f func(*Context, []Value, int) (Value, error),
 	arity int) *Primitive {
 	return &Primitive{f, arity}
@@ -20,9 +20,9 @@ func (r *Range) Type() int {
 
 func (r *Range) Desc() string {
 	if r.Close {
-		return fmt.Sprintf("%d..<%d", r.Start, r.End)
-	} else {
 		return fmt.Sprintf("%d...%d", r.Start, r.End)
+	} else {
+		return fmt.Sprintf("%d..<%d", r.Start, r.End)
 	}
 }
 
@@ -0,0 +1,98 @@
+package trompe
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Record struct {
+	Name   string
+	Fields []string
+	Values []Value
+}
+
+func NewRecord(name string, fields []string, values []Value) *Record {
+	return &Record{Name: name, Fields: fields, Values: values}
+}
+
+// NewRecordTemplate returns a record without values. The compiler puts
+// templates in literals to create and update records.
+func NewRecordTemplate(name string, fields []string) *Record {
+	return &Record{Name: name, Fields: fields}
+}
+
+func (r *Record) Type() int {
+	return ValueTypeRecord
+}
+
+func (r *Record) Desc() string {
+	var b strings.Builder
+	b.WriteString("{")
+	if r.Name != "" {
+		b.WriteString(r.Name + ": ")
+	}
+	for i, field := range r.Fields {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if r.Values != nil {
+			b.WriteString(fmt.Sprintf("%s = %s", field, r.Values[i].Desc()))
+		} else {
+			b.WriteString(field)
+		}
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func (r *Record) Len() int {
+	return len(r.Fields)
+}
+
+func (r *Record) index(field string) int {
+	for i, name := range r.Fields {
+		if name == field {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *Record) Get(field string) Value {
+	if i := r.index(field); i >= 0 {
+		return r.Values[i]
+	}
+	return nil
+}
+
+func (r *Record) Set(field string, value Value) bool {
+	if i := r.index(field); i >= 0 {
+		r.Values[i] = value
+		return true
+	}
+	return false
+}
+
+// Update returns a copy of the record with the fields replaced.
+func (r *Record) Update(fields []string, values []Value) *Record {
+	newValues := make([]Value, len(r.Values))
+	copy(newValues, r.Values)
+	new := NewRecord(r.Name, r.Fields, newValues)
+	for i, field := range fields {
+		new.Set(field, values[i])
+	}
+	return new
+}
+
+func (r *Record) Equal(other *Record) bool {
+	if r.Name != other.Name || r.Len() != other.Len() {
+		return false
+	}
+	for i, field := range r.Fields {
+		v := other.Get(field)
+		if v == nil || !ValueEqual(r.Values[i], v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package trompe
+
+import (
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+	"strings"
+)
+
+// Source is a source text. Sources are registered with IDs
+// to turn locations back into the text.
+type Source struct {
+	Id    int
+	Name  string
+	Text  string
+	lines []int // offsets of the beginnings of the lines
+}
+
+var sources []*Source
+
+// AddSource registers the text and returns the source with a new ID.
+// IDs start from 1.
+func AddSource(name string, text string) *Source {
+	src := &Source{Id: len(sources) + 1, Name: name, Text: text, lines: []int{0}}
+	for i, c := range text {
+		if c == '\n' {
+			src.lines = append(src.lines, i+1)
+		}
+	}
+	sources = append(sources, src)
+	return src
+}
+
+// GetSource returns the source of the ID, or nil if not registered.
+func GetSource(id int) *Source {
+	if id < 1 || id > len(sources) {
+		return nil
+	}
+	return sources[id-1]
+}
+
+// Line returns the text of the line without the line terminator.
+// Lines are numbered from 1.
+func (src *Source) Line(n int) (string, bool) {
+	if n < 1 || n > len(src.lines) {
+		return "", false
+	}
+	line := src.Text[src.lines[n-1]:]
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSuffix(line, "\r"), true
+}
+
+// SourceLine returns the line of the source where the location starts.
+func SourceLine(loc Loc) (string, bool) {
+	src := GetSource(loc.File)
+	if src == nil {
+		return "", false
+	}
+	return src.Line(loc.Start.Line)
+}
+
+// SourceStream is a character stream of a source for the lexer.
+// Tokens read from the stream have locations with the source ID.
+type SourceStream struct {
+	*antlr.InputStream
+	Source   *Source
+	Fixities map[string]Fixity // fixities of operators declared while parsing
+
+	// position of the text in the source if the text is a part of it,
+	// such as an interpolation. Line is the number of the lines before.
+	Base Pos
+}
+
+func NewSourceStream(src *Source) *SourceStream {
+	return &SourceStream{InputStream: antlr.NewInputStream(src.Text), Source: src}
+}
+
+// SourcePos returns the position in the source of the position
+// in the text of the stream.
+func (s *SourceStream) SourcePos(pos Pos) Pos {
+	if pos.Line == 1 {
+		pos.Col += s.Base.Col
+	}
+	pos.Line += s.Base.Line
+	pos.Offset += s.Base.Offset
+	return pos
+}
+
+func (s *SourceStream) GetSourceName() string {
+	return s.Source.Name
+}
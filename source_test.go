@@ -0,0 +1,22 @@
+package trompe
+
+import (
+	"testing"
+)
+
+func TestSourcePos(t *testing.T) {
+	s := &SourceStream{Base: Pos{Line: 2, Col: 4, Offset: 30}}
+	cases := []struct {
+		pos  Pos
+		want Pos
+	}{
+		{Pos{Line: 1, Col: 0, Offset: 0}, Pos{Line: 3, Col: 4, Offset: 30}},
+		{Pos{Line: 1, Col: 3, Offset: 3}, Pos{Line: 3, Col: 7, Offset: 33}},
+		{Pos{Line: 2, Col: 1, Offset: 6}, Pos{Line: 4, Col: 1, Offset: 36}},
+	}
+	for _, c := range cases {
+		if got := s.SourcePos(c.pos); got != c.want {
+			t.Errorf("%v: got %v, want %v", c.pos, got, c.want)
+		}
+	}
+}
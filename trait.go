@@ -0,0 +1,376 @@
+package trompe
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// built-in traits
+const (
+	TraitShow = "Show"
+	TraitEq   = "Eq"
+	TraitOrd  = "Ord"
+
+	// TraitNum is satisfied only by int and float. It has no methods;
+	// arithmetic opcodes dispatch on the operand values.
+	TraitNum = "Num"
+)
+
+// Method is a method of a trait. Calling a method dispatches to
+// the implementation for the type of the first argument.
+type Method struct {
+	Trait string
+	Name  string
+	arity int
+}
+
+// Impl is an implementation of a trait for a type.
+type Impl struct {
+	Trait    string
+	TypeName string
+	Names    []string         // method names
+	Methods  map[string]Value // nil if the impl is a template
+}
+
+var impls map[string]map[string]*Impl
+
+func NewMethod(trait string, name string, arity int) *Method {
+	return &Method{Trait: trait, Name: name, arity: arity}
+}
+
+func (m *Method) Type() int {
+	return ValueTypeClos
+}
+
+func (m *Method) Desc() string {
+	return fmt.Sprintf("<method %s.%s>", m.Trait, m.Name)
+}
+
+func (m *Method) Arity() int {
+	return m.arity
+}
+
+func (m *Method) Apply(ip *Interp, ctx *Context, env *Env) (Value, error) {
+	return ip.CallMethod(ctx, m.Trait, m.Name, ctx.Args[:ctx.NumArgs])
+}
+
+// NewImplTemplate returns an impl without methods. The compiler puts
+// templates in literals to register impls.
+func NewImplTemplate(trait string, typeName string, names []string) *Impl {
+	return &Impl{Trait: trait, TypeName: typeName, Names: names}
+}
+
+func (impl *Impl) Type() int {
+	return ValueTypeImpl
+}
+
+func (impl *Impl) Desc() string {
+	return fmt.Sprintf("<impl %s<%s>>", impl.Trait, impl.TypeName)
+}
+
+// MethodSym returns the name that the method of the impl is bound to.
+func MethodSym(name string, typeName string) string {
+	return name + "@" + typeName
+}
+
+func AddImpl(impl *Impl) {
+	if impls == nil {
+		impls = make(map[string]map[string]*Impl, 8)
+	}
+	if impls[impl.Trait] == nil {
+		impls[impl.Trait] = make(map[string]*Impl, 8)
+	}
+	impls[impl.Trait][impl.TypeName] = impl
+}
+
+func GetImpl(trait string, typeName string) *Impl {
+	return impls[trait][typeName]
+}
+
+// TypeNameOf returns the name of the type of the value
+// to look up implementations of traits.
+func TypeNameOf(value Value) string {
+	switch value := value.(type) {
+	case *Unit:
+		return "unit"
+	case *Bool:
+		return "bool"
+	case *Int:
+		return "int"
+	case *Float:
+		return "float"
+	case *String:
+		return "string"
+	case *List:
+		return "list"
+	case *Tuple:
+		return "tuple"
+	case *Range:
+		return "range"
+	case *Box:
+		return "box"
+	case *Record:
+		return value.Name
+	case *Variant:
+		return value.TypeName
+	default:
+		return ""
+	}
+}
+
+// Apply calls the closure with the arguments.
+// The caller validates the number of the arguments.
+// Functions are evaluated in the environments captured on creation,
+// so the module environment is only given to the other closures.
+func (ip *Interp) Apply(ctx *Context, clos Closure, args ...Value) (Value, error) {
+	newCtx := NewContext(ctx, ctx.Module, clos, args, len(args))
+	newCtx.Interp = ip
+	return clos.Apply(ip, &newCtx, NewEnv(ctx.Module.Env))
+}
+
+func (ip *Interp) CallMethod(ctx *Context, trait string, name string, args []Value) (Value, error) {
+	if len(args) == 0 {
+		return nil, NewRuntimeError(ctx, InvalidArityError,
+			fmt.Sprintf("method %s requires a receiver", name))
+	}
+	if v, ok, err := ip.callImpl(ctx, trait, name, args...); ok || err != nil {
+		return v, err
+	}
+
+	// derived implementations
+	switch trait {
+	case TraitShow:
+		s, err := ip.Show(ctx, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return NewString(s), nil
+	case TraitEq:
+		if err := ValidateArity(ctx, 2, len(args)); err != nil {
+			return nil, err
+		}
+		eq, err := ip.Equal(ctx, args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		return NewBool(eq), nil
+	case TraitOrd:
+		if err := ValidateArity(ctx, 2, len(args)); err != nil {
+			return nil, err
+		}
+		cmp, err := ip.Compare(ctx, args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		return NewInt(cmp), nil
+	}
+	return nil, NewRuntimeError(ctx, GenericError,
+		fmt.Sprintf("%s does not implement %s", args[0].Desc(), trait))
+}
+
+func (ip *Interp) callImpl(ctx *Context, trait string, name string, args ...Value) (Value, bool, error) {
+	impl := GetImpl(trait, TypeNameOf(args[0]))
+	if impl == nil {
+		return nil, false, nil
+	}
+	clos, _ := ValueToClos(impl.Methods[name])
+	v, err := ip.Apply(ctx, clos, args...)
+	return v, true, err
+}
+
+func (ip *Interp) showAll(ctx *Context, values []Value) (string, error) {
+	descs := make([]string, len(values))
+	for i, value := range values {
+		desc, err := ip.Show(ctx, value)
+		if err != nil {
+			return "", err
+		}
+		descs[i] = desc
+	}
+	return strings.Join(descs, ", "), nil
+}
+
+// Show returns the description of the value with the Show trait.
+func (ip *Interp) Show(ctx *Context, value Value) (string, error) {
+	if v, ok, err := ip.callImpl(ctx, TraitShow, "desc", value); ok || err != nil {
+		if err != nil {
+			return "", err
+		}
+		s, _ := ValueToString(v)
+		return s.Value, nil
+	}
+	var elts string
+	var err error
+	switch value := value.(type) {
+	case *Tuple:
+		if elts, err = ip.showAll(ctx, value.Values); err == nil {
+			return "(" + elts + ")", nil
+		}
+	case *List:
+		if elts, err = ip.showAll(ctx, value.Values()); err == nil {
+			return "[" + elts + "]", nil
+		}
+	case *Variant:
+		tag := variantTagDesc(value.TypeName, value.Tag)
+		if len(value.Values) == 0 {
+			return tag, nil
+		}
+		if elts, err = ip.showAll(ctx, value.Values); err == nil {
+			return tag + "(" + elts + ")", nil
+		}
+	case *Box:
+		if elts, err = ip.Show(ctx, value.Value); err == nil {
+			return "box(" + elts + ")", nil
+		}
+	case *Record:
+		fields := make([]string, len(value.Fields))
+		for i, field := range value.Fields {
+			desc, err := ip.Show(ctx, value.Values[i])
+			if err != nil {
+				return "", err
+			}
+			fields[i] = field + " = " + desc
+		}
+		return "{" + value.Name + ": " + strings.Join(fields, ", ") + "}", nil
+	default:
+		return value.Desc(), nil
+	}
+	return "", err
+}
+
+// Equal compares the values with the Eq trait.
+func (ip *Interp) Equal(ctx *Context, a Value, b Value) (bool, error) {
+	if v, ok, err := ip.callImpl(ctx, TraitEq, "eq", a, b); ok || err != nil {
+		if err != nil {
+			return false, err
+		}
+		eq, _ := ValueToBool(v)
+		return eq.Value, nil
+	}
+	var as, bs []Value
+	switch a := a.(type) {
+	case *Tuple:
+		b, _ := ValueToTuple(b)
+		as, bs = a.Values, b.Values
+	case *List:
+		b, _ := ValueToList(b)
+		as, bs = a.Values(), b.Values()
+	case *Variant:
+		b, _ := ValueToVariant(b)
+		if a.TypeName != b.TypeName || a.Tag != b.Tag {
+			return false, nil
+		}
+		as, bs = a.Values, b.Values
+	case *Record:
+		b, _ := ValueToRecord(b)
+		if a.Name != b.Name || len(a.Fields) != len(b.Fields) {
+			return false, nil
+		}
+		// compare by names of the fields regardless of the order
+		as = a.Values
+		bs = make([]Value, len(a.Fields))
+		for i, field := range a.Fields {
+			if bs[i] = b.Get(field); bs[i] == nil {
+				return false, nil
+			}
+		}
+	default:
+		return ValueEqual(a, b), nil
+	}
+	if len(as) != len(bs) {
+		return false, nil
+	}
+	for i := range as {
+		if eq, err := ip.Equal(ctx, as[i], bs[i]); err != nil || !eq {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Compare returns a negative integer, zero or a positive integer
+// if a is less than, equal to or greater than b with the Ord trait.
+func (ip *Interp) Compare(ctx *Context, a Value, b Value) (int, error) {
+	if v, ok, err := ip.callImpl(ctx, TraitOrd, "compare", a, b); ok || err != nil {
+		if err != nil {
+			return 0, err
+		}
+		cmp, _ := ValueToInt(v)
+		return cmp.Value, nil
+	}
+	var as, bs []Value
+	switch a := a.(type) {
+	case *Unit:
+		return 0, nil
+	case *Bool:
+		b, _ := ValueToBool(b)
+		return compareBool(a.Value, b.Value), nil
+	case *Int:
+		b, _ := ValueToInt(b)
+		return compareInt(a.Value, b.Value), nil
+	case *Float:
+		b, _ := ValueToFloat(b)
+		return compareFloat(a.Value, b.Value), nil
+	case *String:
+		b, _ := ValueToString(b)
+		return strings.Compare(a.Value, b.Value), nil
+	case *Tuple:
+		b, _ := ValueToTuple(b)
+		as, bs = a.Values, b.Values
+	case *List:
+		b, _ := ValueToList(b)
+		as, bs = a.Values(), b.Values()
+	default:
+		return 0, NewRuntimeError(ctx, GenericError,
+			fmt.Sprintf("%s does not implement %s", a.Desc(), TraitOrd))
+	}
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if cmp, err := ip.Compare(ctx, as[i], bs[i]); err != nil || cmp != 0 {
+			return cmp, err
+		}
+	}
+	return len(as) - len(bs), nil
+}
+
+// compareInt does not subtract the integers to avoid overflow.
+func compareInt(a int, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareFloat orders NaN before all other numbers so that sorting
+// is total. The comparison operators handle NaN separately.
+func compareFloat(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	case a == b:
+		return 0
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	default:
+		return 1
+	}
+}
+
+func compareBool(a bool, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
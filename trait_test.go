@@ -0,0 +1,119 @@
+package trompe
+
+import (
+	"math"
+	"testing"
+)
+
+func pointDecl() *StructDeclNode {
+	return structDecl("point", "x", "int", "y", "int")
+}
+
+func point(x string, y string) *RecordExpNode {
+	return record("point", field("x", in(x)), field("y", in(y)))
+}
+
+func areaTrait() *TraitDeclNode {
+	return &TraitDeclNode{Name: tok("Area"), Param: tok("a"), Methods: []MethodSigNode{{
+		Name:    tok("area"),
+		Params:  &ParamListNode{Names: []Token{tok("s")}, Types: []TypeNode{named("a")}},
+		RetType: named("int"),
+	}}}
+}
+
+func TestTraitDispatch(t *testing.T) {
+	// a user trait resolved statically
+	area := impl("Area", "point", sdef("area", []string{"p"},
+		bin(attr(vr("p"), "x"), "*", attr(vr("p"), "y"))))
+	use := vr("area")
+	info := check(t, 0, pointDecl(), areaTrait(), area, call(use, point("2", "3")))
+	if info.Impls[use] != "point" {
+		t.Errorf("impl %q", info.Impls[use])
+	}
+	eval(t, "6", pointDecl(), areaTrait(), area, call(vr("area"), point("2", "3")))
+	check(t, 1, pointDecl(), areaTrait(), area, call(vr("area"), in("1")))
+	// the impl must define the methods
+	check(t, 1, pointDecl(), areaTrait(), impl("Area", "point"))
+
+	// Eq through == calling a top-level def from a function
+	same := sdef("same", []string{"p", "q"}, bin(attr(vr("p"), "x"), "==", attr(vr("q"), "x")))
+	eq := impl("Eq", "point", sdef("eq", []string{"p", "q"}, call(vr("same"), vr("p"), vr("q"))))
+	f := sdef("f", []string{"u"}, bin(point("1", "2"), "==", point("1", "3")))
+	eval(t, "true", pointDecl(), same, eq, f, call(vr("f"), unit()))
+
+	// Show through show
+	desc := impl("Show", "point", sdef("desc", []string{"p"}, st("P!")))
+	eval(t, "()", pointDecl(), desc, call(vr("show"), point("1", "2")))
+}
+
+func TestTraitDerived(t *testing.T) {
+	// records compare by the names of the fields
+	eval(t, "true", pointDecl(), bin(point("1", "2"), "==",
+		record("", field("y", in("2")), field("x", in("1")))))
+	check(t, 1, pointDecl(), call(vr("compare"), point("1", "2"), point("1", "2")))
+	check(t, 0, call(vr("compare"), in("1"), in("2")))
+	check(t, 1, pointDecl(), call(vr("min"), point("1", "2"), point("1", "2")))
+	check(t, 1, bin(vr("show"), "==", vr("show")))
+	// polymorphic functions take the predicates
+	f := sdef("f", []string{"x"}, call(vr("compare"), vr("x"), vr("x")))
+	check(t, 0, pointDecl(), f, call(vr("f"), in("1")))
+	check(t, 1, pointDecl(), f, call(vr("f"), point("1", "2")))
+}
+
+func TestCompare(t *testing.T) {
+	Init()
+	ctx := NewContext(nil, RootModule, nil, nil, 0)
+	ip := NewInterp(RootModule)
+	ctx.Interp = ip
+	cases := []struct {
+		a, b Value
+		want int
+	}{
+		{NewInt(math.MinInt), NewInt(1), -1},
+		{NewInt(math.MaxInt), NewInt(-1), 1},
+		{NewInt(3), NewInt(3), 0},
+		{NewFloat(math.NaN()), NewFloat(0), -1},
+		{NewString("a"), NewString("b"), -1},
+		{NewListOfValues([]Value{NewInt(1)}), NewListOfValues([]Value{NewInt(1), NewInt(0)}), -1},
+	}
+	for _, c := range cases {
+		if cmp, err := ip.Compare(&ctx, c.a, c.b); err != nil || cmp != c.want {
+			t.Errorf("compare(%s, %s) = %d, %v, want %d", c.a.Desc(), c.b.Desc(), cmp, err, c.want)
+		}
+	}
+
+	l := NewListOfValues([]Value{NewInt(3), NewInt(1), NewInt(2)})
+	if v, err := LibCoreSort(&ctx, []Value{l}, 1); err != nil || v.Desc() != "[1, 2, 3]" {
+		t.Errorf("sort: %v, %v", v, err)
+	}
+}
+
+func TestTraitComponents(t *testing.T) {
+	f := anon([]string{"x"}, vr("x"))
+	// functions are not Eq, and neither are the values holding them
+	check(t, 1, bin(tupe(in("1"), f), "==", tupe(in("1"), f)))
+	check(t, 1, bin(lst(f), "==", lst(f)))
+	check(t, 1, bin(some(f), "==", some(f)))
+	handler := &StructDeclNode{Name: tok("handler"), Fields: []FieldDeclNode{
+		{Name: tok("f"), Type: &FunTypeNode{Params: []TypeNode{named("int")}, Ret: named("int")}}}}
+	h := record("handler", field("f", f))
+	check(t, 1, handler, bin(h, "==", h))
+	check(t, 0, handler, call(vr("show"), h))
+	// boxes are equal only if they are identical
+	check(t, 0, bin(call(vr("box"), f), "==", call(vr("box"), f)))
+
+	tree := &TypeDeclNode{Name: tok("tree"), Params: []Token{tok("a")}, Ctors: []CtorDeclNode{
+		{Name: tok("Leaf")},
+		{Name: tok("Node"), Types: []TypeNode{named("tree", named("a")), named("a"), named("tree", named("a"))}},
+	}}
+	node := func(x Node) Node { return call(vr("Node"), vr("Leaf"), x, vr("Leaf")) }
+	eval(t, "true", tree, bin(node(in("1")), "==", node(in("1"))))
+	check(t, 1, tree, bin(node(f), "==", node(f)))
+	check(t, 0, tree, bin(node(some(some(in("1")))), "==", node(none())))
+
+	// polymorphic functions require the traits of the elements
+	eq := sdef("eq", []string{"x", "y"}, bin(tupe(some(vr("x")), vr("y")), "==", tupe(some(vr("x")), vr("y"))))
+	check(t, 0, eq, call(vr("eq"), in("1"), st("a")))
+	check(t, 1, eq, call(vr("eq"), f, st("a")))
+	check(t, 1, eq, call(vr("eq"), in("1"), f))
+}
@@ -0,0 +1,1794 @@
+package trompe
+
+import (
+	"fmt"
+)
+
+type tyEnv struct {
+	parent *tyEnv
+	vars   map[string]*TyScheme
+}
+
+func newTyEnv(parent *tyEnv) *tyEnv {
+	return &tyEnv{parent: parent, vars: make(map[string]*TyScheme, 8)}
+}
+
+func (env *tyEnv) get(name string) *TyScheme {
+	for cur := env; cur != nil; cur = cur.parent {
+		if scm, ok := cur.vars[name]; ok {
+			return scm
+		}
+	}
+	return nil
+}
+
+func (env *tyEnv) set(name string, scm *TyScheme) {
+	env.vars[name] = scm
+}
+
+type tyStruct struct {
+	name   string
+	fields []string
+	types  []Type
+}
+
+func (st *tyStruct) ty() Type {
+	return &TyCon{Name: st.name}
+}
+
+func (st *tyStruct) fieldType(name string) Type {
+	for i, field := range st.fields {
+		if field == name {
+			return st.types[i]
+		}
+	}
+	return nil
+}
+
+type tyVariant struct {
+	name   string
+	params []*TyVar
+	ctors  []*tyCtor
+}
+
+type tyCtor struct {
+	variant *tyVariant
+	name    string
+	types   []Type // argument types
+}
+
+func (v *tyVariant) ty() Type {
+	args := make([]Type, len(v.params))
+	for i, param := range v.params {
+		args[i] = param
+	}
+	return &TyCon{Name: v.name, Args: args}
+}
+
+func (c *tyCtor) scheme() *TyScheme {
+	if len(c.types) == 0 {
+		return NewTyScheme(c.variant.params, c.variant.ty())
+	}
+	return NewTyScheme(c.variant.params, NewTyFun(c.variant.ty(), c.types...))
+}
+
+type tyTrait struct {
+	name    string
+	names   []string // method names in order
+	methods map[string]*TyScheme
+}
+
+// tyPending is a predicate to be checked.
+type tyPending struct {
+	pred *TyPred
+	loc  *Loc
+	node *VarExpNode // nullable, a reference to the method of the trait
+}
+
+type typer struct {
+	path     string
+	env      *tyEnv
+	pinEnv   *tyEnv // environment before the pattern being inferred
+	level    int
+	varId    int
+	rets     []Type // return types of enclosing functions
+	structs  map[string]*tyStruct
+	owners   map[string]*tyStruct // the latest struct that has the field
+	variants map[string]*tyVariant
+	ctors    map[string]*tyCtor
+	tyParams map[string]*TyVar // type parameters of the current declaration
+	traits   map[string]*tyTrait
+	methods  map[string]*tyTrait        // traits by method names
+	impls    map[string]map[string]bool // type names by trait names
+	formats  map[*TyScheme]bool         // primitives taking a format string
+	preds    []*tyPending
+	info     *TypeInfo
+	errs     []Diagnostic
+}
+
+func newTyper(path string) *typer {
+	t := &typer{path: path}
+	t.env = newTyEnv(nil)
+	t.structs = make(map[string]*tyStruct, 8)
+	t.owners = make(map[string]*tyStruct, 8)
+	t.variants = make(map[string]*tyVariant, 8)
+	t.ctors = make(map[string]*tyCtor, 8)
+	t.traits = make(map[string]*tyTrait, 8)
+	t.methods = make(map[string]*tyTrait, 8)
+	t.impls = make(map[string]map[string]bool, 8)
+	t.formats = make(map[*TyScheme]bool, 2)
+	t.info = &TypeInfo{
+		Types:     make(map[Node]Type, 64),
+		Impls:     make(map[*VarExpNode]string, 8),
+		Methods:   make(map[*AttrExpNode]string, 8),
+		Structs:   make(map[*RecordExpNode]string, 8),
+		ImplTypes: make(map[*ImplDeclNode]string, 8),
+		Ctors:     make(map[*CtorPtnNode]string, 8),
+	}
+	t.installCore()
+	return t
+}
+
+func (t *typer) installCore() {
+	for _, m := range OpenedModules {
+		for name, scm := range m.Sigs {
+			t.env.set(name, scm)
+			if m.Formats[name] {
+				t.formats[scm] = true
+			}
+		}
+		for trait, names := range m.Traits {
+			tr := &tyTrait{name: trait, names: names, methods: make(map[string]*TyScheme, len(names))}
+			for _, name := range names {
+				tr.methods[name] = m.Sigs[name]
+				t.methods[name] = tr
+			}
+			t.traits[trait] = tr
+		}
+	}
+
+	a := t.newGenVar()
+	opt := &tyVariant{name: OptionTypeName, params: []*TyVar{a}}
+	opt.ctors = []*tyCtor{
+		{variant: opt, name: OptionNoneTag},
+		{variant: opt, name: OptionSomeTag, types: []Type{a}},
+	}
+	t.addVariant(opt)
+
+	exn := &tyVariant{name: ExnTypeName}
+	for _, tag := range ExnTags() {
+		exn.ctors = append(exn.ctors, &tyCtor{variant: exn, name: tag, types: []Type{TyString}})
+	}
+	t.addVariant(exn)
+}
+
+func (t *typer) addVariant(v *tyVariant) {
+	t.variants[v.name] = v
+	for _, ctor := range v.ctors {
+		t.ctors[ctor.name] = ctor
+		t.env.set(ctor.name, ctor.scheme())
+	}
+}
+
+func (t *typer) error(loc *Loc, format string, args ...interface{}) {
+	t.errs = append(t.errs, NewDiagnostic(t.path, *loc, fmt.Sprintf(format, args...)))
+}
+
+func (t *typer) newVar() *TyVar {
+	t.varId++
+	return &TyVar{Id: t.varId, Level: t.level}
+}
+
+// genericLevel is the level of variables that are always generalized.
+const genericLevel = 1 << 30
+
+// newGenVar returns a variable that is always generalized.
+func (t *typer) newGenVar() *TyVar {
+	t.varId++
+	return &TyVar{Id: t.varId, Level: genericLevel}
+}
+
+func (t *typer) enterScope() {
+	t.env = newTyEnv(t.env)
+}
+
+func (t *typer) leaveScope() {
+	t.env = t.env.parent
+}
+
+func (t *typer) bindMono(name string, ty Type) {
+	t.env.set(name, NewTyScheme(nil, ty))
+}
+
+func (t *typer) occurs(tv *TyVar, ty Type) bool {
+	switch ty := PruneType(ty).(type) {
+	case *TyVar:
+		if ty == tv {
+			return true
+		}
+		if ty.Level > tv.Level {
+			ty.Level = tv.Level
+		}
+		return false
+	case *TyCon:
+		for _, arg := range ty.Args {
+			if t.occurs(tv, arg) {
+				return true
+			}
+		}
+		return false
+	case *TyTuple:
+		for _, elt := range ty.Elts {
+			if t.occurs(tv, elt) {
+				return true
+			}
+		}
+		return false
+	case *TyFun:
+		for _, param := range ty.Params {
+			if t.occurs(tv, param) {
+				return true
+			}
+		}
+		return t.occurs(tv, ty.Ret)
+	default:
+		return false
+	}
+}
+
+func (t *typer) unifyList(tys1 []Type, tys2 []Type) bool {
+	if len(tys1) != len(tys2) {
+		return false
+	}
+	for i := range tys1 {
+		if !t.unify(tys1[i], tys2[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *typer) unify(ty1 Type, ty2 Type) bool {
+	ty1 = PruneType(ty1)
+	ty2 = PruneType(ty2)
+	if tv1, ok := ty1.(*TyVar); ok {
+		if tv2, ok := ty2.(*TyVar); ok && tv1 == tv2 {
+			return true
+		}
+		if t.occurs(tv1, ty2) {
+			return false
+		}
+		tv1.Inst = ty2
+		return true
+	}
+	if _, ok := ty2.(*TyVar); ok {
+		return t.unify(ty2, ty1)
+	}
+	switch ty1 := ty1.(type) {
+	case *TyCon:
+		if ty2, ok := ty2.(*TyCon); ok {
+			return ty1.Name == ty2.Name && t.unifyList(ty1.Args, ty2.Args)
+		}
+	case *TyTuple:
+		if ty2, ok := ty2.(*TyTuple); ok {
+			return t.unifyList(ty1.Elts, ty2.Elts)
+		}
+	case *TyFun:
+		if ty2, ok := ty2.(*TyFun); ok {
+			return t.unifyList(ty1.Params, ty2.Params) &&
+				t.unify(ty1.Ret, ty2.Ret)
+		}
+	}
+	return false
+}
+
+// expect unifies the actual type of a node with the expected type.
+func (t *typer) expect(node Node, expected Type, actual Type) {
+	t.expectLoc(node.Loc(), expected, actual)
+}
+
+func (t *typer) expectLoc(loc *Loc, expected Type, actual Type) {
+	// describe both types before unification modifies them
+	p := newTyPrinter()
+	expDesc := p.desc(expected)
+	actDesc := p.desc(actual)
+	if !t.unify(expected, actual) {
+		t.error(loc, "type mismatch: expected %s, but found %s",
+			expDesc, actDesc)
+	}
+}
+
+func (t *typer) generalize(ty Type) *TyScheme {
+	return t.generalizeGroup([]Type{ty})[0]
+}
+
+// generalizeGroup generalizes the types of mutually recursive definitions
+// together. A predicate on the shared variables goes to every scheme.
+func (t *typer) generalizeGroup(tys []Type) []*TyScheme {
+	var vars []*TyVar
+	var collect func(Type)
+	collect = func(ty Type) {
+		switch ty := PruneType(ty).(type) {
+		case *TyVar:
+			if ty.Level > t.level && !t.hasVars(ty, vars) {
+				vars = append(vars, ty)
+			}
+		case *TyCon:
+			for _, arg := range ty.Args {
+				collect(arg)
+			}
+		case *TyTuple:
+			for _, elt := range ty.Elts {
+				collect(elt)
+			}
+		case *TyFun:
+			for _, param := range ty.Params {
+				collect(param)
+			}
+			collect(ty.Ret)
+		}
+	}
+	scms := make([]*TyScheme, len(tys))
+	var all []*TyVar
+	for i, ty := range tys {
+		vars = nil
+		collect(ty)
+		scms[i] = NewTyScheme(vars, ty)
+		for _, tv := range vars {
+			if !t.hasVars(tv, all) {
+				all = append(all, tv)
+			}
+		}
+	}
+
+	// move predicates on the generalized variables to the schemes
+	var rest []*tyPending
+	for _, p := range t.preds {
+		if !t.hasVars(p.pred.Type, all) {
+			rest = append(rest, p)
+			continue
+		}
+		preds, ok := t.reducePred(p.pred)
+		if !ok {
+			t.predError(p)
+			continue
+		}
+		for _, pred := range preds {
+			if tv, ok := PruneType(pred.Type).(*TyVar); ok && t.hasVars(tv, all) {
+				for _, scm := range scms {
+					if t.hasVars(tv, scm.Vars) {
+						scm.Preds = appendPred(scm.Preds, pred)
+					}
+				}
+			} else {
+				rest = append(rest, &tyPending{pred: pred, loc: p.loc})
+			}
+		}
+	}
+	t.preds = rest
+	return scms
+}
+
+func appendPred(preds []*TyPred, pred *TyPred) []*TyPred {
+	for _, pred1 := range preds {
+		if pred1.Trait == pred.Trait && PruneType(pred1.Type) == PruneType(pred.Type) {
+			return preds
+		}
+	}
+	return append(preds, pred)
+}
+
+// hasVars reports whether the type contains any of the variables.
+func (t *typer) hasVars(ty Type, vars []*TyVar) bool {
+	switch ty := PruneType(ty).(type) {
+	case *TyVar:
+		for _, tv := range vars {
+			if tv == ty {
+				return true
+			}
+		}
+	case *TyCon:
+		for _, arg := range ty.Args {
+			if t.hasVars(arg, vars) {
+				return true
+			}
+		}
+	case *TyTuple:
+		for _, elt := range ty.Elts {
+			if t.hasVars(elt, vars) {
+				return true
+			}
+		}
+	case *TyFun:
+		for _, param := range ty.Params {
+			if t.hasVars(param, vars) {
+				return true
+			}
+		}
+		return t.hasVars(ty.Ret, vars)
+	}
+	return false
+}
+
+func (t *typer) instantiate(scm *TyScheme) Type {
+	ty, _ := t.instantiatePreds(scm)
+	return ty
+}
+
+// instantiatePreds instantiates the scheme and the predicates.
+func (t *typer) instantiatePreds(scm *TyScheme) (Type, []*TyPred) {
+	if len(scm.Vars) == 0 {
+		return scm.Type, scm.Preds
+	}
+	subst := make(map[*TyVar]Type, len(scm.Vars))
+	for _, tv := range scm.Vars {
+		subst[tv] = t.newVar()
+	}
+	preds := make([]*TyPred, len(scm.Preds))
+	for i, pred := range scm.Preds {
+		preds[i] = &TyPred{Trait: pred.Trait, Type: substType(pred.Type, subst)}
+	}
+	return substType(scm.Type, subst), preds
+}
+
+// substType returns a copy of the type with the variables replaced.
+func substType(ty Type, subst map[*TyVar]Type) Type {
+	switch ty := PruneType(ty).(type) {
+	case *TyVar:
+		if new, ok := subst[ty]; ok {
+			return new
+		}
+		return ty
+	case *TyCon:
+		if len(ty.Args) == 0 {
+			return ty
+		}
+		args := make([]Type, len(ty.Args))
+		for i, arg := range ty.Args {
+			args[i] = substType(arg, subst)
+		}
+		return &TyCon{Name: ty.Name, Args: args}
+	case *TyTuple:
+		elts := make([]Type, len(ty.Elts))
+		for i, elt := range ty.Elts {
+			elts[i] = substType(elt, subst)
+		}
+		return &TyTuple{Elts: elts}
+	case *TyFun:
+		params := make([]Type, len(ty.Params))
+		for i, param := range ty.Params {
+			params[i] = substType(param, subst)
+		}
+		return &TyFun{Params: params, Ret: substType(ty.Ret, subst)}
+	default:
+		return ty
+	}
+}
+
+// require adds the predicates to be checked.
+func (t *typer) require(preds []*TyPred, loc *Loc, node *VarExpNode) {
+	for _, pred := range preds {
+		t.preds = append(t.preds, &tyPending{pred: pred, loc: loc, node: node})
+	}
+}
+
+// reducePred reduces the predicate to predicates on type variables.
+// It returns false if the type does not implement the trait.
+func (t *typer) reducePred(pred *TyPred) ([]*TyPred, bool) {
+	return t.reducePredIn(pred, nil)
+}
+
+// reducePredIn reduces the predicate. Outer has the types being reduced
+// to stop at recursive types.
+func (t *typer) reducePredIn(pred *TyPred, outer []*TyCon) ([]*TyPred, bool) {
+	var elts []Type
+	switch ty := PruneType(pred.Type).(type) {
+	case *TyVar:
+		return []*TyPred{pred}, true
+	case *TyCon:
+		if t.impls[pred.Trait][ty.Name] {
+			return nil, true
+		}
+		depth := 0
+		for _, con := range outer {
+			if sameType(con, ty) {
+				return nil, true
+			} else if con.Name == ty.Name {
+				depth++
+			}
+		}
+		if depth >= maxNestedTypes {
+			return nil, true
+		}
+		outer = append(outer, ty)
+		switch pred.Trait {
+		case TraitShow:
+			elts = t.componentTypes(ty)
+		case TraitEq:
+			if ty.Name == "box" {
+				// boxes are equal only if they are identical
+				return nil, true
+			}
+			elts = t.componentTypes(ty)
+		case TraitOrd:
+			switch ty.Name {
+			case TyUnit.Name, TyBool.Name, TyInt.Name, TyFloat.Name, TyString.Name:
+				return nil, true
+			case "list":
+				elts = ty.Args
+			default:
+				return nil, false
+			}
+		case TraitNum:
+			return nil, ty.Name == TyInt.Name || ty.Name == TyFloat.Name
+		default:
+			return nil, false
+		}
+	case *TyTuple:
+		switch pred.Trait {
+		case TraitShow, TraitEq, TraitOrd:
+			elts = ty.Elts
+		default:
+			return nil, false
+		}
+	case *TyFun:
+		return nil, pred.Trait == TraitShow
+	}
+
+	var preds []*TyPred
+	for _, elt := range elts {
+		reduced, ok := t.reducePredIn(&TyPred{Trait: pred.Trait, Type: elt}, outer)
+		if !ok {
+			return nil, false
+		}
+		preds = append(preds, reduced...)
+	}
+	return preds, true
+}
+
+// maxNestedTypes is the limit of the nested types of the same name
+// checked by reducePred, for types nested in themselves with
+// different arguments.
+const maxNestedTypes = 8
+
+// sameType reports whether the types are identical.
+func sameType(a Type, b Type) bool {
+	switch a := PruneType(a).(type) {
+	case *TyVar:
+		return a == PruneType(b)
+	case *TyCon:
+		b, ok := PruneType(b).(*TyCon)
+		return ok && a.Name == b.Name && sameTypes(a.Args, b.Args)
+	case *TyTuple:
+		b, ok := PruneType(b).(*TyTuple)
+		return ok && sameTypes(a.Elts, b.Elts)
+	case *TyFun:
+		b, ok := PruneType(b).(*TyFun)
+		return ok && sameTypes(a.Params, b.Params) && sameType(a.Ret, b.Ret)
+	}
+	return false
+}
+
+func sameTypes(as []Type, bs []Type) bool {
+	if len(as) != len(bs) {
+		return false
+	}
+	for i := range as {
+		if !sameType(as[i], bs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// componentTypes returns the types of the values held by the value
+// of the type: the fields of structs, the arguments of the constructors
+// of variants, and the type arguments of the other types.
+func (t *typer) componentTypes(ty *TyCon) []Type {
+	if st := t.structs[ty.Name]; st != nil {
+		return st.types
+	}
+	if v := t.variants[ty.Name]; v != nil {
+		subst := make(map[*TyVar]Type, len(v.params))
+		for i, param := range v.params {
+			if i < len(ty.Args) {
+				subst[param] = ty.Args[i]
+			}
+		}
+		var tys []Type
+		for _, ctor := range v.ctors {
+			for _, arg := range ctor.types {
+				tys = append(tys, substType(arg, subst))
+			}
+		}
+		return tys
+	}
+	return ty.Args
+}
+
+func (t *typer) predError(p *tyPending) {
+	t.error(p.loc, "type %s does not implement %s", p.pred.Type.Desc(), p.pred.Trait)
+}
+
+// checkPreds checks the rest of predicates and resolves the methods
+// called with the types that have the impls. The predicates on
+// type variables are left to dispatch at run time.
+func (t *typer) checkPreds() {
+	for _, p := range t.preds {
+		if _, ok := t.reducePred(p.pred); !ok {
+			t.predError(p)
+		} else if con, ok := PruneType(p.pred.Type).(*TyCon); ok &&
+			p.node != nil && t.impls[p.pred.Trait][con.Name] {
+			t.info.Impls[p.node] = con.Name
+		}
+	}
+	t.preds = nil
+}
+
+// isSyntacticValue reports whether a let-bound expression can be generalized.
+func isSyntacticValue(node ExpNode) bool {
+	switch node := node.(type) {
+	case *AnonFunExpNode, *VarExpNode, *UnitExpNode, *BoolExpNode,
+		*IntExpNode, *StrExpNode, *NoneExpNode:
+		return true
+	case *ParenExpNode:
+		return isSyntacticValue(node.Exp)
+	default:
+		return false
+	}
+}
+
+// diverges reports whether the block always leaves with return or raise.
+func diverges(block *BlockNode) bool {
+	if block == nil || len(block.Stats) == 0 {
+		return false
+	}
+	switch stat := block.Stats[len(block.Stats)-1].(type) {
+	case *RetStatNode, *RaiseExpNode:
+		return true
+	case *IfStatNode:
+		if stat.ElseAction == nil {
+			return false
+		}
+		for i := range stat.Cond {
+			if !diverges(&stat.Cond[i].Action) {
+				return false
+			}
+		}
+		return diverges(stat.ElseAction)
+	case *CaseStatNode:
+		for _, clau := range stat.Claus {
+			if !diverges(clau.Action) {
+				return false
+			}
+		}
+		return stat.ElseAction == nil || diverges(stat.ElseAction)
+	default:
+		return false
+	}
+}
+
+func (t *typer) inferBlock(block *BlockNode) Type {
+	t.enterScope()
+	defer t.leaveScope()
+	var ty Type = TyUnit
+	for i := 0; i < len(block.Stats); i++ {
+		// consecutive definitions may refer to each other
+		j := i
+		for j < len(block.Stats) && isDef(block.Stats[j]) {
+			j++
+		}
+		if j > i {
+			t.inferDefs(block.Stats[i:j])
+			for _, def := range block.Stats[i:j] {
+				t.info.Types[def] = TyUnit
+			}
+			ty = TyUnit
+			i = j - 1
+			continue
+		}
+		ty = t.infer(block.Stats[i])
+	}
+	return ty
+}
+
+func isDef(node Node) bool {
+	switch node.(type) {
+	case *DefStatNode, *ShortDefStatNode:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *typer) inferParams(params *ParamListNode) []Type {
+	if params == nil {
+		return []Type{}
+	}
+	tys := make([]Type, len(params.Names))
+	for i, name := range params.Names {
+		if tyNode := params.TypeAt(i); tyNode != nil {
+			tys[i] = t.resolveType(tyNode)
+		} else {
+			tys[i] = t.newVar()
+		}
+		t.bindMono(name.Text, tys[i])
+	}
+	return tys
+}
+
+// inferDefs infers a group of mutually recursive definitions.
+// The names are bound monomorphically while the bodies are inferred,
+// and the group is generalized together.
+func (t *typer) inferDefs(defs []Node) {
+	t.level++
+	names := make([]Token, len(defs))
+	selfs := make([]Type, len(defs))
+	for i, def := range defs {
+		switch def := def.(type) {
+		case *DefStatNode:
+			names[i] = def.Name
+		case *ShortDefStatNode:
+			names[i] = def.Name
+		}
+		selfs[i] = t.newVar()
+		t.bindMono(names[i].Text, selfs[i])
+	}
+	for i, def := range defs {
+		switch def := def.(type) {
+		case *DefStatNode:
+			t.inferFun(selfs[i], def.Params, def.RetType, &def.Block)
+		case *ShortDefStatNode:
+			t.inferFun(selfs[i], def.Params, def.RetType, def.Exp)
+		}
+	}
+	t.level--
+	for i, scm := range t.generalizeGroup(selfs) {
+		t.env.set(names[i].Text, scm)
+	}
+}
+
+func (t *typer) inferFun(self Type,
+	params *ParamListNode,
+	retType TypeNode,
+	body Node) {
+	var ret Type
+	if retType != nil {
+		ret = t.resolveType(retType)
+	} else {
+		ret = t.newVar()
+	}
+	t.enterScope()
+	paramTys := t.inferParams(params)
+	t.rets = append(t.rets, ret)
+	t.expect(body, ret, t.infer(body))
+	t.rets = t.rets[:len(t.rets)-1]
+	t.leaveScope()
+	t.expect(body, self, NewTyFun(ret, paramTys...))
+}
+
+// infer returns the type of the node and records it.
+func (t *typer) infer(node Node) Type {
+	ty := t.inferNode(node)
+	t.info.Types[node] = ty
+	return ty
+}
+
+func (t *typer) inferNode(node Node) Type {
+	switch node := node.(type) {
+	case *ChunkNode:
+		if node.Block == nil {
+			return TyUnit
+		}
+		return t.infer(node.Block)
+	case *BlockNode:
+		return t.inferBlock(node)
+	case *LetStatNode:
+		if ptn, ok := node.Ptn.(*VarPtnNode); ok && node.ElseAction == nil &&
+			isSyntacticValue(node.Exp) {
+			t.level++
+			ty := t.infer(node.Exp)
+			if node.Type != nil {
+				t.expect(node.Exp, t.resolveType(node.Type), ty)
+			}
+			t.level--
+			t.env.set(ptn.Name.Text, t.generalize(ty))
+		} else {
+			ty := t.infer(node.Exp)
+			if node.Type != nil {
+				t.expect(node.Exp, t.resolveType(node.Type), ty)
+			}
+			if node.ElseAction != nil {
+				// the else block does not see the bindings of the pattern
+				t.infer(node.ElseAction)
+				if !diverges(node.ElseAction) {
+					loc := node.Ptn.Loc()
+					if node.Else != nil {
+						loc = node.Else
+					}
+					t.error(loc, "else block of let must end with return or raise")
+				}
+			}
+			t.expect(node.Ptn, t.inferBinding(node.Ptn), ty)
+			t.checkLet(node, ty)
+		}
+		return TyUnit
+	case *DefStatNode, *ShortDefStatNode:
+		t.inferDefs([]Node{node})
+		return TyUnit
+	case *IfStatNode:
+		var ty Type
+		if node.ElseAction != nil {
+			ty = t.newVar()
+		} else {
+			ty = TyUnit
+		}
+		for _, cond := range node.Cond {
+			t.expect(cond.Cond, TyBool, t.infer(cond.Cond))
+			actTy := t.infer(&cond.Action)
+			if node.ElseAction != nil {
+				t.expect(&cond.Action, ty, actTy)
+			}
+		}
+		if node.ElseAction != nil {
+			t.expect(node.ElseAction, ty, t.infer(node.ElseAction))
+		}
+		return ty
+	case *CaseStatNode:
+		nerrs := len(t.errs)
+		condTy := t.infer(node.Cond)
+		ty := t.newVar()
+		for _, clau := range node.Claus {
+			t.enterScope()
+			t.expect(clau.Ptn, condTy, t.inferBinding(clau.Ptn))
+			if clau.Guard != nil {
+				t.expect(clau.Guard, TyBool, t.infer(clau.Guard))
+			}
+			t.expect(clau.Action, ty, t.infer(clau.Action))
+			t.leaveScope()
+		}
+		if node.ElseAction != nil {
+			t.expect(node.ElseAction, ty, t.infer(node.ElseAction))
+		}
+		if len(t.errs) == nerrs {
+			t.checkCase(node, condTy)
+		}
+		return ty
+	case *TryStatNode:
+		ty := t.infer(node.Block)
+		exnTy := t.variants[ExnTypeName].ty()
+		for _, clau := range node.Claus {
+			t.enterScope()
+			t.expect(clau.Ptn, exnTy, t.inferBinding(clau.Ptn))
+			t.expect(clau.Action, ty, t.infer(clau.Action))
+			t.leaveScope()
+		}
+		if node.EnsureAction != nil {
+			t.infer(node.EnsureAction)
+		}
+		return ty
+	case *RaiseExpNode:
+		t.expect(node.Exp, t.variants[ExnTypeName].ty(), t.infer(node.Exp))
+		return t.newVar()
+	case *ForStatNode:
+		var eltTy Type
+		expTy := t.infer(node.Exp)
+		if con, ok := PruneType(expTy).(*TyCon); ok && con.Name == "list" {
+			eltTy = con.Args[0]
+		} else {
+			t.expect(node.Exp, TyRange, expTy)
+			eltTy = TyInt
+		}
+		t.enterScope()
+		t.expect(node.Ptn, eltTy, t.inferBinding(node.Ptn))
+		t.infer(&node.Block)
+		t.leaveScope()
+		return TyUnit
+	case *RetStatNode:
+		var ty Type = TyUnit
+		if node.Exp != nil {
+			ty = t.infer(node.Exp)
+		}
+		if len(t.rets) > 0 {
+			t.expect(node, t.rets[len(t.rets)-1], ty)
+		}
+		return t.newVar()
+	case *ParenExpNode:
+		return t.infer(node.Exp)
+	case *FunCallExpNode:
+		if v, ok := node.Callable.(*VarExpNode); ok && t.formats[t.env.get(v.Name.Text)] {
+			return t.inferFormat(node)
+		}
+		var funTy Type
+		if attr, ok := node.Callable.(*AttrExpNode); ok {
+			funTy = t.inferAttr(attr, true)
+		} else {
+			funTy = t.infer(node.Callable)
+		}
+		argTys := make([]Type, len(node.Args.Elts))
+		var holeTys []Type
+		for i, arg := range node.Args.Elts {
+			if IsPlaceholder(arg) {
+				argTys[i] = t.newVar()
+				holeTys = append(holeTys, argTys[i])
+			} else {
+				argTys[i] = t.infer(arg)
+			}
+		}
+		ret := t.newVar()
+		t.expect(node, funTy, NewTyFun(ret, argTys...))
+		if holeTys != nil {
+			return NewTyFun(ret, holeTys...)
+		}
+		return ret
+	case *CondOpExpNode:
+		t.expect(node.Cond, TyBool, t.infer(node.Cond))
+		ty := t.infer(node.True)
+		t.expect(node.False, ty, t.infer(node.False))
+		return ty
+	case *VarExpNode:
+		if node.Name.Text == "_" {
+			t.error(node.Loc(), "placeholder _ is allowed only as an argument of function calls")
+			return t.newVar()
+		}
+		if scm := t.env.get(node.Name.Text); scm != nil {
+			ty, preds := t.instantiatePreds(scm)
+			if tr := t.methods[node.Name.Text]; tr != nil && tr.methods[node.Name.Text] == scm {
+				t.require(preds, node.Loc(), node)
+			} else {
+				t.require(preds, node.Loc(), nil)
+			}
+			return ty
+		}
+		t.error(node.Loc(), "unbound variable %s", node.Name.Text)
+		return t.newVar()
+	case *UnitExpNode:
+		return TyUnit
+	case *BoolExpNode:
+		return TyBool
+	case *IntExpNode:
+		return TyInt
+	case *FloatExpNode:
+		return TyFloat
+	case *StrExpNode:
+		return TyString
+	case *InterpExpNode:
+		for _, part := range node.Parts {
+			ty := t.infer(part)
+			t.require([]*TyPred{{Trait: TraitShow, Type: ty}}, part.Loc(), nil)
+		}
+		return TyString
+	case *ListExpNode:
+		eltTy := t.newVar()
+		for _, elt := range node.Elts.Elts {
+			t.expect(elt, eltTy, t.infer(elt))
+		}
+		return NewTyList(eltTy)
+	case *TupleExpNode:
+		eltTys := make([]Type, len(node.Elts.Elts))
+		for i, elt := range node.Elts.Elts {
+			eltTys[i] = t.infer(elt)
+		}
+		return &TyTuple{Elts: eltTys}
+	case *SomeExpNode:
+		return NewTyOption(t.infer(node.Value))
+	case *NoneExpNode:
+		return NewTyOption(t.newVar())
+	case *AnonFunExpNode:
+		ret := t.newVar()
+		t.enterScope()
+		paramTys := t.inferParams(node.Params)
+		t.rets = append(t.rets, ret)
+		for _, stat := range node.Stats {
+			t.infer(stat)
+		}
+		t.expect(node.Exp, ret, t.infer(node.Exp))
+		t.rets = t.rets[:len(t.rets)-1]
+		t.leaveScope()
+		return NewTyFun(ret, paramTys...)
+	case *StructDeclNode:
+		if t.isTypeName(node.Name.Text) {
+			t.error(&node.Name.Loc, "type %s is already defined", node.Name.Text)
+			return TyUnit
+		}
+		st := &tyStruct{name: node.Name.Text}
+		t.structs[st.name] = st
+		for _, field := range node.Fields {
+			if st.fieldType(field.Name.Text) != nil {
+				t.error(&field.Name.Loc, "duplicate field %s", field.Name.Text)
+				continue
+			}
+			st.fields = append(st.fields, field.Name.Text)
+			st.types = append(st.types, t.resolveType(field.Type))
+			t.owners[field.Name.Text] = st
+		}
+		return TyUnit
+	case *FixityDeclNode:
+		return TyUnit
+	case *TypeDeclNode:
+		if node.Name.Text == ExnTypeName {
+			t.error(&node.Name.Loc, "type %s is closed and cannot be redeclared", ExnTypeName)
+			return TyUnit
+		} else if t.isTypeName(node.Name.Text) {
+			t.error(&node.Name.Loc, "type %s is already defined", node.Name.Text)
+			return TyUnit
+		}
+		v := &tyVariant{name: node.Name.Text}
+		params := make(map[string]*TyVar, len(node.Params))
+		for _, param := range node.Params {
+			tv := t.newGenVar()
+			v.params = append(v.params, tv)
+			params[param.Text] = tv
+		}
+		// register the type first for recursive types
+		t.variants[v.name] = v
+		saved := t.tyParams
+		t.tyParams = params
+		for _, decl := range node.Ctors {
+			name := decl.Name.Text
+			if !IsCtorName(name) {
+				t.error(&decl.Name.Loc, "constructor %s must be capitalized", name)
+			}
+			for _, ctor := range v.ctors {
+				if ctor.name == name {
+					t.error(&decl.Name.Loc, "duplicate constructor %s", name)
+				}
+			}
+			if ctor := t.ctors[name]; ctor != nil {
+				t.error(&decl.Name.Loc, "constructor %s is already defined by type %s",
+					name, ctor.variant.name)
+				continue
+			}
+			ctor := &tyCtor{variant: v, name: name}
+			for _, tyNode := range decl.Types {
+				ctor.types = append(ctor.types, t.resolveType(tyNode))
+			}
+			v.ctors = append(v.ctors, ctor)
+		}
+		t.tyParams = saved
+		t.addVariant(v)
+		return TyUnit
+	case *AssignStatNode:
+		targetTy := t.infer(node.Target)
+		if attr, ok := node.Target.(*AttrExpNode); ok && !IsTyBox(targetTy) {
+			// "r.field <- v" updates the field of the record
+			if v, ok := attr.Exp.(*VarExpNode); ok && t.isModuleName(v.Name.Text) {
+				t.error(attr.Loc(), "cannot assign to attribute %s of module %s",
+					attr.Name.Text, v.Name.Text)
+				return TyUnit
+			}
+			t.expect(node.Exp, targetTy, t.infer(node.Exp))
+			return TyUnit
+		}
+		ty := t.newVar()
+		t.expect(node.Target, NewTyBox(ty), targetTy)
+		t.expect(node.Exp, ty, t.infer(node.Exp))
+		return TyUnit
+	case *TraitDeclNode:
+		t.inferTrait(node)
+		return TyUnit
+	case *ImplDeclNode:
+		t.inferImpl(node)
+		return TyUnit
+	case *RecordExpNode:
+		var st *tyStruct
+		if node.Name != nil {
+			if st = t.structs[node.Name.Text]; st == nil {
+				t.error(&node.Name.Loc, "unknown struct %s", node.Name.Text)
+			}
+		} else if len(node.Fields) > 0 {
+			if st = t.owners[node.Fields[0].Name.Text]; st == nil {
+				t.error(node.Loc(), "unknown field %s", node.Fields[0].Name.Text)
+			}
+		} else {
+			t.error(node.Loc(), "cannot infer struct of empty record")
+		}
+		if st == nil {
+			t.inferFields(nil, node.Fields)
+			return t.newVar()
+		}
+		t.info.Structs[node] = st.name
+		t.inferFields(st, node.Fields)
+		for _, name := range st.fields {
+			found := false
+			for _, field := range node.Fields {
+				if field.Name.Text == name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.error(node.Loc(), "field %s of struct %s is not initialized",
+					name, st.name)
+			}
+		}
+		return st.ty()
+	case *UpdateExpNode:
+		ty := t.infer(node.Exp)
+		var st *tyStruct
+		if con, ok := PruneType(ty).(*TyCon); ok {
+			st = t.structs[con.Name]
+		} else if len(node.Fields) > 0 {
+			st = t.owners[node.Fields[0].Name.Text]
+		}
+		if st == nil {
+			t.error(node.Exp.Loc(), "record required, but found %s", ty.Desc())
+			t.inferFields(nil, node.Fields)
+			return t.newVar()
+		}
+		t.expect(node.Exp, st.ty(), ty)
+		t.inferFields(st, node.Fields)
+		return st.ty()
+	case *AttrExpNode:
+		return t.inferAttr(node, false)
+	case *IndexExpNode:
+		return t.inferIndex(node)
+	case *RangeExpNode:
+		t.expect(node.Left, TyInt, t.infer(node.Left))
+		t.expect(node.Right, TyInt, t.infer(node.Right))
+		return TyRange
+	case *BinaryExpNode:
+		return t.inferBinary(node)
+	case *UnaryExpNode:
+		ty := t.infer(node.Exp)
+		switch node.Op.Text {
+		case "not":
+			t.expect(node.Exp, TyBool, ty)
+			return TyBool
+		case "-":
+			t.require([]*TyPred{{Trait: TraitNum, Type: ty}}, &node.Op.Loc, nil)
+			return ty
+		default:
+			t.expect(node.Exp, TyInt, ty)
+			return TyInt
+		}
+	default:
+		t.error(node.Loc(), "unsupported node %s", NodeDesc(node))
+		return t.newVar()
+	}
+}
+
+func (t *typer) inferBinary(node *BinaryExpNode) Type {
+	if node.Fun != nil {
+		fun := t.infer(node.Fun)
+		left := t.infer(node.Left)
+		right := t.infer(node.Right)
+		ret := t.newVar()
+		t.expect(node, fun, NewTyFun(ret, left, right))
+		return ret
+	}
+	left := t.infer(node.Left)
+	right := t.infer(node.Right)
+	switch node.Op.Text {
+	case "and", "or":
+		t.expect(node.Left, TyBool, left)
+		t.expect(node.Right, TyBool, right)
+		return TyBool
+	case "==", "!=":
+		t.expect(node.Right, left, right)
+		t.require([]*TyPred{{Trait: TraitEq, Type: left}}, &node.Op.Loc, nil)
+		return TyBool
+	case "<", "<=", ">", ">=":
+		t.expect(node.Right, left, right)
+		t.require([]*TyPred{{Trait: TraitOrd, Type: left}}, &node.Op.Loc, nil)
+		return TyBool
+	case "::":
+		ty := NewTyList(left)
+		t.expect(node.Right, ty, right)
+		return ty
+	case "|>":
+		// "x |> f" is "f(x)"
+		ret := t.newVar()
+		t.expect(node.Right, NewTyFun(ret, left), right)
+		return ret
+	case "+", "-", "*", "/", "//", "%":
+		// no implicit conversion: both operands are ints or both are floats
+		t.expect(node.Right, left, right)
+		t.require([]*TyPred{{Trait: TraitNum, Type: left}}, &node.Op.Loc, nil)
+		return left
+	default:
+		t.expect(node.Left, TyInt, left)
+		t.expect(node.Right, TyInt, right)
+		return TyInt
+	}
+}
+
+// inferAttr returns the type of the attribute of a module or a field
+// of a record. If the attribute is called, it may be a method of
+// the type of the value.
+func (t *typer) inferAttr(node *AttrExpNode, call bool) Type {
+	if v, ok := node.Exp.(*VarExpNode); ok && t.isModuleName(v.Name.Text) {
+		m := GetModule(v.Name.Text)
+		if scm := m.GetSig(node.Name.Text); scm != nil {
+			ty, preds := t.instantiatePreds(scm)
+			t.require(preds, node.Loc(), nil)
+			return ty
+		}
+		if m.GetAttr(node.Name.Text) == nil {
+			t.error(node.Loc(), "module %s has no attribute %s", v.Name.Text, node.Name.Text)
+		}
+		return t.newVar()
+	}
+	ty := t.infer(node.Exp)
+	var st *tyStruct
+	if con, ok := PruneType(ty).(*TyCon); ok {
+		st = t.structs[con.Name]
+	} else if _, ok := PruneType(ty).(*TyVar); ok {
+		st = t.owners[node.Name.Text]
+	}
+	if call && (st == nil || st.fieldType(node.Name.Text) == nil) {
+		return t.inferMethod(node, ty)
+	}
+	if st == nil {
+		t.error(node.Exp.Loc(), "record required, but found %s", ty.Desc())
+		return t.newVar()
+	}
+	t.expect(node.Exp, st.ty(), ty)
+	if fieldTy := st.fieldType(node.Name.Text); fieldTy != nil {
+		return fieldTy
+	}
+	t.error(node.Loc(), "struct %s has no field %s", st.name, node.Name.Text)
+	return t.newVar()
+}
+
+// inferFormat checks the arguments of a call of a format primitive
+// against the directives of the format string literal.
+func (t *typer) inferFormat(node *FunCallExpNode) Type {
+	t.infer(node.Callable)
+	args := node.Args.Elts
+	for _, arg := range args {
+		if IsPlaceholder(arg) {
+			t.error(arg.Loc(), "%s cannot be partially applied", NodeDesc(node.Callable))
+			return TyUnit
+		}
+	}
+	if len(args) == 0 {
+		t.error(node.Callable.Loc(), "format string required")
+		return TyUnit
+	}
+	t.expect(args[0], TyString, t.infer(args[0]))
+	str, ok := args[0].(*StrExpNode)
+	if !ok {
+		t.error(args[0].Loc(), "format must be a string literal")
+		return TyUnit
+	}
+	verbs, err := FormatVerbs(str.Value.Text)
+	if err != nil {
+		t.error(args[0].Loc(), "%s", err.Error())
+		return TyUnit
+	}
+	args = args[1:]
+	if len(args) != len(verbs) {
+		t.error(node.Callable.Loc(), "format requires %d arguments, but %d given",
+			len(verbs), len(args))
+		return TyUnit
+	}
+	for i, arg := range args {
+		ty := t.infer(arg)
+		switch verbs[i] {
+		case 'd', 'x':
+			t.expect(arg, TyInt, ty)
+		case 'f':
+			t.expect(arg, TyFloat, ty)
+		case 's':
+			t.require([]*TyPred{{Trait: TraitShow, Type: ty}}, arg.Loc(), nil)
+		}
+	}
+	return TyUnit
+}
+
+// inferMethod returns the type of the method called as "value.method(args)"
+// without the first parameter. The method is a function of the module
+// of the type of the value, such as String.length for strings.
+func (t *typer) inferMethod(node *AttrExpNode, recvTy Type) Type {
+	var scm *TyScheme
+	var modName string
+	switch ty := PruneType(recvTy).(type) {
+	case *TyVar:
+		t.error(node.Loc(), "type of the value must be known to call method %s", node.Name.Text)
+		return t.newVar()
+	case *TyCon:
+		modName = TypeModuleName(ty.Name)
+		if m := GetModule(modName); m != nil {
+			scm = m.GetSig(node.Name.Text)
+		}
+	}
+	if scm == nil {
+		t.error(node.Loc(), "type %s has no method %s", recvTy.Desc(), node.Name.Text)
+		return t.newVar()
+	}
+	ty, preds := t.instantiatePreds(scm)
+	t.require(preds, node.Loc(), nil)
+	fun, ok := ty.(*TyFun)
+	if !ok || len(fun.Params) == 0 {
+		t.error(node.Loc(), "%s.%s is not a method", modName, node.Name.Text)
+		return t.newVar()
+	}
+	t.expect(node.Exp, fun.Params[0], recvTy)
+	t.info.Methods[node] = modName
+	return NewTyFun(fun.Ret, fun.Params[1:]...)
+}
+
+// inferIndex returns the type of "exp[index]". Lists and strings can be
+// indexed with ints to get an element and with ranges to get a slice.
+func (t *typer) inferIndex(node *IndexExpNode) Type {
+	ty := t.infer(node.Exp)
+	idxTy := t.infer(node.Index)
+	slice := false
+	switch idx := PruneType(idxTy).(type) {
+	case *TyCon:
+		slice = idx.Name == TyRange.Name
+	}
+	if !slice {
+		t.expect(node.Index, TyInt, idxTy)
+	}
+	if con, ok := PruneType(ty).(*TyCon); ok && con.Name == TyString.Name {
+		return TyString
+	}
+	eltTy := t.newVar()
+	t.expect(node.Exp, NewTyList(eltTy), ty)
+	if slice {
+		return ty
+	}
+	return eltTy
+}
+
+// inferBinding infers the type of the pattern binding the variables
+// in the current environment. As matching at runtime, pins refer to
+// the variables before the pattern.
+func (t *typer) inferBinding(node PtnNode) Type {
+	outer, outerPin := t.env, t.pinEnv
+	t.env, t.pinEnv = newTyEnv(outer), outer
+	ty := t.inferPtn(node)
+	for name, scm := range t.env.vars {
+		outer.set(name, scm)
+	}
+	t.env, t.pinEnv = outer, outerPin
+	return ty
+}
+
+// inferPtn returns the type of a pattern and binds its variables
+// into the current scope. The type is recorded.
+func (t *typer) inferPtn(node PtnNode) Type {
+	ty := t.inferPtnNode(node)
+	t.info.Types[node] = ty
+	return ty
+}
+
+func (t *typer) inferPtnNode(node PtnNode) Type {
+	switch node := node.(type) {
+	case *UnitPtnNode:
+		return TyUnit
+	case *BoolPtnNode:
+		return TyBool
+	case *IntPtnNode:
+		return TyInt
+	case *FloatPtnNode:
+		return TyFloat
+	case *StrPtnNode:
+		return TyString
+	case *RangePtnNode:
+		for _, bound := range []PtnNode{node.Left, node.Right} {
+			if _, ok := bound.(*IntPtnNode); !ok {
+				t.error(bound.Loc(), "bounds of range patterns must be integers")
+			}
+		}
+		return TyInt
+	case *VarPtnNode:
+		ty := t.newVar()
+		if node.Name.Text != "_" {
+			t.bindMono(node.Name.Text, ty)
+		}
+		return ty
+	case *OrPtnNode:
+		// both alternatives must bind the same variables with the same types
+		ty := t.inferPtn(node.Left)
+		leftVars := ptnVars(node.Left, nil)
+		leftTys := make(map[string]Type, len(leftVars))
+		for _, name := range leftVars {
+			leftTys[name] = t.env.get(name).Type
+		}
+		t.expect(node.Right, ty, t.inferPtn(node.Right))
+		rightVars := ptnVars(node.Right, nil)
+		for _, name := range rightVars {
+			if leftTy, ok := leftTys[name]; ok {
+				t.expectLoc(node.Right.Loc(), leftTy, t.env.get(name).Type)
+				delete(leftTys, name)
+			} else {
+				t.error(node.Left.Loc(), "variable %s must occur on both sides of this | pattern", name)
+			}
+		}
+		for _, name := range leftVars {
+			if _, ok := leftTys[name]; ok {
+				t.error(node.Right.Loc(), "variable %s must occur on both sides of this | pattern", name)
+			}
+		}
+		return ty
+	case *SomePtnNode:
+		return NewTyOption(t.inferPtn(node.Value))
+	case *NonePtnNode:
+		return NewTyOption(t.newVar())
+	case *AsPtnNode:
+		ty := t.inferPtn(node.Ptn)
+		t.bindMono(node.Name.Text, ty)
+		return ty
+	case *PinPtnNode:
+		// compared with the value of the variable before matching
+		scm := t.pinEnv.get(node.Name.Text)
+		if scm == nil {
+			t.error(&node.Name.Loc, "unbound variable %s", node.Name.Text)
+			return t.newVar()
+		}
+		ty := t.instantiate(scm)
+		t.require([]*TyPred{{Trait: TraitEq, Type: ty}}, node.Loc(), nil)
+		return ty
+	case *TuplePtnNode:
+		eltTys := make([]Type, len(node.Elts.Elts))
+		for i, elt := range node.Elts.Elts {
+			eltTys[i] = t.inferPtn(elt)
+		}
+		return &TyTuple{Elts: eltTys}
+	case *ListPtnNode:
+		eltTy := t.newVar()
+		for _, elt := range node.Elts.Elts {
+			t.expect(elt, eltTy, t.inferPtn(elt))
+		}
+		return NewTyList(eltTy)
+	case *ConsPtnNode:
+		eltTy := t.inferPtn(node.Left)
+		ty := NewTyList(eltTy)
+		t.expect(node.Right, ty, t.inferPtn(node.Right))
+		return ty
+	case *CtorPtnNode:
+		var args []PtnNode
+		if node.Args != nil {
+			args = node.Args.Elts
+		}
+		ctor := t.ctors[node.Name.Text]
+		if ctor == nil {
+			t.error(node.Loc(), "unknown constructor %s", node.Name.Text)
+			for _, arg := range args {
+				t.inferPtn(arg)
+			}
+			return t.newVar()
+		}
+		t.info.Ctors[node] = ctor.variant.name
+		if len(args) != len(ctor.types) {
+			t.error(node.Loc(), "constructor %s takes %d arguments, but %d given",
+				ctor.name, len(ctor.types), len(args))
+		}
+		ty := t.instantiate(ctor.scheme())
+		if fun, ok := ty.(*TyFun); ok && len(ctor.types) > 0 {
+			for i, arg := range args {
+				if i < len(fun.Params) {
+					t.expect(arg, fun.Params[i], t.inferPtn(arg))
+				} else {
+					t.inferPtn(arg)
+				}
+			}
+			return fun.Ret
+		}
+		for _, arg := range args {
+			t.inferPtn(arg)
+		}
+		return ty
+	case *RecordPtnNode:
+		var st *tyStruct
+		if node.Name != nil {
+			if st = t.structs[node.Name.Text]; st == nil {
+				t.error(&node.Name.Loc, "unknown struct %s", node.Name.Text)
+			}
+		} else if len(node.Fields) > 0 {
+			if st = t.owners[node.Fields[0].Name.Text]; st == nil {
+				t.error(node.Loc(), "unknown field %s", node.Fields[0].Name.Text)
+			}
+		}
+		for _, field := range node.Fields {
+			var fieldTy Type
+			if st != nil {
+				if fieldTy = st.fieldType(field.Name.Text); fieldTy == nil {
+					t.error(&field.Name.Loc, "struct %s has no field %s",
+						st.name, field.Name.Text)
+				}
+			}
+			if fieldTy == nil {
+				fieldTy = t.newVar()
+			}
+			if field.Ptn != nil {
+				t.expect(field.Ptn, fieldTy, t.inferPtn(field.Ptn))
+			} else {
+				t.bindMono(field.Name.Text, fieldTy)
+			}
+		}
+		if st == nil {
+			return t.newVar()
+		}
+		return st.ty()
+	default:
+		t.error(node.Loc(), "unsupported pattern %s", NodeDesc(node))
+		return t.newVar()
+	}
+}
+
+// ptnVars appends the names of the variables bound by the pattern.
+func ptnVars(node PtnNode, names []string) []string {
+	switch node := node.(type) {
+	case *VarPtnNode:
+		if node.Name.Text != "_" {
+			names = append(names, node.Name.Text)
+		}
+	case *AsPtnNode:
+		names = append(ptnVars(node.Ptn, names), node.Name.Text)
+	case *OrPtnNode:
+		names = ptnVars(node.Left, names)
+	case *SomePtnNode:
+		names = ptnVars(node.Value, names)
+	case *ConsPtnNode:
+		names = ptnVars(node.Right, ptnVars(node.Left, names))
+	case *TuplePtnNode:
+		for _, elt := range node.Elts.Elts {
+			names = ptnVars(elt, names)
+		}
+	case *ListPtnNode:
+		for _, elt := range node.Elts.Elts {
+			names = ptnVars(elt, names)
+		}
+	case *CtorPtnNode:
+		if node.Args != nil {
+			for _, arg := range node.Args.Elts {
+				names = ptnVars(arg, names)
+			}
+		}
+	case *RecordPtnNode:
+		for _, field := range node.Fields {
+			if field.Ptn != nil {
+				names = ptnVars(field.Ptn, names)
+			} else {
+				names = append(names, field.Name.Text)
+			}
+		}
+	}
+	return names
+}
+
+// inferFields checks types of field values. st is nullable.
+func (t *typer) inferFields(st *tyStruct, fields []FieldNode) {
+	for i, field := range fields {
+		var ty Type
+		if field.Exp != nil {
+			ty = t.infer(field.Exp)
+		} else if scm := t.env.get(field.Name.Text); scm != nil {
+			ty = t.instantiate(scm)
+		} else {
+			t.error(&field.Name.Loc, "unbound variable %s", field.Name.Text)
+			ty = t.newVar()
+		}
+		for _, prev := range fields[:i] {
+			if prev.Name.Text == field.Name.Text {
+				t.error(&field.Name.Loc, "duplicate field %s", field.Name.Text)
+			}
+		}
+		if st == nil {
+			continue
+		}
+		if fieldTy := st.fieldType(field.Name.Text); fieldTy != nil {
+			t.expectLoc(&field.Name.Loc, fieldTy, ty)
+		} else {
+			t.error(&field.Name.Loc, "struct %s has no field %s",
+				st.name, field.Name.Text)
+		}
+	}
+}
+
+func (t *typer) inferTrait(node *TraitDeclNode) {
+	name := node.Name.Text
+	if t.traits[name] != nil {
+		t.error(&node.Name.Loc, "trait %s is already defined", name)
+	}
+	tr := &tyTrait{name: name, methods: make(map[string]*TyScheme, len(node.Methods))}
+	param := t.newGenVar()
+	saved := t.tyParams
+	t.tyParams = map[string]*TyVar{node.Param.Text: param}
+	for _, sig := range node.Methods {
+		var params []Type
+		for i, pname := range sig.Params.Names {
+			if tyNode := sig.Params.TypeAt(i); tyNode != nil {
+				params = append(params, t.resolveType(tyNode))
+			} else {
+				t.error(&pname.Loc, "parameter %s of method %s requires a type annotation",
+					pname.Text, sig.Name.Text)
+				params = append(params, t.newGenVar())
+			}
+		}
+		if len(params) == 0 || PruneType(params[0]) != param {
+			// methods are dispatched with the first argument at run time
+			t.error(&sig.Name.Loc, "the first parameter of method %s must be of type %s",
+				sig.Name.Text, node.Param.Text)
+		}
+		scm := NewTyScheme([]*TyVar{param}, NewTyFun(t.resolveType(sig.RetType), params...))
+		scm.Preds = []*TyPred{{Trait: name, Type: param}}
+		tr.names = append(tr.names, sig.Name.Text)
+		tr.methods[sig.Name.Text] = scm
+		t.methods[sig.Name.Text] = tr
+		t.env.set(sig.Name.Text, scm)
+	}
+	t.tyParams = saved
+	t.traits[name] = tr
+}
+
+func (t *typer) inferImpl(node *ImplDeclNode) {
+	tr := t.traits[node.Trait.Text]
+	if tr == nil {
+		t.error(&node.Trait.Loc, "unknown trait %s", node.Trait.Text)
+		return
+	}
+	ty := t.resolveType(node.Type)
+	con, ok := PruneType(ty).(*TyCon)
+	if !ok {
+		t.error(node.Type.Loc(), "impl requires a named type, but found %s", ty.Desc())
+		return
+	}
+	t.info.ImplTypes[node] = con.Name
+	if t.impls[tr.name][con.Name] {
+		t.error(&node.Trait.Loc, "impl %s<%s> is already defined", tr.name, con.Name)
+	}
+	if t.impls[tr.name] == nil {
+		t.impls[tr.name] = make(map[string]bool, 8)
+	}
+	t.impls[tr.name][con.Name] = true
+
+	defined := make(map[string]bool, len(node.Defs))
+	for _, def := range node.Defs {
+		var name Token
+		t.enterScope()
+		switch def := def.(type) {
+		case *DefStatNode:
+			name = def.Name
+		case *ShortDefStatNode:
+			name = def.Name
+		}
+		t.inferDefs([]Node{def})
+		actual := t.instantiate(t.env.get(name.Text))
+		t.leaveScope()
+
+		scm := tr.methods[name.Text]
+		if scm == nil {
+			t.error(&name.Loc, "%s is not a method of trait %s", name.Text, tr.name)
+			continue
+		}
+		expected, preds := t.instantiatePreds(scm)
+		for _, pred := range preds {
+			if pred.Trait == tr.name {
+				t.unify(pred.Type, ty)
+			}
+		}
+		t.expect(def, expected, actual)
+		defined[name.Text] = true
+	}
+	for _, name := range tr.names {
+		if !defined[name] {
+			t.error(&node.End, "impl %s<%s> lacks method %s", tr.name, con.Name, name)
+		}
+	}
+}
+
+func (t *typer) isModuleName(name string) bool {
+	return t.env.get(name) == nil && RootModule != nil && GetModule(name) != nil
+}
+
+// isTypeName reports whether the name is a built-in type or a type
+// already declared.
+func (t *typer) isTypeName(name string) bool {
+	_, builtin := tyNames[name]
+	_, con := tyCons[name]
+	return builtin || con || t.structs[name] != nil || t.variants[name] != nil
+}
+
+var tyNames = map[string]Type{
+	"unit":   TyUnit,
+	"Unit":   TyUnit,
+	"bool":   TyBool,
+	"Bool":   TyBool,
+	"int":    TyInt,
+	"Int":    TyInt,
+	"float":  TyFloat,
+	"Float":  TyFloat,
+	"string": TyString,
+	"String": TyString,
+	"range":  TyRange,
+	"Range":  TyRange,
+}
+
+// tyCons are built-in type constructors and their numbers of arguments.
+var tyCons = map[string]int{
+	"list": 1,
+	"box":  1,
+}
+
+// resolveType converts a type annotation to a type.
+func (t *typer) resolveType(node TypeNode) Type {
+	switch node := node.(type) {
+	case *NamedTypeNode:
+		name := node.Name.Text
+		if tv, ok := t.tyParams[name]; ok && len(node.Args) == 0 {
+			return tv
+		}
+		if ty, ok := tyNames[name]; ok && len(node.Args) == 0 {
+			return ty
+		}
+		if st, ok := t.structs[name]; ok && len(node.Args) == 0 {
+			return st.ty()
+		}
+		n, ok := tyCons[name]
+		if v, isVariant := t.variants[name]; isVariant {
+			n, ok = len(v.params), true
+		}
+		if ok {
+			if n != len(node.Args) {
+				t.error(node.Loc(), "type %s takes %d arguments, but %d given",
+					name, n, len(node.Args))
+				return t.newVar()
+			}
+			args := make([]Type, len(node.Args))
+			for i, arg := range node.Args {
+				args[i] = t.resolveType(arg)
+			}
+			return &TyCon{Name: name, Args: args}
+		}
+		t.error(node.Loc(), "unknown type %s", name)
+		return t.newVar()
+	case *OptTypeNode:
+		return NewTyOption(t.resolveType(node.Type))
+	case *TupleTypeNode:
+		if len(node.Elts) == 0 {
+			return TyUnit
+		}
+		elts := make([]Type, len(node.Elts))
+		for i, elt := range node.Elts {
+			elts[i] = t.resolveType(elt)
+		}
+		return &TyTuple{Elts: elts}
+	case *FunTypeNode:
+		params := make([]Type, len(node.Params))
+		for i, param := range node.Params {
+			params[i] = t.resolveType(param)
+		}
+		return NewTyFun(t.resolveType(node.Ret), params...)
+	default:
+		t.error(node.Loc(), "unsupported type %s", NodeDesc(node))
+		return t.newVar()
+	}
+}
+
+// TypeInfo is the result of type checking. The compiler refers to it
+// for the code depending on the types.
+type TypeInfo struct {
+	// types of the expressions, the statements and the patterns.
+	// The types may be type variables to be pruned with PruneType.
+	Types map[Node]Type
+
+	// type names of the impls of the methods resolved statically
+	Impls map[*VarExpNode]string
+
+	// modules of the types of the receivers of the methods
+	// called as "value.method(args)"
+	Methods map[*AttrExpNode]string
+
+	// struct names of the records
+	Structs map[*RecordExpNode]string
+
+	// type names of the impls
+	ImplTypes map[*ImplDeclNode]string
+
+	// type names of the constructor patterns
+	Ctors map[*CtorPtnNode]string
+}
+
+// TypeCheck infers types of all expressions in the node and reports
+// ill-typed expressions. Attributes of modules are typed by their signatures.
+func TypeCheck(path string, node Node) (*TypeInfo, []Diagnostic) {
+	t := newTyper(path)
+	t.infer(node)
+	t.checkPreds()
+	return t.info, t.errs
+}
@@ -0,0 +1,129 @@
+package trompe
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTypeErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		stats []Node
+	}{
+		{"arity", []Node{call(vr("id"), in("1"), in("2"))}},
+		{"unbound", []Node{call(vr("nope"))}},
+		{"operand", []Node{bin(in("1"), "+", bl(true))}},
+		{"int and float", []Node{bin(in("1"), "+", fl("1.0"))}},
+		{"annotation", []Node{&LetStatNode{Ptn: vp("x"), Type: named("string"), Exp: in("1")}}},
+		{"list element", []Node{lst(in("1"), st("a"))}},
+		{"cons", []Node{bin(in("1"), "::", lst(st("a")))}},
+		{"raise", []Node{raise(in("1"))}},
+		{"index", []Node{idx(st("a"), st("b"))}},
+		{"method", []Node{mcall(st("a"), "nothing")}},
+		{"module attribute", []Node{call(attr(vr("core"), "nope"))}},
+		{"pipe", []Node{bin(st("a"), "|>", vr("float"))}},
+		{"unsupported node", []Node{named("int")}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			check(t, 1, c.stats...)
+		})
+	}
+}
+
+func TestTypeInfo(t *testing.T) {
+	rec := record("", field("x", in("1")), field("y", st("a")))
+	info := check(t, 0, structDecl("p", "x", "int", "y", "string"), rec)
+	if info.Structs[rec] != "p" {
+		t.Errorf("struct %q", info.Structs[rec])
+	}
+	if ty := info.Types[rec]; ty == nil || ty.Desc() != "p" {
+		t.Errorf("type %v", ty)
+	}
+}
+
+func TestLetGeneralization(t *testing.T) {
+	// let f = fun(x) in x end; (f(1), f("a"))
+	check(t, 0, let(vp("f"), anon([]string{"x"}, vr("x"))),
+		tupe(call(vr("f"), in("1")), call(vr("f"), st("a"))))
+	// def f(x) = x; (f(1), f("a"))
+	check(t, 0, sdef("f", []string{"x"}, vr("x")),
+		tupe(call(vr("f"), in("1")), call(vr("f"), st("a"))))
+	// let b = box(none) is not generalized
+	check(t, 1, let(vp("b"), call(vr("box"), none())),
+		&AssignStatNode{Target: vr("b"), Exp: some(in("1"))},
+		&AssignStatNode{Target: vr("b"), Exp: some(st("a"))})
+	// parameters are monomorphic in the body
+	check(t, 1, sdef("g", []string{"f"}, tupe(call(vr("f"), in("1")), call(vr("f"), st("a")))))
+}
+
+func TestRecursion(t *testing.T) {
+	// def g(x) = g(x, 1)
+	check(t, 1, sdef("g", []string{"x"}, call(vr("g"), vr("x"), in("1"))))
+	fib := def("fib", []string{"n"},
+		ifElse(bin(vr("n"), "<=", in("1")), blk(ret(vr("n"))),
+			blk(ret(bin(call(vr("fib"), bin(vr("n"), "-", in("1"))), "+",
+				call(vr("fib"), bin(vr("n"), "-", in("2"))))))))
+	eval(t, "55", fib, call(vr("fib"), in("10")))
+
+	// mutual recursion
+	even := sdef("even", []string{"n"}, ifElse(bin(vr("n"), "==", in("0")),
+		blk(bl(true)), blk(call(vr("odd"), bin(vr("n"), "-", in("1"))))))
+	odd := sdef("odd", []string{"n"}, ifElse(bin(vr("n"), "==", in("0")),
+		blk(bl(false)), blk(call(vr("even"), bin(vr("n"), "-", in("1"))))))
+	eval(t, "true", even, odd, call(vr("odd"), in("7")))
+	check(t, 1, even, odd, call(vr("odd"), st("7")))
+	// the group is generalized together
+	check(t, 0, sdef("ev", []string{"x"}, call(vr("od"), vr("x"))),
+		sdef("od", []string{"x"}, call(vr("ev"), vr("x"))),
+		call(vr("ev"), in("1")), call(vr("od"), st("a")))
+	// only consecutive definitions are grouped
+	check(t, 1, sdef("f", nil, call(vr("g"))), let(vp("x"), in("1")), sdef("g", nil, in("1")))
+}
+
+func TestTypeAliases(t *testing.T) {
+	for _, name := range []string{"Unit", "Bool", "Int", "Float", "String", "Range"} {
+		lower := named(strings.ToLower(name))
+		f := sdef("f", []string{"x"}, vr("x"))
+		f.Params.Types[0] = named(name)
+		f.RetType = lower
+		check(t, 0, f)
+	}
+	check(t, 0, &LetStatNode{Ptn: vp("s"), Type: named("String"), Exp: st("a")})
+	check(t, 1, &LetStatNode{Ptn: vp("s"), Type: named("String"), Exp: in("1")})
+}
+
+func TestRefutableLet(t *testing.T) {
+	check(t, 1, let(lptn(vp("a")), lst(in("1"))))
+	check(t, 1, let(somep(vp("a")), some(in("1"))))
+	check(t, 0, let(tup(vp("a"), vp("b")), tupe(in("1"), in("2"))))
+}
+
+func TestVariantTypes(t *testing.T) {
+	shape := &TypeDeclNode{Name: tok("shape"), Ctors: []CtorDeclNode{
+		{Name: tok("Circle"), Types: []TypeNode{named("int")}},
+		{Name: tok("Empty")},
+	}}
+	eval(t, "Circle(7)", shape, call(vr("Circle"), in("7")))
+	check(t, 1, shape, call(vr("Circle"), st("a")))
+	check(t, 0, &LetStatNode{Ptn: vp("n"), Type: &OptTypeNode{Type: named("int")}, Exp: none()})
+
+	// redeclarations
+	variant := func(name string, ctors ...string) *TypeDeclNode {
+		decl := &TypeDeclNode{Name: tok(name)}
+		for _, ctor := range ctors {
+			decl.Ctors = append(decl.Ctors, CtorDeclNode{Name: tok(ctor)})
+		}
+		return decl
+	}
+	check(t, 1, shape, variant("shape", "Square"))
+	check(t, 1, shape, variant("color", "Red", "Empty"))
+	check(t, 1, variant("option", "Nothing"))
+	check(t, 1, variant("exn", "Mine"))
+	check(t, 1, variant("int", "Zero"))
+	check(t, 1, variant("color", "Error"))
+	check(t, 1, pointDecl(), variant("point", "P"))
+	check(t, 1, shape, structDecl("shape", "x", "int"))
+	check(t, 1, structDecl("list", "x", "int"))
+	check(t, 0, shape, variant("color", "Red", "Green"))
+}
@@ -0,0 +1,166 @@
+package trompe
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Type interface {
+	Desc() string
+}
+
+type TyVar struct {
+	Id    int
+	Level int
+	Inst  Type // nullable, instantiated type
+}
+
+type TyCon struct {
+	Name string
+	Args []Type
+}
+
+type TyTuple struct {
+	Elts []Type
+}
+
+type TyFun struct {
+	Params []Type
+	Ret    Type
+}
+
+type TyScheme struct {
+	Vars  []*TyVar
+	Preds []*TyPred // traits that the variables must implement
+	Type  Type
+}
+
+// TyPred is a predicate that the type implements the trait.
+type TyPred struct {
+	Trait string
+	Type  Type
+}
+
+var TyUnit = &TyCon{Name: "unit"}
+var TyBool = &TyCon{Name: "bool"}
+var TyInt = &TyCon{Name: "int"}
+var TyFloat = &TyCon{Name: "float"}
+var TyString = &TyCon{Name: "string"}
+var TyRange = &TyCon{Name: "range"}
+
+func NewTyList(elt Type) *TyCon {
+	return &TyCon{Name: "list", Args: []Type{elt}}
+}
+
+func NewTyBox(elt Type) *TyCon {
+	return &TyCon{Name: "box", Args: []Type{elt}}
+}
+
+// IsTyBox reports whether the type is a box.
+func IsTyBox(ty Type) bool {
+	con, ok := PruneType(ty).(*TyCon)
+	return ok && con.Name == "box"
+}
+
+func NewTyOption(elt Type) *TyCon {
+	return &TyCon{Name: "option", Args: []Type{elt}}
+}
+
+func NewTyFun(ret Type, params ...Type) *TyFun {
+	return &TyFun{Params: params, Ret: ret}
+}
+
+func NewTyScheme(vars []*TyVar, ty Type) *TyScheme {
+	return &TyScheme{Vars: vars, Type: ty}
+}
+
+// PruneType follows instantiated type variables.
+func PruneType(ty Type) Type {
+	for {
+		if tv, ok := ty.(*TyVar); ok && tv.Inst != nil {
+			ty = tv.Inst
+		} else {
+			return ty
+		}
+	}
+}
+
+func (tv *TyVar) Desc() string {
+	return newTyPrinter().desc(tv)
+}
+
+func (tc *TyCon) Desc() string {
+	return newTyPrinter().desc(tc)
+}
+
+func (tt *TyTuple) Desc() string {
+	return newTyPrinter().desc(tt)
+}
+
+func (tf *TyFun) Desc() string {
+	return newTyPrinter().desc(tf)
+}
+
+func (ts *TyScheme) Desc() string {
+	p := newTyPrinter()
+	if len(ts.Preds) == 0 {
+		return p.desc(ts.Type)
+	}
+	preds := make([]string, len(ts.Preds))
+	for i, pred := range ts.Preds {
+		preds[i] = pred.Trait + " " + p.desc(pred.Type)
+	}
+	return fmt.Sprintf("%s => %s", strings.Join(preds, ", "), p.desc(ts.Type))
+}
+
+type tyPrinter struct {
+	names map[*TyVar]string
+}
+
+func newTyPrinter() *tyPrinter {
+	return &tyPrinter{names: make(map[*TyVar]string, 4)}
+}
+
+func (p *tyPrinter) varName(tv *TyVar) string {
+	if name, ok := p.names[tv]; ok {
+		return name
+	}
+	n := len(p.names)
+	name := "'" + string(rune('a'+n%26))
+	if n >= 26 {
+		name += fmt.Sprintf("%d", n/26)
+	}
+	p.names[tv] = name
+	return name
+}
+
+func (p *tyPrinter) descList(tys []Type) string {
+	descs := make([]string, len(tys))
+	for i, ty := range tys {
+		descs[i] = p.desc(ty)
+	}
+	return strings.Join(descs, ", ")
+}
+
+func (p *tyPrinter) desc(ty Type) string {
+	switch ty := PruneType(ty).(type) {
+	case *TyVar:
+		return p.varName(ty)
+	case *TyCon:
+		if len(ty.Args) == 0 {
+			return ty.Name
+		}
+		return fmt.Sprintf("%s<%s>", ty.Name, p.descList(ty.Args))
+	case *TyTuple:
+		return fmt.Sprintf("(%s)", p.descList(ty.Elts))
+	case *TyFun:
+		if len(ty.Params) == 1 {
+			if _, ok := PruneType(ty.Params[0]).(*TyFun); !ok {
+				return fmt.Sprintf("%s -> %s", p.desc(ty.Params[0]), p.desc(ty.Ret))
+			}
+		}
+		return fmt.Sprintf("(%s) -> %s", p.descList(ty.Params), p.desc(ty.Ret))
+	default:
+		panic("unknown type")
+	}
+}
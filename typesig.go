@@ -0,0 +1,213 @@
+package trompe
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ParseTypeSig parses a type signature of library functions
+// such as "'a -> 'a", "(int, string) -> bool" and "Ord 'a => list<'a> -> list<'a>".
+// Type variables are generalized.
+//
+//	scheme  : (pred (',' pred)* '=>')? sig
+//	pred    : NAME atom
+//	sig     : postfix ('->' sig)?
+//	postfix : atom '?'*
+//	atom    : "'" NAME | NAME ('<' sig (',' sig)* '>')? | '(' (sig (',' sig)*)? ')'
+//
+// Parenthesized types before '->' are parameters of a function.
+func ParseTypeSig(sig string) (*TyScheme, error) {
+	p := &sigParser{src: sig, vars: make(map[string]*TyVar, 4)}
+	p.next()
+	var preds []*TyPred
+	if strings.Contains(sig, "=>") {
+		for {
+			if p.tok == "" || !isSigNameChar(rune(p.tok[0])) {
+				return nil, p.errorf("trait name required")
+			}
+			trait := p.tok
+			p.next()
+			tys, _, err := p.parseAtom()
+			if err != nil {
+				return nil, err
+			}
+			preds = append(preds, &TyPred{Trait: trait, Type: p.joinTuple(tys)})
+			if p.tok != "," {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect("=>"); err != nil {
+			return nil, err
+		}
+	}
+	ty, err := p.parseSig()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, p.errorf("unexpected %s", p.tokDesc())
+	}
+	var vars []*TyVar
+	for _, name := range p.order {
+		vars = append(vars, p.vars[name])
+	}
+	scm := NewTyScheme(vars, ty)
+	scm.Preds = preds
+	return scm, nil
+}
+
+type sigParser struct {
+	src   string
+	pos   int
+	tok   string // empty at the end
+	vars  map[string]*TyVar
+	order []string
+}
+
+func (p *sigParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid type signature \"%s\": %s", p.src, fmt.Sprintf(format, args...))
+}
+
+func isSigNameChar(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func (p *sigParser) next() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+	start := p.pos
+	switch {
+	case p.pos >= len(p.src):
+	case strings.HasPrefix(p.src[p.pos:], "->"), strings.HasPrefix(p.src[p.pos:], "=>"):
+		p.pos += 2
+	case isSigNameChar(rune(p.src[p.pos])):
+		for p.pos < len(p.src) && isSigNameChar(rune(p.src[p.pos])) {
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+	p.tok = p.src[start:p.pos]
+}
+
+func (p *sigParser) tokDesc() string {
+	if p.tok == "" {
+		return "end of signature"
+	}
+	return p.tok
+}
+
+func (p *sigParser) expect(tok string) error {
+	if p.tok != tok {
+		return p.errorf("expected %s, but found %s", tok, p.tokDesc())
+	}
+	p.next()
+	return nil
+}
+
+func (p *sigParser) parseSig() (Type, error) {
+	tys, paren, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok == "->" {
+		p.next()
+		ret, err := p.parseSig()
+		if err != nil {
+			return nil, err
+		}
+		if !paren {
+			tys = []Type{p.joinTuple(tys)}
+		}
+		return NewTyFun(ret, tys...), nil
+	}
+	return p.joinTuple(tys), nil
+}
+
+func (p *sigParser) joinTuple(tys []Type) Type {
+	switch len(tys) {
+	case 0:
+		return TyUnit
+	case 1:
+		return tys[0]
+	default:
+		return &TyTuple{Elts: tys}
+	}
+}
+
+// parsePostfix returns the elements if the type is parenthesized.
+func (p *sigParser) parsePostfix() ([]Type, bool, error) {
+	tys, paren, err := p.parseAtom()
+	if err != nil {
+		return nil, false, err
+	}
+	for p.tok == "?" {
+		p.next()
+		tys, paren = []Type{NewTyOption(p.joinTuple(tys))}, false
+	}
+	return tys, paren, nil
+}
+
+func (p *sigParser) parseAtom() ([]Type, bool, error) {
+	switch {
+	case p.tok == "'":
+		p.next()
+		if p.tok == "" || !isSigNameChar(rune(p.tok[0])) {
+			return nil, false, p.errorf("type variable name required")
+		}
+		name := p.tok
+		p.next()
+		tv, ok := p.vars[name]
+		if !ok {
+			tv = &TyVar{Id: -len(p.vars) - 1, Level: genericLevel}
+			p.vars[name] = tv
+			p.order = append(p.order, name)
+		}
+		return []Type{tv}, false, nil
+	case p.tok == "(":
+		p.next()
+		tys, err := p.parseSigList(")")
+		if err != nil {
+			return nil, false, err
+		}
+		return tys, true, nil
+	case p.tok != "" && isSigNameChar(rune(p.tok[0])):
+		name := p.tok
+		p.next()
+		var args []Type
+		if p.tok == "<" {
+			p.next()
+			var err error
+			if args, err = p.parseSigList(">"); err != nil {
+				return nil, false, err
+			}
+		}
+		if ty, ok := tyNames[name]; ok && len(args) == 0 {
+			return []Type{ty}, false, nil
+		}
+		return []Type{&TyCon{Name: name, Args: args}}, false, nil
+	default:
+		return nil, false, p.errorf("unexpected %s", p.tokDesc())
+	}
+}
+
+func (p *sigParser) parseSigList(close string) ([]Type, error) {
+	var tys []Type
+	for p.tok != close {
+		if len(tys) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		ty, err := p.parseSig()
+		if err != nil {
+			return nil, err
+		}
+		tys = append(tys, ty)
+	}
+	p.next()
+	return tys, nil
+}
@@ -3,21 +3,26 @@ package trompe
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 const (
 	ValueTypeUnit = iota
 	ValueTypeBool
 	ValueTypeInt
+	ValueTypeFloat
 	ValueTypeString
 	ValueTypeList
 	ValueTypeTuple
 	ValueTypeClos
-	ValueTypeOption
+	ValueTypeVariant
 	ValueTypeRange
 	ValueTypeIter
 	ValueTypePattern
 	ValueTypeRef
+	ValueTypeRecord
+	ValueTypeImpl
+	ValueTypeBox
 )
 
 type Value interface {
@@ -35,6 +40,10 @@ type Int struct {
 	Value int
 }
 
+type Float struct {
+	Value float64
+}
+
 type String struct {
 	Value string
 }
@@ -46,7 +55,7 @@ type Tuple struct {
 var SharedUnit = &Unit{}
 var SharedTrue = &Bool{true}
 var SharedFalse = &Bool{false}
-var SharedNone = &Option{nil}
+var SharedNone = NewVariant(OptionTypeName, OptionNoneTag, nil)
 
 func NewBool(b bool) *Bool {
 	if b {
@@ -74,6 +83,15 @@ func ValueToInt(v Value) (*Int, bool) {
 	}
 }
 
+func ValueToFloat(v Value) (*Float, bool) {
+	switch v := v.(type) {
+	case *Float:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
 func ValueToString(v Value) (*String, bool) {
 	switch v := v.(type) {
 	case *String:
@@ -105,16 +123,22 @@ func ValueToClos(v Value) (Closure, bool) {
 	switch v := v.(type) {
 	case *CompiledCode:
 		return v, true
+	case *Fun:
+		return v, true
 	case *Primitive:
 		return v, true
+	case *Ctor:
+		return v, true
+	case *Partial:
+		return v, true
 	default:
 		return nil, false
 	}
 }
 
-func ValueToOption(v Value) (*Option, bool) {
+func ValueToVariant(v Value) (*Variant, bool) {
 	switch v := v.(type) {
-	case *Option:
+	case *Variant:
 		return v, true
 	default:
 		return nil, false
@@ -130,6 +154,79 @@ func ValueToIter(v Value) (Iter, bool) {
 	}
 }
 
+func ValueToBox(v Value) (*Box, bool) {
+	switch v := v.(type) {
+	case *Box:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+func ValueToRecord(v Value) (*Record, bool) {
+	switch v := v.(type) {
+	case *Record:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// ValueEqual compares two values structurally.
+func ValueEqual(v1 Value, v2 Value) bool {
+	if v1 == v2 {
+		return true
+	}
+	switch v1 := v1.(type) {
+	case *Unit:
+		_, ok := v2.(*Unit)
+		return ok
+	case *Bool:
+		v2, ok := ValueToBool(v2)
+		return ok && v1.Value == v2.Value
+	case *Int:
+		v2, ok := ValueToInt(v2)
+		return ok && v1.Value == v2.Value
+	case *Float:
+		v2, ok := ValueToFloat(v2)
+		return ok && v1.Value == v2.Value
+	case *String:
+		v2, ok := ValueToString(v2)
+		return ok && v1.Value == v2.Value
+	case *Tuple:
+		v2, ok := ValueToTuple(v2)
+		if !ok || v1.Len() != v2.Len() {
+			return false
+		}
+		for i, e := range v1.Values {
+			if !ValueEqual(e, v2.Values[i]) {
+				return false
+			}
+		}
+		return true
+	case *List:
+		v2, ok := ValueToList(v2)
+		if !ok || v1.Len() != v2.Len() {
+			return false
+		}
+		values := v2.Values()
+		for i, e := range v1.Values() {
+			if !ValueEqual(e, values[i]) {
+				return false
+			}
+		}
+		return true
+	case *Variant:
+		v2, ok := ValueToVariant(v2)
+		return ok && v1.Equal(v2)
+	case *Record:
+		v2, ok := ValueToRecord(v2)
+		return ok && v1.Equal(v2)
+	default:
+		return false
+	}
+}
+
 func ValueToRef(v Value) (*Ref, bool) {
 	switch v := v.(type) {
 	case *Ref:
@@ -171,6 +268,24 @@ func (i *Int) Desc() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+func NewFloat(f float64) *Float {
+	return &Float{f}
+}
+
+func (f *Float) Type() int {
+	return ValueTypeFloat
+}
+
+// Desc returns the shortest representation that reads back as the same
+// number. Integral values keep a trailing ".0" to distinguish from ints.
+func (f *Float) Desc() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 func NewString(s string) *String {
 	return &String{s}
 }
@@ -192,8 +307,16 @@ func (t *Tuple) Type() int {
 }
 
 func (t *Tuple) Desc() string {
-	// TODO
-	return fmt.Sprintf("tuple")
+	return fmt.Sprintf("(%s)", ValuesDesc(t.Values))
+}
+
+// ValuesDesc returns the descriptions of the values separated by commas.
+func ValuesDesc(values []Value) string {
+	descs := make([]string, len(values))
+	for i, value := range values {
+		descs[i] = value.Desc()
+	}
+	return strings.Join(descs, ", ")
 }
 
 func (t *Tuple) Len() int {
@@ -224,6 +347,21 @@ func (r *Ref) Module() *Module {
 	return r.module
 }
 
+// StrToInt converts decimal and hexadecimal ("0x1F") integer literals.
 func StrToInt(s string) (int, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		n, err := strconv.ParseInt(s[2:], 16, 0)
+		return int(n), err
+	}
 	return strconv.Atoi(s)
 }
+
+// StrToFloat converts decimal ("1.5e3") and hexadecimal ("0x1.8p1")
+// floating-point literals.
+func StrToFloat(s string) (float64, error) {
+	if (strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X")) &&
+		!strings.ContainsAny(s, "pP") {
+		s += "p0"
+	}
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,79 @@
+package trompe
+
+import (
+	"fmt"
+)
+
+type Variant struct {
+	TypeName string
+	Tag      string
+	Values   []Value
+}
+
+// Ctor is a constructor of variants taking one or more arguments.
+type Ctor struct {
+	TypeName string
+	Tag      string
+	arity    int
+}
+
+func NewVariant(typeName string, tag string, values []Value) *Variant {
+	return &Variant{TypeName: typeName, Tag: tag, Values: values}
+}
+
+func (v *Variant) Type() int {
+	return ValueTypeVariant
+}
+
+func (v *Variant) Desc() string {
+	tag := variantTagDesc(v.TypeName, v.Tag)
+	if len(v.Values) == 0 {
+		return tag
+	}
+	return fmt.Sprintf("%s(%s)", tag, ValuesDesc(v.Values))
+}
+
+func (v *Variant) Equal(other *Variant) bool {
+	if v.TypeName != other.TypeName || v.Tag != other.Tag ||
+		len(v.Values) != len(other.Values) {
+		return false
+	}
+	for i, value := range v.Values {
+		if !ValueEqual(value, other.Values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewCtorValue returns a value bound to the constructor name.
+// A constructor without arguments is the variant itself.
+func NewCtorValue(typeName string, tag string, arity int) Value {
+	if arity == 0 {
+		return NewVariant(typeName, tag, nil)
+	}
+	return &Ctor{TypeName: typeName, Tag: tag, arity: arity}
+}
+
+func (c *Ctor) Type() int {
+	return ValueTypeClos
+}
+
+func (c *Ctor) Desc() string {
+	return fmt.Sprintf("<ctor %s.%s>", c.TypeName, c.Tag)
+}
+
+func (c *Ctor) Arity() int {
+	return c.arity
+}
+
+func (c *Ctor) Apply(ip *Interp, ctx *Context, env *Env) (Value, error) {
+	values := make([]Value, ctx.NumArgs)
+	copy(values, ctx.Args)
+	return NewVariant(c.TypeName, c.Tag, values), nil
+}
+
+// IsCtorName reports whether the name is a name of variant constructors.
+func IsCtorName(name string) bool {
+	return len(name) > 0 && 'A' <= name[0] && name[0] <= 'Z'
+}